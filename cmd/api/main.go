@@ -0,0 +1,27 @@
+// Command api serves the HTTP API without running the notification scheduler.
+package main
+
+import (
+	"context"
+	"os/signal"
+	"syscall"
+
+	"github.com/ilya-burinskiy/birthday-notify/internal/app"
+	"github.com/ilya-burinskiy/birthday-notify/internal/configs"
+)
+
+func main() {
+	config := configs.Parse()
+	logger := app.ConfigureLogger(config.LogLevel)
+	application, err := app.New(config, logger)
+	if err != nil {
+		panic(err)
+	}
+	defer application.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	if err := application.RunAPI(ctx); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,42 @@
+// Command mailcatcher is a development-only SMTP server. It keeps every
+// received message in memory and serves a web UI and JSON API to inspect them.
+package main
+
+import (
+	"flag"
+	"net/http"
+
+	"github.com/ilya-burinskiy/birthday-notify/internal/mailcatcher"
+	"go.uber.org/zap"
+)
+
+func main() {
+	smtpAddr := flag.String("smtp", "localhost:1025", "SMTP listen address")
+	httpAddr := flag.String("http", "localhost:1080", "HTTP listen address")
+	capacity := flag.Int("capacity", 1000, "maximum number of stored messages")
+	flag.Parse()
+
+	logger, err := zap.NewDevelopment()
+	if err != nil {
+		panic(err)
+	}
+
+	store := mailcatcher.NewStore(*capacity)
+	smtpServer := mailcatcher.NewSMTPServer(*smtpAddr, store, logger)
+	go func() {
+		logger.Info("smtp server started", zap.String("addr", *smtpAddr))
+		if err := smtpServer.ListenAndServe(); err != nil {
+			logger.Fatal("smtp server failed", zap.Error(err))
+		}
+	}()
+
+	handler := mailcatcher.NewHandler(logger, store)
+	server := http.Server{
+		Handler: handler.Router(),
+		Addr:    *httpAddr,
+	}
+	logger.Info("http server started", zap.String("addr", *httpAddr))
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		panic(err)
+	}
+}
@@ -1,107 +1,112 @@
+// Command notifier serves the HTTP API and runs the notification scheduler in
+// one process. Use cmd/api and cmd/worker to scale them independently.
 package main
 
 import (
-	"net/http"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
-	"github.com/go-chi/chi/v5"
-	"github.com/go-chi/chi/v5/middleware"
+	"github.com/ilya-burinskiy/birthday-notify/internal/app"
 	"github.com/ilya-burinskiy/birthday-notify/internal/configs"
-	"github.com/ilya-burinskiy/birthday-notify/internal/handlers"
-	"github.com/ilya-burinskiy/birthday-notify/internal/middlewares"
-	"github.com/ilya-burinskiy/birthday-notify/internal/services"
-	"github.com/ilya-burinskiy/birthday-notify/internal/storage"
-	"go.uber.org/zap"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "notify" {
+		runNotify(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "role" {
+		runRole(os.Args[2:])
+		return
+	}
+
 	config := configs.Parse()
-	store, err := storage.NewDBStorage(config.DSN)
+	logger := app.ConfigureLogger(config.LogLevel)
+	application, err := app.New(config, logger)
 	if err != nil {
 		panic(err)
 	}
-	logger := configureLogger("info")
-
-	registerSrv := services.NewRegisterService(store)
-	authSrv := services.NewAuthenticateService(store)
-	fetchUsersSrv := services.NewFetchUsersService(store)
-	subscribeSrv := services.NewSubscribeService(store)
-	unsubscribeSrv := services.NewUnsubscribeService(store, store)
-	notifySettingCreator := services.NewCreateNotificationSettingService(store)
-	notifySettingUpdator := services.NewUpdateNotificationService(store)
+	defer application.Close()
 
-	emailSender := services.NewEmailSender(config)
-	notifier := services.NewNotifier(logger, store, emailSender)
-	notifier.Start()
-
-	router := chi.NewRouter()
-	configureUserRouter(logger, registerSrv, authSrv, fetchUsersSrv, router)
-	configureSubscriptionRouter(logger, subscribeSrv, unsubscribeSrv, router)
-	configureNotificationSettingRouter(logger, notifySettingCreator, notifySettingUpdator, router)
-
-	server := http.Server{
-		Handler: router,
-		Addr:    config.RunAddr,
-	}
-	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	if err := application.RunAll(ctx); err != nil {
 		panic(err)
 	}
 }
 
-func configureUserRouter(
-	logger *zap.Logger,
-	registerSrv services.RegisterService,
-	authSrv services.AuthenticateService,
-	fetchSrv services.FetchUsersService,
-	mainRouter chi.Router) {
-
-	handler := handlers.NewUserHandlers(logger)
-	mainRouter.Group(func(router chi.Router) {
-		router.Use(middleware.AllowContentType("application/json"))
-		router.Post("/api/users/register", handler.Register(registerSrv))
-		router.Post("/api/users/login", handler.Authenticate(authSrv))
-		router.Get("/api/users", handler.Get(fetchSrv))
-	})
-}
-
-func configureSubscriptionRouter(
-	logger *zap.Logger,
-	subscribeSrv services.SubscribeService,
-	unsubscribeSrv services.UnsubscribeService,
-	mainRouter chi.Router) {
+// runNotify runs the notification cycle once and prints the report:
+//
+//	notifier notify [-date YYYY-MM-DD] [-dry-run] [config flags]
+func runNotify(args []string) {
+	flags := flag.NewFlagSet("notify", flag.ExitOnError)
+	dateStr := flags.String("date", time.Now().UTC().Format(time.DateOnly), "reference date (YYYY-MM-DD)")
+	dryRun := flags.Bool("dry-run", false, "print notifications without sending them")
+	config, err := configs.Load(flags, args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration:\n%s\n", err)
+		os.Exit(2)
+	}
 
-	handler := handlers.NewSubscriptionHandler(logger)
-	mainRouter.Group(func(router chi.Router) {
-		router.Use(middlewares.Authenticate)
-		router.Post("/api/users/{id}/subscribe", handler.Subscribe(subscribeSrv))
-		router.Delete("/api/users/{id}/unsubscribe", handler.Unsubscribe(unsubscribeSrv))
-	})
-}
+	date, err := time.Parse(time.DateOnly, *dateStr)
+	if err != nil {
+		panic(err)
+	}
 
-func configureNotificationSettingRouter(
-	logger *zap.Logger,
-	createSrv services.CreateNotificationSettingService,
-	updateSrv services.UpdateNotificationSettingService,
-	mainRouter chi.Router) {
+	logger := app.ConfigureLogger(config.LogLevel)
+	application, err := app.New(config, logger)
+	if err != nil {
+		panic(err)
+	}
+	defer application.Close()
 
-	handler := handlers.NewNotificationSettingHandler(logger)
-	mainRouter.Group(func(router chi.Router) {
-		router.Use(middlewares.Authenticate)
-		router.Post("/api/notify_settings", handler.Create(createSrv))
-		router.Patch("/api/notify_settings/{id}", handler.Update(updateSrv))
-	})
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	report, err := application.Notifier().Run(ctx, date, *dryRun)
+	if err != nil {
+		panic(err)
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		panic(err)
+	}
 }
 
-func configureLogger(level string) *zap.Logger {
-	logLvl, err := zap.ParseAtomicLevel(level)
+// runRole sets the role of a user, which is how the first administrator is
+// created:
+//
+//	notifier role -email EMAIL [-role admin|user] [config flags]
+func runRole(args []string) {
+	flags := flag.NewFlagSet("role", flag.ExitOnError)
+	email := flags.String("email", "", "email of the user")
+	role := flags.String("role", "admin", "role to assign (admin or user)")
+	config, err := configs.Load(flags, args)
 	if err != nil {
-		panic(err)
+		fmt.Fprintf(os.Stderr, "invalid configuration:\n%s\n", err)
+		os.Exit(2)
 	}
-	loggerConfig := zap.NewProductionConfig()
-	loggerConfig.Level = logLvl
-	logger, err := loggerConfig.Build()
+	if *email == "" {
+		fmt.Fprintln(os.Stderr, "-email is required")
+		os.Exit(2)
+	}
+
+	logger := app.ConfigureLogger(config.LogLevel)
+	application, err := app.New(config, logger)
 	if err != nil {
 		panic(err)
 	}
+	defer application.Close()
 
-	return logger
+	if err := application.Admin().SetRoleByEmail(context.Background(), *email, *role); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to set role: %s\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("%s is now %s\n", *email, *role)
 }
@@ -0,0 +1,27 @@
+// Command worker runs the notification scheduler without serving the HTTP API.
+package main
+
+import (
+	"context"
+	"os/signal"
+	"syscall"
+
+	"github.com/ilya-burinskiy/birthday-notify/internal/app"
+	"github.com/ilya-burinskiy/birthday-notify/internal/configs"
+)
+
+func main() {
+	config := configs.Parse()
+	logger := app.ConfigureLogger(config.LogLevel)
+	application, err := app.New(config, logger)
+	if err != nil {
+		panic(err)
+	}
+	defer application.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	if err := application.RunWorker(ctx); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,197 @@
+// Package app wires storage, services and handlers together. The API server
+// and the notification worker can run in separate processes or in one.
+package app
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/ilya-burinskiy/birthday-notify/internal/auth"
+	"github.com/ilya-burinskiy/birthday-notify/internal/configs"
+	"github.com/ilya-burinskiy/birthday-notify/internal/services"
+	"github.com/ilya-burinskiy/birthday-notify/internal/storage"
+	"go.uber.org/zap"
+)
+
+type App struct {
+	config     configs.Config
+	logger     *zap.Logger
+	store      *storage.DBStorage
+	notifier   services.Notifier
+	mailer     services.NotificationSender
+	background services.Background
+	jwtManager auth.JWTManager
+	passwords  auth.PasswordPolicy
+	photos     services.DirPhotoStore
+}
+
+func New(config configs.Config, logger *zap.Logger) (*App, error) {
+	jwtManager, err := newJWTManager(config)
+	if err != nil {
+		return nil, err
+	}
+
+	passwords, err := newPasswordPolicy(config)
+	if err != nil {
+		return nil, err
+	}
+
+	store, err := storage.NewDBStorage(config.DSN)
+	if err != nil {
+		return nil, err
+	}
+
+	emailSender, err := services.NewNotificationSender(config)
+	if err != nil {
+		store.Close()
+		return nil, err
+	}
+
+	photos, err := services.NewDirPhotoStore(config.PhotoDir)
+	if err != nil {
+		store.Close()
+		return nil, err
+	}
+
+	return &App{
+		config:     config,
+		logger:     logger,
+		store:      store,
+		notifier:   services.NewNotifier(logger, store, emailSender, store),
+		mailer:     emailSender,
+		background: services.NewBackground(),
+		jwtManager: jwtManager,
+		passwords:  passwords,
+		photos:     photos,
+	}, nil
+}
+
+func newPasswordPolicy(config configs.Config) (auth.PasswordPolicy, error) {
+	policy := auth.PasswordPolicy{MinLength: config.PasswordMinLength}
+	if !config.PasswordBreachCheck {
+		return policy, nil
+	}
+
+	if config.BreachedPasswordsFile == "" {
+		policy.Breached = auth.DefaultBreachedPasswords()
+		return policy, nil
+	}
+	breached, err := auth.LoadBreachedPasswords(config.BreachedPasswordsFile)
+	if err != nil {
+		return policy, err
+	}
+	policy.Breached = breached
+	return policy, nil
+}
+
+func newJWTManager(config configs.Config) (auth.JWTManager, error) {
+	var keys []auth.SigningKey
+	for _, configKey := range config.SigningKeys() {
+		material := []byte(configKey.Secret)
+		if configKey.PrivateKeyFile != "" {
+			var err error
+			material, err = os.ReadFile(configKey.PrivateKeyFile)
+			if err != nil {
+				return auth.JWTManager{}, fmt.Errorf("failed to read jwt key %q: %w", configKey.ID, err)
+			}
+		}
+
+		key, err := auth.NewSigningKey(configKey.ID, configKey.Algorithm, material)
+		if err != nil {
+			return auth.JWTManager{}, fmt.Errorf("invalid jwt key: %w", err)
+		}
+		keys = append(keys, key)
+	}
+
+	return auth.NewJWTManager(keys, config.JWTIssuer, config.JWTAudience, config.AuthTokenExp), nil
+}
+
+func (app *App) Notifier() services.Notifier {
+	return app.notifier
+}
+
+func (app *App) Admin() services.AdminService {
+	return services.NewAdminService(app.logger, app.store, app.photos)
+}
+
+// RunAPI serves HTTP until ctx is canceled and then drains in-flight requests.
+func (app *App) RunAPI(ctx context.Context) error {
+	server := http.Server{
+		Handler: app.Router(),
+		Addr:    app.config.RunAddr,
+	}
+
+	serverErr := make(chan error, 1)
+	go func() {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			serverErr <- err
+		}
+	}()
+
+	select {
+	case err := <-serverErr:
+		return fmt.Errorf("http server failed: %w", err)
+	case <-ctx.Done():
+	}
+
+	app.logger.Info("shutting down http server")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), app.config.ShutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("failed to shutdown http server: %w", err)
+	}
+	return nil
+}
+
+// RunWorker runs the notification scheduler until ctx is canceled and then
+// waits for the current batch.
+func (app *App) RunWorker(ctx context.Context) error {
+	app.notifier.Start()
+	<-ctx.Done()
+
+	app.logger.Info("stopping notifier")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), app.config.ShutdownTimeout)
+	defer cancel()
+	if err := app.notifier.Stop(shutdownCtx); err != nil {
+		return fmt.Errorf("failed to stop notifier: %w", err)
+	}
+	return nil
+}
+
+// RunAll runs the API server and the worker in one process.
+func (app *App) RunAll(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	errs := make(chan error, 2)
+	go func() { errs <- app.RunAPI(ctx) }()
+	go func() { errs <- app.RunWorker(ctx) }()
+
+	var firstErr error
+	for i := 0; i < 2; i++ {
+		if err := <-errs; err != nil && firstErr == nil {
+			firstErr = err
+			cancel()
+		}
+	}
+	return firstErr
+}
+
+// Close waits for notification batches and emails sent in the background and
+// closes the database. The API starts batches too, so the notifier is stopped
+// here and not only in RunWorker.
+func (app *App) Close() {
+	ctx, cancel := context.WithTimeout(context.Background(), app.config.ShutdownTimeout)
+	defer cancel()
+	if err := app.notifier.Stop(ctx); err != nil {
+		app.logger.Info("notification batch interrupted", zap.Error(err))
+	}
+	if err := app.background.Stop(ctx); err != nil {
+		app.logger.Info("background work interrupted", zap.Error(err))
+	}
+
+	app.store.Close()
+	app.logger.Sync()
+}
@@ -0,0 +1,18 @@
+package app
+
+import "go.uber.org/zap"
+
+func ConfigureLogger(level string) *zap.Logger {
+	logLvl, err := zap.ParseAtomicLevel(level)
+	if err != nil {
+		panic(err)
+	}
+	loggerConfig := zap.NewProductionConfig()
+	loggerConfig.Level = logLvl
+	logger, err := loggerConfig.Build()
+	if err != nil {
+		panic(err)
+	}
+
+	return logger
+}
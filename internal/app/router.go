@@ -0,0 +1,285 @@
+package app
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/ilya-burinskiy/birthday-notify/internal/auth"
+	"github.com/ilya-burinskiy/birthday-notify/internal/handlers"
+	"github.com/ilya-burinskiy/birthday-notify/internal/middlewares"
+	"github.com/ilya-burinskiy/birthday-notify/internal/oidc"
+	"github.com/ilya-burinskiy/birthday-notify/internal/services"
+	"go.uber.org/zap"
+)
+
+func (app *App) Router() chi.Router {
+	jwtManager := app.jwtManager
+	sessionSrv := services.NewSessionService(app.store, jwtManager, app.config.RefreshTokenExp)
+	apiKeySrv := services.NewAPIKeyService(app.store)
+	authenticate := middlewares.Authenticate(jwtManager, sessionSrv, apiKeySrv)
+	verificationSrv := services.NewEmailVerificationService(
+		app.store,
+		jwtManager,
+		app.mailer,
+		app.config.PublicURL,
+		app.config.EmailTokenExp,
+	)
+	passwordResetSrv := services.NewPasswordResetService(
+		app.logger,
+		app.store,
+		app.mailer,
+		app.background,
+		app.config.PasswordResetTokenExp,
+		app.passwords,
+	)
+	loginThrottleSrv := services.NewLoginThrottleService(
+		app.store,
+		app.config.LoginMaxAttempts,
+		app.config.LoginIPMaxAttempts,
+		app.config.LoginLockoutDuration,
+	)
+	registerSrv := services.NewRegisterService(app.logger, app.store, sessionSrv, verificationSrv, app.passwords)
+	profileSrv := services.NewProfileService(app.logger, app.store, verificationSrv, app.passwords, loginThrottleSrv)
+	accountSrv := services.NewAccountService(app.logger, app.store, app.photos, loginThrottleSrv)
+	photoSrv := services.NewPhotoService(app.store, app.photos, app.config.PhotoMaxSize)
+	twoFactorSrv := services.NewTwoFactorService(app.store, app.config.TOTPIssuer, loginThrottleSrv)
+	authSrv := services.NewAuthenticateService(app.store, sessionSrv, twoFactorSrv, jwtManager, loginThrottleSrv)
+	fetchUsersSrv := services.NewFetchUsersService(app.store)
+	subscribeSrv := services.NewSubscribeService(app.store)
+	unsubscribeSrv := services.NewUnsubscribeService(app.store, app.store)
+	notifySettingCreator := services.NewCreateNotificationSettingService(app.store)
+	notifySettingUpdator := services.NewUpdateNotificationService(app.store, services.NewAuthorizer(app.store))
+
+	router := chi.NewRouter()
+	if app.config.TrustProxy {
+		router.Use(middleware.RealIP)
+	}
+	configureUserRouter(app.logger, authenticate, registerSrv, authSrv, fetchUsersSrv, router)
+	configureSessionRouter(app.logger, authenticate, sessionSrv, router)
+	configureProfileRouter(app.logger, authenticate, profileSrv, accountSrv, photoSrv, router)
+	configureTwoFactorRouter(app.logger, authenticate, twoFactorSrv, router)
+	configureEmailVerificationRouter(app.logger, authenticate, verificationSrv, router)
+	configurePasswordResetRouter(app.logger, passwordResetSrv, router)
+	if app.config.OIDCIssuer != "" {
+		oidcClient := oidc.NewClient(
+			app.config.OIDCIssuer,
+			app.config.OIDCClientID,
+			app.config.OIDCClientSecret,
+			app.config.OIDCRedirect(),
+			app.config.OIDCScopes,
+		)
+		oidcSrv := services.NewOIDCLoginService(oidcClient, app.store, sessionSrv)
+		configureOIDCRouter(app.logger, oidcSrv, router)
+	}
+	configureAPIKeyRouter(app.logger, authenticate, apiKeySrv, router)
+	configureSubscriptionRouter(app.logger, authenticate, subscribeSrv, unsubscribeSrv, router)
+	configureNotificationSettingRouter(app.logger, authenticate, notifySettingCreator, notifySettingUpdator, router)
+	requireAdmin := middlewares.RequireAdmin(app.config.AdminToken, authenticate, app.store)
+	configureAdminRouter(app.logger, requireAdmin, app.notifier, loginThrottleSrv, app.Admin(), router)
+	configureJWKSRouter(app.logger, jwtManager, router)
+
+	return router
+}
+
+func configureUserRouter(
+	logger *zap.Logger,
+	authenticate func(http.Handler) http.Handler,
+	registerSrv services.RegisterService,
+	authSrv services.AuthenticateService,
+	fetchSrv services.FetchUsersService,
+	mainRouter chi.Router) {
+
+	handler := handlers.NewUserHandlers(logger)
+	mainRouter.Group(func(router chi.Router) {
+		router.Use(middleware.AllowContentType("application/json"))
+		router.Post("/api/users/register", handler.Register(registerSrv))
+		router.Post("/api/users/login", handler.Authenticate(authSrv))
+		router.Post("/api/users/login/2fa", handler.AuthenticateSecondFactor(authSrv))
+	})
+	mainRouter.With(authenticate, middlewares.RequireScope(auth.ScopeReadUsers)).
+		Get("/api/users", handler.Get(fetchSrv))
+}
+
+func configureSessionRouter(
+	logger *zap.Logger,
+	authenticate func(http.Handler) http.Handler,
+	sessionSrv services.SessionService,
+	mainRouter chi.Router) {
+
+	handler := handlers.NewSessionHandler(logger)
+	mainRouter.Post("/api/users/refresh", handler.Refresh(sessionSrv))
+	mainRouter.Group(func(router chi.Router) {
+		router.Use(authenticate, middlewares.RequireSession)
+		router.Post("/api/users/logout", handler.Logout(sessionSrv))
+		router.Post("/api/users/logout_all", handler.LogoutAll(sessionSrv))
+	})
+}
+
+func configureProfileRouter(
+	logger *zap.Logger,
+	authenticate func(http.Handler) http.Handler,
+	profileSrv handlers.ProfileService,
+	accountSrv handlers.AccountService,
+	photoSrv handlers.PhotoService,
+	mainRouter chi.Router) {
+
+	handler := handlers.NewProfileHandler(logger)
+	accountHandler := handlers.NewAccountHandler(logger)
+	photoHandler := handlers.NewPhotoHandler(logger)
+	mainRouter.With(authenticate, middlewares.RequireScope(auth.ScopeReadUsers)).
+		Get("/api/users/me", handler.Get(profileSrv))
+	mainRouter.Get("/api/users/{id}/photo", photoHandler.Get(photoSrv))
+	mainRouter.Group(func(router chi.Router) {
+		router.Use(authenticate, middlewares.RequireSession)
+		router.Put("/api/users/me/photo", photoHandler.Upload(photoSrv))
+		router.Delete("/api/users/me/photo", photoHandler.Delete(photoSrv))
+	})
+	mainRouter.Group(func(router chi.Router) {
+		// the email and the password can not be changed and the account can
+		// not be deleted or exported with an API key
+		router.Use(authenticate, middlewares.RequireSession)
+		router.Use(middleware.AllowContentType("application/json"))
+		router.Patch("/api/users/me", handler.Update(profileSrv))
+		router.Post("/api/users/me/email", handler.ChangeEmail(profileSrv))
+		router.Post("/api/users/me/password", handler.ChangePassword(profileSrv))
+		router.Delete("/api/users/me", accountHandler.Delete(accountSrv))
+		router.Get("/api/users/me/export", accountHandler.Export(accountSrv))
+	})
+}
+
+func configureTwoFactorRouter(
+	logger *zap.Logger,
+	authenticate func(http.Handler) http.Handler,
+	twoFactorSrv handlers.TwoFactorService,
+	mainRouter chi.Router) {
+
+	handler := handlers.NewTwoFactorHandler(logger)
+	mainRouter.Group(func(router chi.Router) {
+		router.Use(authenticate, middlewares.RequireSession)
+		router.Post("/api/users/2fa/enroll", handler.Enroll(twoFactorSrv))
+		router.Group(func(router chi.Router) {
+			router.Use(middleware.AllowContentType("application/json"))
+			router.Post("/api/users/2fa/confirm", handler.Confirm(twoFactorSrv))
+			router.Post("/api/users/2fa/disable", handler.Disable(twoFactorSrv))
+			router.Post("/api/users/2fa/recovery_codes", handler.RegenerateRecoveryCodes(twoFactorSrv))
+		})
+	})
+}
+
+func configureEmailVerificationRouter(
+	logger *zap.Logger,
+	authenticate func(http.Handler) http.Handler,
+	verificationSrv handlers.EmailVerificationService,
+	mainRouter chi.Router) {
+
+	handler := handlers.NewEmailVerificationHandler(logger)
+	// GET is used by the link in the email
+	mainRouter.Get("/api/users/verify_email", handler.Confirm(verificationSrv))
+	mainRouter.Post("/api/users/verify_email", handler.Confirm(verificationSrv))
+	mainRouter.With(authenticate, middlewares.RequireSession).
+		Post("/api/users/verify_email/resend", handler.Resend(verificationSrv))
+}
+
+func configurePasswordResetRouter(
+	logger *zap.Logger,
+	resetSrv handlers.PasswordResetService,
+	mainRouter chi.Router) {
+
+	handler := handlers.NewPasswordResetHandler(logger)
+	mainRouter.Group(func(router chi.Router) {
+		router.Use(middleware.AllowContentType("application/json"))
+		router.Post("/api/users/forgot_password", handler.Forgot(resetSrv))
+		router.Post("/api/users/reset_password", handler.Reset(resetSrv))
+	})
+}
+
+func configureOIDCRouter(logger *zap.Logger, loginSrv handlers.OIDCLoginService, mainRouter chi.Router) {
+	handler := handlers.NewOIDCHandler(logger)
+	mainRouter.Get("/api/auth/oidc/login", handler.Login(loginSrv))
+	mainRouter.Get("/api/auth/oidc/callback", handler.Callback(loginSrv))
+}
+
+func configureAPIKeyRouter(
+	logger *zap.Logger,
+	authenticate func(http.Handler) http.Handler,
+	apiKeySrv handlers.APIKeyService,
+	mainRouter chi.Router) {
+
+	handler := handlers.NewAPIKeyHandler(logger)
+	mainRouter.Group(func(router chi.Router) {
+		// API keys can not be used to manage API keys
+		router.Use(authenticate, middlewares.RequireSession)
+		router.With(middleware.AllowContentType("application/json")).
+			Post("/api/api_keys", handler.Create(apiKeySrv))
+		router.Get("/api/api_keys", handler.List(apiKeySrv))
+		router.Delete("/api/api_keys/{id}", handler.Revoke(apiKeySrv))
+	})
+}
+
+func configureSubscriptionRouter(
+	logger *zap.Logger,
+	authenticate func(http.Handler) http.Handler,
+	subscribeSrv services.SubscribeService,
+	unsubscribeSrv services.UnsubscribeService,
+	mainRouter chi.Router) {
+
+	handler := handlers.NewSubscriptionHandler(logger)
+	mainRouter.Group(func(router chi.Router) {
+		router.Use(authenticate, middlewares.RequireScope(auth.ScopeWriteSubscriptions))
+		router.Post("/api/users/{id}/subscribe", handler.Subscribe(subscribeSrv))
+		router.Delete("/api/users/{id}/unsubscribe", handler.Unsubscribe(unsubscribeSrv))
+	})
+}
+
+func configureNotificationSettingRouter(
+	logger *zap.Logger,
+	authenticate func(http.Handler) http.Handler,
+	createSrv services.CreateNotificationSettingService,
+	updateSrv services.UpdateNotificationSettingService,
+	mainRouter chi.Router) {
+
+	handler := handlers.NewNotificationSettingHandler(logger)
+	mainRouter.Group(func(router chi.Router) {
+		router.Use(authenticate, middlewares.RequireScope(auth.ScopeWriteNotifySettings))
+		router.Post("/api/notify_settings", handler.Create(createSrv))
+		router.Patch("/api/notify_settings/{id}", handler.Update(updateSrv))
+	})
+}
+
+func configureAdminRouter(
+	logger *zap.Logger,
+	requireAdmin func(http.Handler) http.Handler,
+	runSrv handlers.RunNotificationsService,
+	throttleSrv handlers.LoginThrottleService,
+	adminSrv handlers.AdminService,
+	mainRouter chi.Router) {
+
+	handler := handlers.NewNotificationHandler(logger)
+	throttleHandler := handlers.NewLoginThrottleHandler(logger)
+	adminHandler := handlers.NewAdminHandler(logger)
+	mainRouter.Group(func(router chi.Router) {
+		router.Use(requireAdmin)
+		router.Use(middleware.AllowContentType("application/json"))
+		router.Post("/api/admin/notifications/run", handler.Run(runSrv))
+		router.Post("/api/admin/users/{id}/unlock", throttleHandler.Unlock(throttleSrv))
+		router.Get("/api/admin/lockouts", throttleHandler.Lockouts(throttleSrv))
+
+		router.Get("/api/admin/users", adminHandler.ListUsers(adminSrv))
+		router.Get("/api/admin/users/{id}", adminHandler.GetUser(adminSrv))
+		// the admin token is not tied to a user, so it can neither be told
+		// apart from the target account nor be held accountable for it
+		router.With(middlewares.RequireSession).Delete("/api/admin/users/{id}", adminHandler.Delete(adminSrv))
+		router.With(middlewares.RequireSession).Put("/api/admin/users/{id}/role", adminHandler.SetRole(adminSrv))
+		router.With(middlewares.RequireSession).Post("/api/admin/users/{id}/disable", adminHandler.Disable(adminSrv))
+		router.With(middlewares.RequireSession).Post("/api/admin/users/{id}/enable", adminHandler.Enable(adminSrv))
+		router.Get("/api/admin/users/{id}/subscriptions", adminHandler.Subscriptions(adminSrv))
+		router.Get("/api/admin/users/{id}/notify_settings", adminHandler.NotificationSetting(adminSrv))
+		router.Put("/api/admin/users/{id}/notify_settings", adminHandler.SetNotificationSetting(adminSrv))
+	})
+}
+
+func configureJWKSRouter(logger *zap.Logger, provider handlers.JWKSProvider, mainRouter chi.Router) {
+	handler := handlers.NewJWKSHandler(logger)
+	mainRouter.Get("/.well-known/jwks.json", handler.Get(provider))
+}
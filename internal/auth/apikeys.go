@@ -0,0 +1,50 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"strings"
+)
+
+// Scopes limit what an API key may do. Requests authenticated with a session
+// are not limited.
+const (
+	ScopeReadUsers           = "read:users"
+	ScopeWriteSubscriptions  = "write:subscriptions"
+	ScopeWriteNotifySettings = "write:notify_settings"
+)
+
+// Scopes lists all scopes an API key can be granted.
+var Scopes = []string{ScopeReadUsers, ScopeWriteSubscriptions, ScopeWriteNotifySettings}
+
+// apiKeyPrefix tells API keys apart from JWTs in the Authorization header.
+const apiKeyPrefix = "bnk_"
+
+// GenerateAPIKey returns a random API key and the hash to store.
+func GenerateAPIKey() (string, []byte, error) {
+	token, err := randomToken()
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to generate api key: %w", err)
+	}
+	key := apiKeyPrefix + token
+
+	return key, HashAPIKey(key), nil
+}
+
+func HashAPIKey(key string) []byte {
+	hash := sha256.Sum256([]byte(key))
+	return hash[:]
+}
+
+func IsAPIKey(token string) bool {
+	return strings.HasPrefix(token, apiKeyPrefix)
+}
+
+func IsKnownScope(scope string) bool {
+	for _, known := range Scopes {
+		if scope == known {
+			return true
+		}
+	}
+	return false
+}
@@ -1,52 +1,175 @@
 package auth
 
 import (
+	"errors"
 	"fmt"
-	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/golang-jwt/jwt/v4"
-	"golang.org/x/crypto/bcrypt"
-
-	"github.com/ilya-burinskiy/birthday-notify/internal/configs"
 )
 
-func HashPassword(password string) ([]byte, error) {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+// JWTManager signs tokens with the primary (first) key and verifies them with
+// any of the keys, chosen by the "kid" header. Keeping the previous key in the
+// list after a rotation lets already issued tokens live until they expire.
+type JWTManager struct {
+	primary  SigningKey
+	keys     map[string]SigningKey
+	order    []string
+	issuer   string
+	audience string
+	tokenExp time.Duration
+}
+
+func NewJWTManager(keys []SigningKey, issuer, audience string, tokenExp time.Duration) JWTManager {
+	manager := JWTManager{
+		keys:     make(map[string]SigningKey, len(keys)),
+		issuer:   issuer,
+		audience: audience,
+		tokenExp: tokenExp,
+	}
+	for i, key := range keys {
+		if key.Method == nil {
+			key.Method = jwt.SigningMethodHS256
+		}
+		if i == 0 {
+			manager.primary = key
+		}
+		manager.keys[key.ID] = key
+		manager.order = append(manager.order, key.ID)
+	}
+
+	return manager
+}
+
+func (m JWTManager) BuildJWTString(userID, sessionID int) (string, error) {
+	claims := m.newClaims(userID, m.tokenExp)
+	claims.SessionID = sessionID
+	return m.sign(claims)
+}
+
+func (m JWTManager) ParseJWTString(tokenString string) (Claims, error) {
+	claims, err := m.parse(tokenString)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate hash from password: %w", err)
+		return claims, err
+	}
+	// action tokens are sent by email and must not grant access to the API
+	if claims.Purpose != "" {
+		return claims, errors.New("invalid token purpose")
 	}
 
-	return bytes, nil
+	return claims, nil
 }
 
-func ValidatePasswordHash(password, hash string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
-	return err == nil
+// BuildActionToken signs a single-purpose token, e.g. an email verification
+// link. The token is bound to the email so that it stops working once the
+// user changes it.
+func (m JWTManager) BuildActionToken(purpose string, userID int, email string, exp time.Duration) (string, error) {
+	claims := m.newClaims(userID, exp)
+	claims.Purpose = purpose
+	claims.Email = email
+	return m.sign(claims)
 }
 
-func BuildJWTString(userID int) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{
+func (m JWTManager) ParseActionToken(purpose, tokenString string) (Claims, error) {
+	claims, err := m.parse(tokenString)
+	if err != nil {
+		return claims, err
+	}
+	if claims.Purpose != purpose {
+		return claims, errors.New("invalid token purpose")
+	}
+
+	return claims, nil
+}
+
+func (m JWTManager) newClaims(userID int, exp time.Duration) Claims {
+	now := time.Now()
+	claims := Claims{
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(configs.AuthTokenExp)),
+			Subject:   strconv.Itoa(userID),
+			Issuer:    m.issuer,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(exp)),
 		},
 		UserID: userID,
-	})
-	tokenString, err := token.SignedString([]byte(configs.SecretKey))
+	}
+	if m.audience != "" {
+		claims.Audience = jwt.ClaimStrings{m.audience}
+	}
+	return claims
+}
+
+func (m JWTManager) sign(claims Claims) (string, error) {
+	if m.primary.Method == nil {
+		return "", errors.New("failed to sign token: no signing key configured")
+	}
+
+	token := jwt.NewWithClaims(m.primary.Method, claims)
+	token.Header["kid"] = m.primary.ID
+	tokenString, err := token.SignedString(m.primary.signKey())
 	if err != nil {
 		return "", fmt.Errorf("failed to sign token: %w", err)
 	}
 	return tokenString, nil
 }
 
-func SetJWTCookie(w http.ResponseWriter, token string) {
-	http.SetCookie(
-		w,
-		&http.Cookie{
-			Name:     "jwt",
-			Value:    token,
-			MaxAge:   int(configs.AuthTokenExp / time.Second),
-			HttpOnly: true,
-		},
-	)
+func (m JWTManager) parse(tokenString string) (Claims, error) {
+	claims := Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, &claims, m.keyFunc)
+	if err != nil {
+		return claims, fmt.Errorf("failed to parse token: %w", err)
+	}
+	if !token.Valid {
+		return claims, errors.New("invalid token")
+	}
+	// tokens issued before iss and aud were introduced do not carry them
+	if !claims.VerifyIssuer(m.issuer, false) {
+		return claims, errors.New("invalid token issuer")
+	}
+	if m.audience != "" && !claims.VerifyAudience(m.audience, false) {
+		return claims, errors.New("invalid token audience")
+	}
+	if claims.Subject != "" {
+		userID, err := strconv.Atoi(claims.Subject)
+		if err != nil {
+			return claims, errors.New("invalid token subject")
+		}
+		claims.UserID = userID
+	}
+
+	return claims, nil
+}
+
+// JWKS returns the public parts of asymmetric keys so that other services
+// can verify tokens. HMAC keys are never published.
+func (m JWTManager) JWKS() JWKSet {
+	set := JWKSet{Keys: []JWK{}}
+	for _, id := range m.order {
+		if jwk, ok := m.keys[id].jwk(); ok {
+			set.Keys = append(set.Keys, jwk)
+		}
+	}
+	return set
+}
+
+func (m JWTManager) keyFunc(token *jwt.Token) (interface{}, error) {
+	// tokens issued before key rotation was introduced carry no kid
+	key := m.primary
+	if kid, ok := token.Header["kid"].(string); ok {
+		key, ok = m.keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown signing key %q", kid)
+		}
+	}
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+	}
+
+	return key.verifyKey(), nil
+}
+
+// TokenExp is the lifetime of access tokens.
+func (m JWTManager) TokenExp() time.Duration {
+	return m.tokenExp
 }
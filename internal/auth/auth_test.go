@@ -0,0 +1,290 @@
+package auth_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/ilya-burinskiy/birthday-notify/internal/auth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestJWTManagerKeyRotation(t *testing.T) {
+	oldKey := auth.SigningKey{ID: "2024-01", Secret: []byte("old-secret")}
+	newKey := auth.SigningKey{ID: "2024-06", Secret: []byte("new-secret")}
+	beforeRotation := newJWTManager(time.Hour, oldKey)
+	duringRotation := newJWTManager(time.Hour, newKey, oldKey)
+	afterRotation := newJWTManager(time.Hour, newKey)
+
+	oldToken, err := beforeRotation.BuildJWTString(1, 1)
+	require.NoError(t, err)
+	newToken, err := duringRotation.BuildJWTString(2, 1)
+	require.NoError(t, err)
+
+	claims, err := duringRotation.ParseJWTString(oldToken)
+	require.NoError(t, err)
+	assert.Equal(t, 1, claims.UserID)
+
+	claims, err = afterRotation.ParseJWTString(newToken)
+	require.NoError(t, err)
+	assert.Equal(t, 2, claims.UserID)
+
+	_, err = afterRotation.ParseJWTString(oldToken)
+	assert.ErrorContains(t, err, `unknown signing key "2024-01"`)
+}
+
+func TestJWTManagerRejectsExpiredToken(t *testing.T) {
+	manager := newJWTManager(-time.Minute, auth.SigningKey{ID: "key", Secret: []byte("secret")})
+	token, err := manager.BuildJWTString(1, 1)
+	require.NoError(t, err)
+
+	_, err = manager.ParseJWTString(token)
+	assert.ErrorContains(t, err, "token is expired")
+}
+
+func TestJWTManagerAsymmetricKeys(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name      string
+		algorithm string
+		key       interface{}
+		kty       string
+	}{
+		{name: "RS256", algorithm: auth.AlgorithmRS256, key: rsaKey, kty: "RSA"},
+		{name: "EdDSA", algorithm: auth.AlgorithmEdDSA, key: edKey, kty: "OKP"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			der, err := x509.MarshalPKCS8PrivateKey(tc.key)
+			require.NoError(t, err)
+			key, err := auth.NewSigningKey("key-1", tc.algorithm, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+			require.NoError(t, err)
+			manager := newJWTManager(time.Hour, key)
+
+			tokenString, err := manager.BuildJWTString(42, 1)
+			require.NoError(t, err)
+
+			claims, err := manager.ParseJWTString(tokenString)
+			require.NoError(t, err)
+			assert.Equal(t, 42, claims.UserID)
+			assert.Equal(t, "42", claims.Subject)
+			assert.Equal(t, "birthday-notify", claims.Issuer)
+			assert.Equal(t, jwt.ClaimStrings{"other-service"}, claims.Audience)
+			assert.NotNil(t, claims.IssuedAt)
+
+			jwks := manager.JWKS()
+			require.Len(t, jwks.Keys, 1)
+			assert.Equal(t, "key-1", jwks.Keys[0].KeyID)
+			assert.Equal(t, tc.algorithm, jwks.Keys[0].Algorithm)
+			assert.Equal(t, tc.kty, jwks.Keys[0].KeyType)
+		})
+	}
+}
+
+func TestJWTManagerRejectsAlgorithmMismatch(t *testing.T) {
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	manager := newJWTManager(time.Hour, auth.SigningKey{ID: "key", Method: jwt.SigningMethodEdDSA, PrivateKey: edKey})
+
+	// an HS256 token "signed" with the public key must not verify
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, auth.Claims{UserID: 1})
+	token.Header["kid"] = "key"
+	tokenString, err := token.SignedString([]byte(edKey.Public().(ed25519.PublicKey)))
+	require.NoError(t, err)
+
+	_, err = manager.ParseJWTString(tokenString)
+	assert.ErrorContains(t, err, "unexpected signing method")
+}
+
+func TestJWTManagerDoesNotPublishHMACKeys(t *testing.T) {
+	manager := newJWTManager(time.Hour, auth.SigningKey{ID: "key", Secret: []byte("secret")})
+	assert.Empty(t, manager.JWKS().Keys)
+}
+
+func TestJWTManagerActionTokens(t *testing.T) {
+	manager := newJWTManager(time.Hour, auth.SigningKey{ID: "key", Secret: []byte("secret")})
+	token, err := manager.BuildActionToken("email_verification", 1, "email@example.com", time.Hour)
+	require.NoError(t, err)
+
+	claims, err := manager.ParseActionToken("email_verification", token)
+	require.NoError(t, err)
+	assert.Equal(t, 1, claims.UserID)
+	assert.Equal(t, "email@example.com", claims.Email)
+
+	_, err = manager.ParseActionToken("password_reset", token)
+	assert.ErrorContains(t, err, "invalid token purpose")
+	_, err = manager.ParseJWTString(token)
+	assert.ErrorContains(t, err, "invalid token purpose")
+
+	accessToken, err := manager.BuildJWTString(1, 1)
+	require.NoError(t, err)
+	_, err = manager.ParseActionToken("email_verification", accessToken)
+	assert.ErrorContains(t, err, "invalid token purpose")
+}
+
+func TestValidateTOTP(t *testing.T) {
+	// test vectors from RFC 6238, truncated to 6 digits
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	testCases := []struct {
+		name     string
+		code     string
+		at       time.Time
+		wantStep int64
+		wantOK   bool
+	}{
+		{name: "current period", code: "287082", at: time.Unix(59, 0), wantStep: 1, wantOK: true},
+		{name: "other vector", code: "081804", at: time.Unix(1111111109, 0), wantStep: 37037036, wantOK: true},
+		{name: "previous period", code: "081804", at: time.Unix(1111111109+30, 0), wantStep: 37037036, wantOK: true},
+		{name: "too old", code: "081804", at: time.Unix(1111111109+60, 0)},
+		{name: "wrong code", code: "123456", at: time.Unix(59, 0)},
+		{name: "wrong length", code: "94287082", at: time.Unix(59, 0)},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			step, ok := auth.ValidateTOTP(secret, tc.code, tc.at)
+			assert.Equal(t, tc.wantOK, ok)
+			assert.Equal(t, tc.wantStep, step)
+		})
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, hashes, err := auth.GenerateRecoveryCodes(10)
+	require.NoError(t, err)
+	require.Len(t, codes, 10)
+	assert.Equal(t, hashes[0], auth.HashRecoveryCode(strings.ToUpper(codes[0])))
+	assert.Equal(t, hashes[0], auth.HashRecoveryCode(strings.ReplaceAll(codes[0], "-", "")))
+	assert.NotEqual(t, hashes[0], hashes[1])
+}
+
+func TestPasswordHash(t *testing.T) {
+	longPassword := strings.Repeat("a", 72)
+	hash, err := auth.HashPassword(longPassword + "b")
+	require.NoError(t, err)
+
+	assert.True(t, strings.HasPrefix(string(hash), "$argon2id$v=19$m=65536,t=3,p=2$"))
+	assert.True(t, auth.ValidatePasswordHash(longPassword+"b", string(hash)))
+	assert.False(t, auth.ValidatePasswordHash(longPassword, string(hash)), "long passwords must not be truncated")
+	assert.False(t, auth.PasswordNeedsRehash(string(hash)))
+
+	bcryptHash, err := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	require.NoError(t, err)
+	assert.True(t, auth.ValidatePasswordHash("password", string(bcryptHash)))
+	assert.False(t, auth.ValidatePasswordHash("wrong", string(bcryptHash)))
+	assert.True(t, auth.PasswordNeedsRehash(string(bcryptHash)))
+
+	weakHash := strings.Replace(string(hash), "t=3", "t=1", 1)
+	assert.True(t, auth.PasswordNeedsRehash(weakHash))
+	assert.False(t, auth.ValidatePasswordHash(longPassword+"b", weakHash))
+
+	assert.False(t, auth.ValidatePasswordHash("", ""))
+	assert.False(t, auth.PasswordNeedsRehash(""))
+}
+
+func TestPasswordPolicy(t *testing.T) {
+	policy := auth.PasswordPolicy{MinLength: 8, Breached: auth.DefaultBreachedPasswords()}
+
+	violations, err := policy.Check("correct horse battery")
+	require.NoError(t, err)
+	assert.Empty(t, violations)
+
+	violations, err = policy.Check("password1")
+	require.NoError(t, err)
+	require.Len(t, violations, 1)
+	assert.Equal(t, auth.PasswordBreached, violations[0].Code)
+
+	violations, err = policy.Check("пароль")
+	require.NoError(t, err)
+	require.Len(t, violations, 1)
+	assert.Equal(t, auth.PasswordTooShort, violations[0].Code, "length is counted in characters")
+}
+
+func TestLoadBreachedPasswords(t *testing.T) {
+	sum := sha1.Sum([]byte("correct horse battery"))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	dir := t.TempDir()
+
+	listFile := filepath.Join(dir, "list.txt")
+	require.NoError(t, os.WriteFile(listFile, []byte("# comment\n"+strings.ToLower(hash)+":42\n"), 0o600))
+	list, err := auth.LoadBreachedPasswords(listFile)
+	require.NoError(t, err)
+	assertBreached(t, list, "correct horse battery", true)
+	assertBreached(t, list, "password1", false)
+
+	rangesDir := filepath.Join(dir, "ranges")
+	require.NoError(t, os.Mkdir(rangesDir, 0o700))
+	require.NoError(t, os.WriteFile(filepath.Join(rangesDir, hash[:5]), []byte(hash[5:]+":42\r\n"), 0o600))
+	ranges, err := auth.LoadBreachedPasswords(rangesDir)
+	require.NoError(t, err)
+	assertBreached(t, ranges, "correct horse battery", true)
+	assertBreached(t, ranges, "password1", false)
+
+	invalidFile := filepath.Join(dir, "invalid.txt")
+	require.NoError(t, os.WriteFile(invalidFile, []byte("not-a-hash\n"), 0o600))
+	_, err = auth.LoadBreachedPasswords(invalidFile)
+	assert.ErrorContains(t, err, "line 1: not a SHA-1 hash")
+}
+
+func assertBreached(t *testing.T, list *auth.BreachedPasswords, password string, want bool) {
+	breached, err := list.Contains(password)
+	require.NoError(t, err)
+	assert.Equal(t, want, breached, password)
+}
+
+func TestAccessTokenFromRequest(t *testing.T) {
+	testCases := []struct {
+		name      string
+		header    string
+		cookie    string
+		wantToken string
+		wantOK    bool
+	}{
+		{name: "bearer header", header: "Bearer header-token", wantToken: "header-token", wantOK: true},
+		{name: "case insensitive scheme", header: "bearer header-token", wantToken: "header-token", wantOK: true},
+		{name: "cookie", cookie: "cookie-token", wantToken: "cookie-token", wantOK: true},
+		{name: "header takes precedence", header: "Bearer header-token", cookie: "cookie-token", wantToken: "header-token", wantOK: true},
+		{name: "other scheme", header: "Basic dXNlcjpwd2Q=", cookie: "cookie-token"},
+		{name: "empty bearer", header: "Bearer "},
+		{name: "no token"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tc.header != "" {
+				r.Header.Set("Authorization", tc.header)
+			}
+			if tc.cookie != "" {
+				r.AddCookie(&http.Cookie{Name: "jwt", Value: tc.cookie})
+			}
+
+			token, ok := auth.AccessTokenFromRequest(r)
+			assert.Equal(t, tc.wantOK, ok)
+			assert.Equal(t, tc.wantToken, token)
+		})
+	}
+}
+
+func newJWTManager(tokenExp time.Duration, keys ...auth.SigningKey) auth.JWTManager {
+	return auth.NewJWTManager(keys, "birthday-notify", "other-service", tokenExp)
+}
@@ -2,7 +2,13 @@ package auth
 
 import "github.com/golang-jwt/jwt/v4"
 
+// Claims carries the user id in the standard "sub" claim. UserID duplicates
+// it for tokens issued before "sub" was introduced. Purpose and Email are set
+// only in action tokens.
 type Claims struct {
 	jwt.RegisteredClaims
-	UserID int
+	UserID    int
+	SessionID int    `json:"sid,omitempty"`
+	Purpose   string `json:"purpose,omitempty"`
+	Email     string `json:"email,omitempty"`
 }
@@ -0,0 +1,135 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	AlgorithmHS256 = "HS256"
+	AlgorithmRS256 = "RS256"
+	AlgorithmEdDSA = "EdDSA"
+)
+
+// SigningKey is either an HMAC secret or an asymmetric private key.
+type SigningKey struct {
+	ID         string
+	Method     jwt.SigningMethod
+	Secret     []byte
+	PrivateKey crypto.Signer
+}
+
+// NewSigningKey builds a key for algorithm. For HS256 material is the shared
+// secret, for RS256 and EdDSA it is a PEM encoded PKCS#8 (or PKCS#1 for RSA)
+// private key.
+func NewSigningKey(id, algorithm string, material []byte) (SigningKey, error) {
+	key := SigningKey{ID: id}
+	switch algorithm {
+	case AlgorithmHS256, "":
+		if len(material) == 0 {
+			return key, fmt.Errorf("key %q: empty secret", id)
+		}
+		key.Method = jwt.SigningMethodHS256
+		key.Secret = material
+	case AlgorithmRS256:
+		privateKey, err := parsePrivateKey(material)
+		if err != nil {
+			return key, fmt.Errorf("key %q: %w", id, err)
+		}
+		rsaKey, ok := privateKey.(*rsa.PrivateKey)
+		if !ok {
+			return key, fmt.Errorf("key %q: RS256 requires an RSA private key", id)
+		}
+		key.Method = jwt.SigningMethodRS256
+		key.PrivateKey = rsaKey
+	case AlgorithmEdDSA:
+		privateKey, err := parsePrivateKey(material)
+		if err != nil {
+			return key, fmt.Errorf("key %q: %w", id, err)
+		}
+		edKey, ok := privateKey.(ed25519.PrivateKey)
+		if !ok {
+			return key, fmt.Errorf("key %q: EdDSA requires an Ed25519 private key", id)
+		}
+		key.Method = jwt.SigningMethodEdDSA
+		key.PrivateKey = edKey
+	default:
+		return key, fmt.Errorf("key %q: unsupported algorithm %q", id, algorithm)
+	}
+
+	return key, nil
+}
+
+func (key SigningKey) signKey() interface{} {
+	if key.PrivateKey != nil {
+		return key.PrivateKey
+	}
+	return key.Secret
+}
+
+func (key SigningKey) verifyKey() interface{} {
+	if key.PrivateKey != nil {
+		return key.PrivateKey.Public()
+	}
+	return key.Secret
+}
+
+func parsePrivateKey(material []byte) (interface{}, error) {
+	block, _ := pem.Decode(material)
+	if block == nil {
+		return nil, errors.New("private key is not PEM encoded")
+	}
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	return nil, errors.New("unsupported private key format, expected PKCS#8 or PKCS#1")
+}
+
+// JWK is a public key in JSON Web Key format (RFC 7517).
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+}
+
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+func (key SigningKey) jwk() (JWK, bool) {
+	if key.PrivateKey == nil {
+		return JWK{}, false
+	}
+
+	jwk := JWK{KeyID: key.ID, Use: "sig", Algorithm: key.Method.Alg()}
+	switch pub := key.PrivateKey.Public().(type) {
+	case *rsa.PublicKey:
+		jwk.KeyType = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk.KeyType = "OKP"
+		jwk.Curve = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+	default:
+		return JWK{}, false
+	}
+	return jwk, true
+}
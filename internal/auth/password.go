@@ -0,0 +1,112 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Argon2id parameters for new hashes. They are encoded into every hash, so
+// they can be raised later: old hashes keep verifying and are replaced on
+// the next login (see PasswordNeedsRehash).
+const (
+	argon2Memory  = 64 * 1024 // KiB
+	argon2Time    = 3
+	argon2Threads = 2
+	argon2SaltLen = 16
+	argon2KeyLen  = 32
+)
+
+const argon2Prefix = "$argon2id$"
+
+var argon2Encoding = base64.RawStdEncoding
+
+type argon2Params struct {
+	memory  uint32
+	time    uint32
+	threads uint8
+}
+
+var currentArgon2Params = argon2Params{memory: argon2Memory, time: argon2Time, threads: argon2Threads}
+
+// HashPassword returns an Argon2id hash in the PHC string format:
+// $argon2id$v=19$m=65536,t=3,p=2$<salt>$<hash>.
+func HashPassword(password string) ([]byte, error) {
+	salt := make([]byte, argon2SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("failed to generate hash from password: %w", err)
+	}
+
+	p := currentArgon2Params
+	key := argon2.IDKey([]byte(password), salt, p.time, p.memory, p.threads, argon2KeyLen)
+	encoded := fmt.Sprintf(
+		"%sv=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2Prefix,
+		argon2.Version,
+		p.memory,
+		p.time,
+		p.threads,
+		argon2Encoding.EncodeToString(salt),
+		argon2Encoding.EncodeToString(key),
+	)
+	return []byte(encoded), nil
+}
+
+// ValidatePasswordHash accepts Argon2id hashes and bcrypt hashes created
+// before Argon2id was introduced.
+func ValidatePasswordHash(password, hash string) bool {
+	if !strings.HasPrefix(hash, argon2Prefix) {
+		err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+		return err == nil
+	}
+
+	p, salt, key, err := decodeArgon2Hash(hash)
+	if err != nil {
+		return false
+	}
+	actual := argon2.IDKey([]byte(password), salt, p.time, p.memory, p.threads, uint32(len(key)))
+	return subtle.ConstantTimeCompare(actual, key) == 1
+}
+
+// PasswordNeedsRehash reports whether the hash was created with another
+// algorithm or with other parameters than HashPassword uses now. Empty hashes
+// of users without a password never need a rehash.
+func PasswordNeedsRehash(hash string) bool {
+	if hash == "" {
+		return false
+	}
+	p, _, _, err := decodeArgon2Hash(hash)
+	return err != nil || p != currentArgon2Params
+}
+
+func decodeArgon2Hash(hash string) (argon2Params, []byte, []byte, error) {
+	var p argon2Params
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return p, nil, nil, fmt.Errorf("not an argon2id hash")
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return p, nil, nil, fmt.Errorf("unsupported argon2 version %q", parts[2])
+	}
+	_, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.memory, &p.time, &p.threads)
+	if err != nil || p.time == 0 || p.threads == 0 {
+		return p, nil, nil, fmt.Errorf("invalid argon2 parameters %q", parts[3])
+	}
+	salt, err := argon2Encoding.DecodeString(parts[4])
+	if err != nil {
+		return p, nil, nil, fmt.Errorf("invalid argon2 salt: %w", err)
+	}
+	key, err := argon2Encoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return p, nil, nil, fmt.Errorf("invalid argon2 hash")
+	}
+
+	return p, salt, key, nil
+}
@@ -0,0 +1,179 @@
+package auth
+
+import (
+	"bufio"
+	"crypto/sha1"
+	_ "embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// Codes of password policy violations.
+const (
+	PasswordTooShort = "too_short"
+	PasswordBreached = "breached"
+)
+
+// The hash is split into the prefix used for the lookup and the suffix
+// matched against the lines of a range, like the k-anonymity API of Have I
+// Been Pwned does.
+const (
+	hashPrefixLen = 5
+	sha1HexLen    = 40
+)
+
+//go:embed breached_passwords.txt
+var bundledBreachedPasswords string
+
+// PasswordViolation describes why a password was rejected.
+type PasswordViolation struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// PasswordPolicy checks new passwords. Breached is optional.
+type PasswordPolicy struct {
+	MinLength int
+	Breached  *BreachedPasswords
+}
+
+// Check returns every violation of the policy, nil for an acceptable
+// password. The length is counted in characters, not bytes.
+func (p PasswordPolicy) Check(password string) ([]PasswordViolation, error) {
+	var violations []PasswordViolation
+	if utf8.RuneCountInString(password) < p.MinLength {
+		violations = append(violations, PasswordViolation{
+			Code:    PasswordTooShort,
+			Message: fmt.Sprintf("password must be at least %d characters long", p.MinLength),
+		})
+	}
+
+	if p.Breached != nil {
+		breached, err := p.Breached.Contains(password)
+		if err != nil {
+			return nil, err
+		}
+		if breached {
+			violations = append(violations, PasswordViolation{
+				Code:    PasswordBreached,
+				Message: "password appeared in a data breach, choose another one",
+			})
+		}
+	}
+
+	return violations, nil
+}
+
+// BreachedPasswords is a list of SHA-1 hashes of leaked passwords. A list
+// is either loaded into memory from one file or kept on disk as a directory
+// of range files: the file named after the first 5 hex digits of a hash
+// contains the remaining 35 digits of every hash with that prefix, the same
+// layout the Have I Been Pwned range API returns. Only the range of the
+// checked password is read then, so the full list does not have to fit in
+// memory.
+type BreachedPasswords struct {
+	ranges map[string]map[string]struct{}
+	dir    string
+}
+
+// DefaultBreachedPasswords returns the bundled list of common passwords.
+func DefaultBreachedPasswords() *BreachedPasswords {
+	list, err := readBreachedPasswords(strings.NewReader(bundledBreachedPasswords))
+	if err != nil {
+		panic(fmt.Sprintf("invalid bundled breached passwords: %s", err))
+	}
+	return list
+}
+
+// LoadBreachedPasswords reads a file with one hash per line or opens a
+// directory of range files.
+func LoadBreachedPasswords(path string) (*BreachedPasswords, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load breached passwords: %w", err)
+	}
+	if info.IsDir() {
+		return &BreachedPasswords{dir: path}, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load breached passwords: %w", err)
+	}
+	defer file.Close()
+
+	list, err := readBreachedPasswords(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load breached passwords from %s: %w", path, err)
+	}
+	return list, nil
+}
+
+func (b *BreachedPasswords) Contains(password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := hash[:hashPrefixLen], hash[hashPrefixLen:]
+
+	if b.dir == "" {
+		_, ok := b.ranges[prefix][suffix]
+		return ok, nil
+	}
+
+	file, err := os.Open(filepath.Join(b.dir, prefix))
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to check breached passwords: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if rangeSuffix, _, _ := strings.Cut(scanner.Text(), ":"); strings.EqualFold(strings.TrimSpace(rangeSuffix), suffix) {
+			return true, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return false, fmt.Errorf("failed to check breached passwords: %w", err)
+	}
+	return false, nil
+}
+
+// readBreachedPasswords parses lines "HASH" or "HASH:COUNT". Empty lines and
+// lines starting with '#' are skipped.
+func readBreachedPasswords(r io.Reader) (*BreachedPasswords, error) {
+	list := &BreachedPasswords{ranges: make(map[string]map[string]struct{})}
+	scanner := bufio.NewScanner(r)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		hash, _, _ := strings.Cut(line, ":")
+		hash = strings.ToUpper(hash)
+		if _, err := hex.DecodeString(hash); err != nil || len(hash) != sha1HexLen {
+			return nil, fmt.Errorf("line %d: not a SHA-1 hash", lineNum)
+		}
+
+		prefix, suffix := hash[:hashPrefixLen], hash[hashPrefixLen:]
+		if list.ranges[prefix] == nil {
+			list.ranges[prefix] = make(map[string]struct{})
+		}
+		list.ranges[prefix][suffix] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
@@ -0,0 +1,151 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	accessTokenCookie  = "jwt"
+	refreshTokenCookie = "refresh_token"
+	// refresh token cookie is only sent to the refresh and logout endpoints
+	refreshTokenCookiePath = "/api/users"
+	// returnTokenParam asks login, register and refresh endpoints to
+	// return the tokens in the response body
+	returnTokenParam = "return_token"
+)
+
+// TokenPair is a short-lived access token (JWT) and an opaque refresh token
+// that can be exchanged for a new pair once.
+type TokenPair struct {
+	AccessToken     string        `json:"access_token"`
+	AccessTokenExp  time.Duration `json:"-"`
+	RefreshToken    string        `json:"refresh_token"`
+	RefreshTokenExp time.Duration `json:"-"`
+}
+
+// TokenResponse is the JSON representation of a TokenPair for clients that
+// do not use cookies.
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+func NewTokenResponse(tokens TokenPair) TokenResponse {
+	return TokenResponse{
+		AccessToken:  tokens.AccessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(tokens.AccessTokenExp / time.Second),
+		RefreshToken: tokens.RefreshToken,
+	}
+}
+
+// GenerateRefreshToken returns a random token and the hash to store.
+func GenerateRefreshToken() (string, []byte, error) {
+	token, err := randomToken()
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to generate refresh token: %w", err)
+	}
+
+	return token, HashRefreshToken(token), nil
+}
+
+func HashRefreshToken(token string) []byte {
+	hash := sha256.Sum256([]byte(token))
+	return hash[:]
+}
+
+// GeneratePasswordResetToken returns a random token and the hash to store.
+func GeneratePasswordResetToken() (string, []byte, error) {
+	token, err := randomToken()
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to generate password reset token: %w", err)
+	}
+
+	return token, HashPasswordResetToken(token), nil
+}
+
+func HashPasswordResetToken(token string) []byte {
+	hash := sha256.Sum256([]byte(token))
+	return hash[:]
+}
+
+func randomToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func SetAuthCookies(w http.ResponseWriter, tokens TokenPair) {
+	http.SetCookie(
+		w,
+		&http.Cookie{
+			Name:     accessTokenCookie,
+			Value:    tokens.AccessToken,
+			Path:     "/",
+			MaxAge:   int(tokens.AccessTokenExp / time.Second),
+			HttpOnly: true,
+		},
+	)
+	http.SetCookie(
+		w,
+		&http.Cookie{
+			Name:     refreshTokenCookie,
+			Value:    tokens.RefreshToken,
+			Path:     refreshTokenCookiePath,
+			MaxAge:   int(tokens.RefreshTokenExp / time.Second),
+			HttpOnly: true,
+			SameSite: http.SameSiteStrictMode,
+		},
+	)
+}
+
+func ClearAuthCookies(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{Name: accessTokenCookie, Path: "/", MaxAge: -1, HttpOnly: true})
+	http.SetCookie(w, &http.Cookie{Name: refreshTokenCookie, Path: refreshTokenCookiePath, MaxAge: -1, HttpOnly: true})
+}
+
+// AccessTokenFromRequest returns the access token from the
+// "Authorization: Bearer" header or, if there is no such header, from the
+// jwt cookie.
+func AccessTokenFromRequest(r *http.Request) (string, bool) {
+	if header := r.Header.Get("Authorization"); header != "" {
+		scheme, token, found := strings.Cut(header, " ")
+		if !found || !strings.EqualFold(scheme, "Bearer") {
+			return "", false
+		}
+		token = strings.TrimSpace(token)
+		return token, token != ""
+	}
+
+	cookie, err := r.Cookie(accessTokenCookie)
+	if err != nil {
+		return "", false
+	}
+	return cookie.Value, true
+}
+
+// WantsTokenInBody reports whether the client asked to receive the tokens
+// in the response body with ?return_token=true.
+func WantsTokenInBody(r *http.Request) bool {
+	wants, err := strconv.ParseBool(r.URL.Query().Get(returnTokenParam))
+	return err == nil && wants
+}
+
+func RefreshTokenFromCookie(r *http.Request) (string, bool) {
+	cookie, err := r.Cookie(refreshTokenCookie)
+	if err != nil {
+		return "", false
+	}
+	return cookie.Value, true
+}
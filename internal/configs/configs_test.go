@@ -0,0 +1,145 @@
+package configs_test
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ilya-burinskiy/birthday-notify/internal/configs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "config.yaml")
+	writeFile(t, configFile, `
+run_address: "0.0.0.0:9000"
+auth_token_exp: 1h
+smtp_host: smtp.example.com
+smtp_port: "25"
+mail_transports: [http, smtp]
+mail_http_url: https://mail.example.com/send
+`)
+	secretFile := filepath.Join(dir, "secret")
+	writeFile(t, secretFile, "file-secret\n")
+
+	t.Setenv("CONFIG_FILE", configFile)
+	t.Setenv("SECRET_KEY_FILE", secretFile)
+	t.Setenv("AUTH_TOKEN_EXP", "2h")
+	t.Setenv("SMTP_PORT", "587")
+
+	config, err := configs.Load(flag.NewFlagSet("test", flag.ContinueOnError), []string{"-smtp-port", "2525"})
+	require.NoError(t, err)
+
+	assert.Equal(t, "0.0.0.0:9000", config.RunAddr)
+	assert.Equal(t, "file-secret", config.SecretKey)
+	assert.Equal(t, 2*time.Hour, config.AuthTokenExp)
+	assert.Equal(t, "smtp.example.com", config.SMTPHost)
+	assert.Equal(t, "2525", config.SMTPPort)
+	assert.Equal(t, []string{"http", "smtp"}, config.MailTransports)
+	assert.Equal(t, configs.Default().DSN, config.DSN)
+}
+
+func TestLoadTOML(t *testing.T) {
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "config.toml")
+	writeFile(t, configFile, `
+run_address = "0.0.0.0:9000"
+secret_key = "file-secret"
+auth_token_exp = "1h"
+mail_sender = "stdout"
+oidc_scopes = ["openid", "email"]
+
+[[jwt_keys]]
+id = "k1"
+algorithm = "HS256"
+secret = "jwt-secret"
+`)
+	t.Setenv("CONFIG_FILE", configFile)
+
+	config, err := configs.Load(flag.NewFlagSet("test", flag.ContinueOnError), nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, "0.0.0.0:9000", config.RunAddr)
+	assert.Equal(t, "file-secret", config.SecretKey)
+	assert.Equal(t, time.Hour, config.AuthTokenExp)
+	assert.Equal(t, []string{"openid", "email"}, config.OIDCScopes)
+	assert.Equal(t, []configs.JWTKey{{ID: "k1", Algorithm: "HS256", Secret: "jwt-secret"}}, config.JWTKeys)
+}
+
+func TestLoadErrors(t *testing.T) {
+	dir := t.TempDir()
+	testCases := []struct {
+		name   string
+		file   string
+		ext    string
+		env    map[string]string
+		errMsg string
+	}{
+		{
+			name: "rejects unknown config file keys",
+			file: "unknown_key: 1\n",
+			env:  map[string]string{"MAIL_SENDER": "stdout"},
+			errMsg: "config file " + filepath.Join(dir, "rejects unknown config file keys.yaml") +
+				": yaml: unmarshal errors:\n  line 1: field unknown_key not found in type configs.Config",
+		},
+		{
+			name:   "rejects unknown toml config file keys",
+			file:   "unknown_key = 1\n",
+			ext:    ".toml",
+			env:    map[string]string{"MAIL_SENDER": "stdout"},
+			errMsg: "config file " + filepath.Join(dir, "rejects unknown toml config file keys.toml") + ": toml: unknown keys unknown_key",
+		},
+		{
+			name:   "rejects malformed values",
+			env:    map[string]string{"MAIL_SENDER": "stdout", "AUTH_TOKEN_EXP": "day"},
+			errMsg: `AUTH_TOKEN_EXP: time: invalid duration "day"`,
+		},
+		{
+			name: "rejects both value and file",
+			env: map[string]string{
+				"MAIL_SENDER":     "stdout",
+				"SECRET_KEY":      "secret",
+				"SECRET_KEY_FILE": "/run/secrets/key",
+			},
+			errMsg: "both SECRET_KEY and SECRET_KEY_FILE are set",
+		},
+		{
+			name:   "rejects placeholder secret key",
+			env:    map[string]string{"MAIL_SENDER": "stdout", "SECRET_KEY": "secret"},
+			errMsg: `secret_key: "secret" is a well-known placeholder, generate a random key`,
+		},
+		{
+			name:   "reports all invalid fields",
+			env:    map[string]string{"RUN_ADDRESS": "8000", "MAIL_TRANSPORTS": "smtp,pigeon"},
+			errMsg: "run_address: \"8000\" is not a host:port address\nsecret_key: required when jwt_keys is not set\nsmtp_host, smtp_port: required for smtp mail transport\nmail_transports: unknown transport \"pigeon\"",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for key, value := range tc.env {
+				t.Setenv(key, value)
+			}
+			if tc.file != "" {
+				ext := tc.ext
+				if ext == "" {
+					ext = ".yaml"
+				}
+				path := filepath.Join(dir, tc.name+ext)
+				writeFile(t, path, tc.file)
+				t.Setenv("CONFIG_FILE", path)
+			}
+
+			_, err := configs.Load(flag.NewFlagSet("test", flag.ContinueOnError), nil)
+			assert.EqualError(t, err, tc.errMsg)
+		})
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+}
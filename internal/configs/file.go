@@ -0,0 +1,63 @@
+package configs
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+func loadFile(config *Config, path string) error {
+	decode := decodeYAML
+	switch ext := filepath.Ext(path); ext {
+	case ".yaml", ".yml", ".json":
+	case ".toml":
+		decode = decodeTOML
+	default:
+		return fmt.Errorf("config file %s: unsupported format %q, expected .yaml, .yml, .json or .toml", path, ext)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open config file: %w", err)
+	}
+	defer file.Close()
+
+	if err := decode(file, config); err != nil {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// decodeYAML also reads JSON, which is a subset of YAML.
+func decodeYAML(r io.Reader, config *Config) error {
+	decoder := yaml.NewDecoder(r)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(config); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
+
+// decodeTOML rejects unknown keys like decodeYAML does, so that a typo does
+// not silently leave the default in place.
+func decodeTOML(r io.Reader, config *Config) error {
+	meta, err := toml.NewDecoder(r).Decode(config)
+	if err != nil {
+		return err
+	}
+	if undecoded := meta.Undecoded(); len(undecoded) > 0 {
+		keys := make([]string, len(undecoded))
+		for i, key := range undecoded {
+			keys[i] = key.String()
+		}
+		return fmt.Errorf("toml: unknown keys %s", strings.Join(keys, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,201 @@
+package configs
+
+import (
+	"errors"
+	"flag"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// option binds a config field to its environment variable and, optionally,
+// a command line flag. Secrets have no flag so that they never show up in
+// the process list.
+type option struct {
+	env   string
+	flag  string
+	usage string
+	value flag.Value
+}
+
+func options(c *Config) []option {
+	return []option{
+		{"RUN_ADDRESS", "run-address", "HTTP listen address", (*stringValue)(&c.RunAddr)},
+		{"DATABASE_URI", "database-uri", "PostgreSQL connection string", (*stringValue)(&c.DSN)},
+		{"SHUTDOWN_TIMEOUT", "shutdown-timeout", "graceful shutdown timeout", (*durationValue)(&c.ShutdownTimeout)},
+		{"LOG_LEVEL", "log-level", "log level", (*stringValue)(&c.LogLevel)},
+		{"PUBLIC_URL", "public-url", "public service URL for links in emails", (*stringValue)(&c.PublicURL)},
+		{"ADMIN_TOKEN", "", "admin endpoints token", (*stringValue)(&c.AdminToken)},
+		{"SECRET_KEY", "", "JWT signing key", (*stringValue)(&c.SecretKey)},
+		{"JWT_KEYS", "", "comma-separated JWT signing keys as id:secret or id:algorithm:key", (*jwtKeysValue)(&c.JWTKeys)},
+		{"JWT_PRIMARY_KEY_ID", "jwt-primary-key-id", "id of the key that signs new tokens", (*stringValue)(&c.JWTPrimaryKeyID)},
+		{"JWT_ISSUER", "jwt-issuer", "iss claim of auth tokens", (*stringValue)(&c.JWTIssuer)},
+		{"JWT_AUDIENCE", "jwt-audience", "aud claim of auth tokens", (*stringValue)(&c.JWTAudience)},
+		{"AUTH_TOKEN_EXP", "auth-token-exp", "access token lifetime", (*durationValue)(&c.AuthTokenExp)},
+		{"REFRESH_TOKEN_EXP", "refresh-token-exp", "refresh token lifetime", (*durationValue)(&c.RefreshTokenExp)},
+		{"EMAIL_TOKEN_EXP", "email-token-exp", "lifetime of links sent by email", (*durationValue)(&c.EmailTokenExp)},
+		{"PASSWORD_RESET_TOKEN_EXP", "password-reset-token-exp", "lifetime of password reset tokens", (*durationValue)(&c.PasswordResetTokenExp)},
+		{"PASSWORD_MIN_LENGTH", "password-min-length", "minimum password length", (*intValue)(&c.PasswordMinLength)},
+		{"PASSWORD_BREACH_CHECK", "password-breach-check", "reject passwords from breached password lists", (*boolValue)(&c.PasswordBreachCheck)},
+		{"BREACHED_PASSWORDS_FILE", "breached-passwords-file", "SHA-1 hash list or directory of hash ranges of breached passwords", (*stringValue)(&c.BreachedPasswordsFile)},
+		{"TOTP_ISSUER", "totp-issuer", "service name shown in authenticator apps", (*stringValue)(&c.TOTPIssuer)},
+		{"LOGIN_MAX_ATTEMPTS", "login-max-attempts", "failed logins before an account is locked", (*intValue)(&c.LoginMaxAttempts)},
+		{"LOGIN_IP_MAX_ATTEMPTS", "login-ip-max-attempts", "failed logins before a client address is locked", (*intValue)(&c.LoginIPMaxAttempts)},
+		{"LOGIN_LOCKOUT_DURATION", "login-lockout-duration", "login lockout duration", (*durationValue)(&c.LoginLockoutDuration)},
+		{"TRUST_PROXY", "trust-proxy", "take client address from proxy headers", (*boolValue)(&c.TrustProxy)},
+		{"PHOTO_DIR", "photo-dir", "directory for profile photos", (*stringValue)(&c.PhotoDir)},
+		{"PHOTO_MAX_SIZE", "photo-max-size", "maximum profile photo size in bytes", (*intValue)(&c.PhotoMaxSize)},
+		{"OIDC_ISSUER", "oidc-issuer", "OpenID Connect issuer URL, enables single sign-on", (*stringValue)(&c.OIDCIssuer)},
+		{"OIDC_CLIENT_ID", "oidc-client-id", "OpenID Connect client id", (*stringValue)(&c.OIDCClientID)},
+		{"OIDC_CLIENT_SECRET", "", "OpenID Connect client secret", (*stringValue)(&c.OIDCClientSecret)},
+		{"OIDC_REDIRECT_URL", "oidc-redirect-url", "OpenID Connect redirect URL", (*stringValue)(&c.OIDCRedirectURL)},
+		{"OIDC_SCOPES", "oidc-scopes", "comma-separated OpenID Connect scopes", (*listValue)(&c.OIDCScopes)},
+		{"SMTP_AUTH_IDENTITY", "smtp-auth-identity", "SMTP auth identity", (*stringValue)(&c.SMTPAuthIdentity)},
+		{"SMTP_AUTH_USERNAME", "smtp-auth-username", "SMTP auth username", (*stringValue)(&c.SMTPAuthUsername)},
+		{"SMTP_AUTH_PASSWORD", "", "SMTP auth password", (*stringValue)(&c.SMTPAuthPassword)},
+		{"SMTP_HOST", "smtp-host", "SMTP host", (*stringValue)(&c.SMTPHost)},
+		{"SMTP_PORT", "smtp-port", "SMTP port", (*stringValue)(&c.SMTPPort)},
+		{"MAIL_SENDER", "mail-sender", "mail sender: smtp, stdout, file or maildir", (*stringValue)(&c.MailSender)},
+		{"MAIL_DIR", "mail-dir", "directory for file and maildir senders", (*stringValue)(&c.MailDir)},
+		{"MAIL_FROM", "mail-from", "sender address", (*stringValue)(&c.MailFrom)},
+		{"MAIL_TRANSPORTS", "mail-transports", "comma-separated mail transports in priority order", (*listValue)(&c.MailTransports)},
+		{"MAIL_HTTP_URL", "mail-http-url", "HTTP mail API URL", (*stringValue)(&c.MailHTTPURL)},
+		{"MAIL_HTTP_API_KEY", "", "HTTP mail API key", (*stringValue)(&c.MailHTTPAPIKey)},
+		{"MAIL_HTTP_TIMEOUT", "mail-http-timeout", "HTTP mail API timeout", (*durationValue)(&c.MailHTTPTimeout)},
+		{"MAIL_SANDBOX", "mail-sandbox", "redirect outbound email", (*boolValue)(&c.MailSandbox)},
+		{"MAIL_SANDBOX_RECIPIENT", "mail-sandbox-recipient", "sandbox catch-all address", (*stringValue)(&c.MailSandboxRecipient)},
+		{"MAIL_SANDBOX_ALLOWED_DOMAINS", "mail-sandbox-allowed-domains", "comma-separated domains not redirected in sandbox mode", (*listValue)(&c.MailSandboxAllowedDomains)},
+	}
+}
+
+type flagValue struct {
+	opt   option
+	value string
+}
+
+// recordedFlag postpones setting a flag until the file and environment
+// layers have been applied.
+type recordedFlag struct {
+	opt    option
+	values *[]flagValue
+}
+
+func (f *recordedFlag) String() string {
+	if f.opt.value == nil {
+		return ""
+	}
+	return f.opt.value.String()
+}
+
+func (f *recordedFlag) Set(value string) error {
+	*f.values = append(*f.values, flagValue{opt: f.opt, value: value})
+	return nil
+}
+
+type stringValue string
+
+func (v *stringValue) String() string {
+	return string(*v)
+}
+
+func (v *stringValue) Set(value string) error {
+	*v = stringValue(value)
+	return nil
+}
+
+type durationValue time.Duration
+
+func (v *durationValue) String() string {
+	return time.Duration(*v).String()
+}
+
+func (v *durationValue) Set(value string) error {
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	*v = durationValue(d)
+	return nil
+}
+
+type intValue int
+
+func (v *intValue) String() string {
+	return strconv.Itoa(int(*v))
+}
+
+func (v *intValue) Set(value string) error {
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		return err
+	}
+	*v = intValue(i)
+	return nil
+}
+
+type boolValue bool
+
+func (v *boolValue) String() string {
+	return strconv.FormatBool(bool(*v))
+}
+
+func (v *boolValue) Set(value string) error {
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return err
+	}
+	*v = boolValue(b)
+	return nil
+}
+
+type listValue []string
+
+func (v *listValue) String() string {
+	return strings.Join(*v, ",")
+}
+
+func (v *listValue) Set(value string) error {
+	var result []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	*v = result
+	return nil
+}
+
+type jwtKeysValue []JWTKey
+
+func (v *jwtKeysValue) String() string {
+	ids := make([]string, 0, len(*v))
+	for _, key := range *v {
+		ids = append(ids, key.ID+":"+key.Algorithm+":***")
+	}
+	return strings.Join(ids, ",")
+}
+
+func (v *jwtKeysValue) Set(value string) error {
+	var result []JWTKey
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		id, rest, found := strings.Cut(item, ":")
+		if !found {
+			return errors.New("keys must be in id:secret or id:algorithm:key format")
+		}
+		key := JWTKey{ID: id, Algorithm: JWTAlgorithmHS256, Secret: rest}
+		if algorithm, value, found := strings.Cut(rest, ":"); found {
+			switch algorithm {
+			case JWTAlgorithmHS256:
+				key.Secret = value
+			case JWTAlgorithmRS256, JWTAlgorithmEdDSA:
+				key = JWTKey{ID: id, Algorithm: algorithm, PrivateKeyFile: value}
+			}
+		}
+		result = append(result, key)
+	}
+	*v = result
+	return nil
+}
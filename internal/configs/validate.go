@@ -0,0 +1,171 @@
+package configs
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// Validate reports every invalid field at once.
+func (c Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	_, _, err := net.SplitHostPort(c.RunAddr)
+	check(err == nil, "run_address: %q is not a host:port address", c.RunAddr)
+	check(c.DSN != "", "database_uri: must not be empty")
+	check(c.ShutdownTimeout > 0, "shutdown_timeout: must be positive")
+	_, err = zapcore.ParseLevel(c.LogLevel)
+	check(err == nil, "log_level: unknown level %q", c.LogLevel)
+	publicURL, err := url.Parse(c.PublicURL)
+	check(
+		err == nil && (publicURL.Scheme == "http" || publicURL.Scheme == "https") && publicURL.Host != "",
+		"public_url: %q is not an http(s) URL", c.PublicURL,
+	)
+
+	errs = append(errs, c.validateJWTKeys()...)
+	check(c.AuthTokenExp > 0, "auth_token_exp: must be positive")
+	check(c.RefreshTokenExp > 0, "refresh_token_exp: must be positive")
+	check(c.EmailTokenExp > 0, "email_token_exp: must be positive")
+	check(c.PasswordResetTokenExp > 0, "password_reset_token_exp: must be positive")
+	check(c.PasswordMinLength > 0, "password_min_length: must be positive")
+	check(c.TOTPIssuer != "" && !strings.Contains(c.TOTPIssuer, ":"), "totp_issuer: must be non-empty and must not contain ':'")
+	check(c.LoginMaxAttempts > 0, "login_max_attempts: must be positive")
+	check(c.LoginIPMaxAttempts > 0, "login_ip_max_attempts: must be positive")
+	check(c.LoginLockoutDuration > 0, "login_lockout_duration: must be positive")
+	check(c.PhotoDir != "", "photo_dir: must not be empty")
+	check(c.PhotoMaxSize > 0, "photo_max_size: must be positive")
+
+	errs = append(errs, c.validateOIDC()...)
+
+	switch c.MailSender {
+	case MailSenderSMTP:
+		errs = append(errs, c.validateMailTransports()...)
+	case MailSenderStdout:
+	case MailSenderFile, MailSenderMaildir:
+		check(c.MailDir != "", "mail_dir: required for %s mail sender", c.MailSender)
+	default:
+		check(false, "mail_sender: unknown sender %q", c.MailSender)
+	}
+	if c.MailSandbox {
+		check(
+			c.MailSandboxRecipient != "" || len(c.MailSandboxAllowedDomains) > 0,
+			"mail_sandbox: requires mail_sandbox_recipient or mail_sandbox_allowed_domains",
+		)
+	}
+
+	return errors.Join(errs...)
+}
+
+func (c Config) validateMailTransports() []error {
+	if len(c.MailTransports) == 0 {
+		return []error{errors.New("mail_transports: must not be empty")}
+	}
+
+	var errs []error
+	for _, transport := range c.MailTransports {
+		switch transport {
+		case MailTransportSMTP:
+			if c.SMTPHost == "" || c.SMTPPort == "" {
+				errs = append(errs, errors.New("smtp_host, smtp_port: required for smtp mail transport"))
+			}
+		case MailTransportHTTP:
+			if u, err := url.Parse(c.MailHTTPURL); err != nil || u.Scheme == "" || u.Host == "" {
+				errs = append(errs, fmt.Errorf("mail_http_url: %q is not an absolute URL", c.MailHTTPURL))
+			}
+			if c.MailHTTPTimeout <= 0 {
+				errs = append(errs, errors.New("mail_http_timeout: must be positive"))
+			}
+		default:
+			errs = append(errs, fmt.Errorf("mail_transports: unknown transport %q", transport))
+		}
+	}
+	return errs
+}
+
+// insecureSecrets are well-known placeholder keys, tokens signed with them
+// can be forged by anyone.
+var insecureSecrets = map[string]struct{}{
+	"secret":   {},
+	"changeme": {},
+}
+
+func (c Config) validateJWTKeys() []error {
+	if len(c.JWTKeys) == 0 {
+		if c.SecretKey == "" {
+			return []error{errors.New("secret_key: required when jwt_keys is not set")}
+		}
+		if _, ok := insecureSecrets[c.SecretKey]; ok {
+			return []error{fmt.Errorf("secret_key: %q is a well-known placeholder, generate a random key", c.SecretKey)}
+		}
+		return nil
+	}
+
+	var errs []error
+	ids := make(map[string]struct{}, len(c.JWTKeys))
+	for _, key := range c.JWTKeys {
+		if key.ID == "" {
+			errs = append(errs, errors.New("jwt_keys: key id must not be empty"))
+			continue
+		}
+		if _, ok := ids[key.ID]; ok {
+			errs = append(errs, fmt.Errorf("jwt_keys: duplicate key id %q", key.ID))
+		}
+		ids[key.ID] = struct{}{}
+		switch key.Algorithm {
+		case JWTAlgorithmHS256, "":
+			if key.Secret == "" {
+				errs = append(errs, fmt.Errorf("jwt_keys: key %q has empty secret", key.ID))
+			} else if _, ok := insecureSecrets[key.Secret]; ok {
+				errs = append(errs, fmt.Errorf("jwt_keys: key %q has a well-known placeholder secret", key.ID))
+			}
+		case JWTAlgorithmRS256, JWTAlgorithmEdDSA:
+			if key.PrivateKeyFile == "" {
+				errs = append(errs, fmt.Errorf("jwt_keys: key %q requires private_key_file", key.ID))
+			}
+		default:
+			errs = append(errs, fmt.Errorf("jwt_keys: key %q has unsupported algorithm %q", key.ID, key.Algorithm))
+		}
+	}
+	if c.JWTPrimaryKeyID != "" {
+		if _, ok := ids[c.JWTPrimaryKeyID]; !ok {
+			errs = append(errs, fmt.Errorf("jwt_primary_key_id: unknown key %q", c.JWTPrimaryKeyID))
+		}
+	}
+	return errs
+}
+
+func (c Config) validateOIDC() []error {
+	if c.OIDCIssuer == "" {
+		return nil
+	}
+
+	var errs []error
+	if u, err := url.Parse(c.OIDCIssuer); err != nil || u.Scheme == "" || u.Host == "" {
+		errs = append(errs, fmt.Errorf("oidc_issuer: %q is not an absolute URL", c.OIDCIssuer))
+	}
+	if c.OIDCClientID == "" {
+		errs = append(errs, errors.New("oidc_client_id: required when oidc_issuer is set"))
+	}
+	if c.OIDCRedirectURL != "" {
+		if u, err := url.Parse(c.OIDCRedirectURL); err != nil || u.Scheme == "" || u.Host == "" {
+			errs = append(errs, fmt.Errorf("oidc_redirect_url: %q is not an absolute URL", c.OIDCRedirectURL))
+		}
+	}
+	hasOpenID := false
+	for _, scope := range c.OIDCScopes {
+		hasOpenID = hasOpenID || scope == "openid"
+	}
+	if !hasOpenID {
+		errs = append(errs, errors.New(`oidc_scopes: must include "openid"`))
+	}
+	return errs
+}
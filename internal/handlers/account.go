@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/ilya-burinskiy/birthday-notify/internal/auth"
+	"github.com/ilya-burinskiy/birthday-notify/internal/middlewares"
+	"github.com/ilya-burinskiy/birthday-notify/internal/services"
+	"go.uber.org/zap"
+)
+
+type AccountService interface {
+	Delete(ctx context.Context, userID int, ip, password string) error
+	Export(ctx context.Context, userID int) (services.AccountExport, error)
+}
+
+type AccountHandler struct {
+	logger *zap.Logger
+}
+
+func NewAccountHandler(logger *zap.Logger) AccountHandler {
+	return AccountHandler{
+		logger: logger,
+	}
+}
+
+// Delete deletes the account of the current user and clears the auth
+// cookies. The body is optional for users without a password.
+func (h AccountHandler) Delete(accountSrv AccountService) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		type payload struct {
+			Password string `json:"password"`
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		var requestBody payload
+		encoder := json.NewEncoder(w)
+		if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil && !errors.Is(err, io.EOF) {
+			w.WriteHeader(http.StatusBadRequest)
+			if err := encoder.Encode("invalid request body"); err != nil {
+				h.logger.Info("failed to encode response", zap.Error(err))
+			}
+			return
+		}
+
+		userID, _ := middlewares.UserIDFromContext(r.Context())
+		if err := accountSrv.Delete(r.Context(), userID, clientIP(r), requestBody.Password); err != nil {
+			var throttledErr services.ErrTooManyLoginAttempts
+			if errors.As(err, &throttledErr) {
+				writeTooManyAttempts(w, throttledErr, h.logger)
+				return
+			}
+			if errors.Is(err, services.ErrWrongPassword) {
+				w.WriteHeader(http.StatusForbidden)
+				if err := encoder.Encode(err.Error()); err != nil {
+					h.logger.Info("failed to encode response", zap.Error(err))
+				}
+				return
+			}
+			h.logger.Info("failed to delete account", zap.Error(err))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		auth.ClearAuthCookies(w)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (h AccountHandler) Export(accountSrv AccountService) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		userID, _ := middlewares.UserIDFromContext(r.Context())
+		export, err := accountSrv.Export(r.Context(), userID)
+		if err != nil {
+			h.logger.Info("failed to export account", zap.Error(err))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Disposition", `attachment; filename="birthday-notify-export.json"`)
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(export); err != nil {
+			h.logger.Info("failed to encode response", zap.Error(err))
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ilya-burinskiy/birthday-notify/internal/middlewares"
+	"github.com/ilya-burinskiy/birthday-notify/internal/models"
+	"github.com/ilya-burinskiy/birthday-notify/internal/services"
+	"github.com/ilya-burinskiy/birthday-notify/internal/storage"
+	"go.uber.org/zap"
+)
+
+type AdminService interface {
+	ListUsers(ctx context.Context) ([]models.User, error)
+	GetUser(ctx context.Context, userID int) (models.User, error)
+	SetRole(ctx context.Context, adminID, userID int, role string) error
+	Disable(ctx context.Context, adminID, userID int) error
+	Enable(ctx context.Context, userID int) error
+	Delete(ctx context.Context, adminID, userID int) error
+	Subscriptions(ctx context.Context, userID int) (services.UserSubscriptions, error)
+	NotificationSetting(ctx context.Context, userID int) (models.NotifySetting, error)
+	SetNotificationSetting(ctx context.Context, userID, daysBeforeNotify int) (models.NotifySetting, error)
+}
+
+type AdminHandler struct {
+	logger *zap.Logger
+}
+
+func NewAdminHandler(logger *zap.Logger) AdminHandler {
+	return AdminHandler{
+		logger: logger,
+	}
+}
+
+func (h AdminHandler) ListUsers(adminSrv AdminService) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		users, err := adminSrv.ListUsers(r.Context())
+		if err != nil {
+			h.writeError(w, err)
+			return
+		}
+		h.encode(w, users)
+	}
+}
+
+func (h AdminHandler) GetUser(adminSrv AdminService) func(http.ResponseWriter, *http.Request) {
+	return h.withUserID(func(w http.ResponseWriter, r *http.Request, userID int) {
+		user, err := adminSrv.GetUser(r.Context(), userID)
+		if err != nil {
+			h.writeError(w, err)
+			return
+		}
+		h.encode(w, user)
+	})
+}
+
+func (h AdminHandler) SetRole(adminSrv AdminService) func(http.ResponseWriter, *http.Request) {
+	return h.withUserID(func(w http.ResponseWriter, r *http.Request, userID int) {
+		var requestBody struct {
+			Role string `json:"role"`
+		}
+		if !h.decode(w, r, &requestBody) {
+			return
+		}
+
+		adminID, _ := middlewares.UserIDFromContext(r.Context())
+		if err := adminSrv.SetRole(r.Context(), adminID, userID, requestBody.Role); err != nil {
+			h.writeError(w, err)
+			return
+		}
+		h.logger.Info("user role changed", actor(r), zap.Int("user_id", userID), zap.String("role", requestBody.Role))
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+func (h AdminHandler) Disable(adminSrv AdminService) func(http.ResponseWriter, *http.Request) {
+	return h.withUserID(func(w http.ResponseWriter, r *http.Request, userID int) {
+		adminID, _ := middlewares.UserIDFromContext(r.Context())
+		if err := adminSrv.Disable(r.Context(), adminID, userID); err != nil {
+			h.writeError(w, err)
+			return
+		}
+		h.logger.Info("user disabled", actor(r), zap.Int("user_id", userID))
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+func (h AdminHandler) Enable(adminSrv AdminService) func(http.ResponseWriter, *http.Request) {
+	return h.withUserID(func(w http.ResponseWriter, r *http.Request, userID int) {
+		if err := adminSrv.Enable(r.Context(), userID); err != nil {
+			h.writeError(w, err)
+			return
+		}
+		h.logger.Info("user enabled", actor(r), zap.Int("user_id", userID))
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+func (h AdminHandler) Delete(adminSrv AdminService) func(http.ResponseWriter, *http.Request) {
+	return h.withUserID(func(w http.ResponseWriter, r *http.Request, userID int) {
+		adminID, _ := middlewares.UserIDFromContext(r.Context())
+		if err := adminSrv.Delete(r.Context(), adminID, userID); err != nil {
+			h.writeError(w, err)
+			return
+		}
+		h.logger.Info("user deleted", actor(r), zap.Int("user_id", userID))
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+func (h AdminHandler) Subscriptions(adminSrv AdminService) func(http.ResponseWriter, *http.Request) {
+	return h.withUserID(func(w http.ResponseWriter, r *http.Request, userID int) {
+		subscriptions, err := adminSrv.Subscriptions(r.Context(), userID)
+		if err != nil {
+			h.writeError(w, err)
+			return
+		}
+		h.encode(w, subscriptions)
+	})
+}
+
+func (h AdminHandler) NotificationSetting(adminSrv AdminService) func(http.ResponseWriter, *http.Request) {
+	return h.withUserID(func(w http.ResponseWriter, r *http.Request, userID int) {
+		notifySetting, err := adminSrv.NotificationSetting(r.Context(), userID)
+		if err != nil {
+			h.writeError(w, err)
+			return
+		}
+		h.encode(w, notifySetting)
+	})
+}
+
+func (h AdminHandler) SetNotificationSetting(adminSrv AdminService) func(http.ResponseWriter, *http.Request) {
+	return h.withUserID(func(w http.ResponseWriter, r *http.Request, userID int) {
+		var requestBody struct {
+			DaysBeforeNotify int `json:"days_before_notify"`
+		}
+		if !h.decode(w, r, &requestBody) {
+			return
+		}
+
+		notifySetting, err := adminSrv.SetNotificationSetting(r.Context(), userID, requestBody.DaysBeforeNotify)
+		if err != nil {
+			h.writeError(w, err)
+			return
+		}
+		h.logger.Info(
+			"user notification setting changed",
+			actor(r),
+			zap.Int("user_id", userID),
+			zap.Int("days_before_notify", requestBody.DaysBeforeNotify),
+		)
+		h.encode(w, notifySetting)
+	})
+}
+
+// actor identifies the administrator in the log. Changes to accounts need a
+// session (see the router), so only the remaining actions can be made by
+// scripts with the admin token.
+func actor(r *http.Request) zap.Field {
+	if adminID, ok := middlewares.UserIDFromContext(r.Context()); ok {
+		return zap.Int("admin_id", adminID)
+	}
+	return zap.String("admin", "token")
+}
+
+// withUserID parses the {id} URL parameter.
+func (h AdminHandler) withUserID(
+	next func(w http.ResponseWriter, r *http.Request, userID int),
+) func(http.ResponseWriter, *http.Request) {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		userID, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			h.logger.Info("invalid user id", zap.Error(err))
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		next(w, r, userID)
+	}
+}
+
+func (h AdminHandler) decode(w http.ResponseWriter, r *http.Request, requestBody interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(requestBody); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		h.encode(w, "invalid request body")
+		return false
+	}
+	return true
+}
+
+func (h AdminHandler) encode(w http.ResponseWriter, body interface{}) {
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		h.logger.Info("failed to encode response", zap.Error(err))
+	}
+}
+
+func (h AdminHandler) writeError(w http.ResponseWriter, err error) {
+	var userNotFoundErr storage.ErrUserNotFound
+	var settingNotFoundErr storage.ErrNotifySettingNotFound
+	switch {
+	case errors.As(err, &userNotFoundErr), errors.As(err, &settingNotFoundErr):
+		w.WriteHeader(http.StatusNotFound)
+	case errors.Is(err, services.ErrUnknownRole), errors.Is(err, services.ErrInvalidDaysBefore):
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	case errors.Is(err, services.ErrCannotModifySelf):
+		w.WriteHeader(http.StatusForbidden)
+	default:
+		h.logger.Info("admin request failed", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	h.encode(w, err.Error())
+}
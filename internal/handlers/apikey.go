@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ilya-burinskiy/birthday-notify/internal/middlewares"
+	"github.com/ilya-burinskiy/birthday-notify/internal/models"
+	"github.com/ilya-burinskiy/birthday-notify/internal/services"
+	"github.com/ilya-burinskiy/birthday-notify/internal/storage"
+	"go.uber.org/zap"
+)
+
+type APIKeyService interface {
+	Create(ctx context.Context, userID int, name string, scopes []string, expiresAt *time.Time) (models.APIKey, string, error)
+	List(ctx context.Context, userID int) ([]models.APIKey, error)
+	Revoke(ctx context.Context, userID, apiKeyID int) error
+}
+
+type APIKeyHandler struct {
+	logger *zap.Logger
+}
+
+func NewAPIKeyHandler(logger *zap.Logger) APIKeyHandler {
+	return APIKeyHandler{
+		logger: logger,
+	}
+}
+
+func (h APIKeyHandler) Create(apiKeySrv APIKeyService) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		type payload struct {
+			Name      string     `json:"name"`
+			Scopes    []string   `json:"scopes"`
+			ExpiresAt *time.Time `json:"expires_at"`
+		}
+		type response struct {
+			models.APIKey
+			Key string `json:"key"`
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		var requestBody payload
+		decoder := json.NewDecoder(r.Body)
+		encoder := json.NewEncoder(w)
+		if err := decoder.Decode(&requestBody); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			if err := encoder.Encode("invalid request body"); err != nil {
+				h.logger.Info("failed to encode response", zap.Error(err))
+			}
+			return
+		}
+
+		userID, _ := middlewares.UserIDFromContext(r.Context())
+		apiKey, key, err := apiKeySrv.Create(
+			r.Context(),
+			userID,
+			requestBody.Name,
+			requestBody.Scopes,
+			requestBody.ExpiresAt,
+		)
+		if err != nil {
+			var paramsErr services.ErrInvalidAPIKeyParams
+			if errors.As(err, &paramsErr) {
+				w.WriteHeader(http.StatusUnprocessableEntity)
+				if err := encoder.Encode(err.Error()); err != nil {
+					h.logger.Info("failed to encode response", zap.Error(err))
+				}
+				return
+			}
+			h.logger.Info("failed to create api key", zap.Error(err))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		if err := encoder.Encode(response{APIKey: apiKey, Key: key}); err != nil {
+			h.logger.Info("failed to encode response", zap.Error(err))
+		}
+	}
+}
+
+func (h APIKeyHandler) List(apiKeySrv APIKeyService) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		userID, _ := middlewares.UserIDFromContext(r.Context())
+		apiKeys, err := apiKeySrv.List(r.Context(), userID)
+		if err != nil {
+			h.logger.Info("failed to list api keys", zap.Error(err))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		if err := json.NewEncoder(w).Encode(apiKeys); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			h.logger.Info("failed to encode response", zap.Error(err))
+		}
+	}
+}
+
+func (h APIKeyHandler) Revoke(apiKeySrv APIKeyService) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		apiKeyID, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			h.logger.Info("invalid api key id", zap.Error(err))
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		userID, _ := middlewares.UserIDFromContext(r.Context())
+		if err := apiKeySrv.Revoke(r.Context(), userID, apiKeyID); err != nil {
+			var notFoundErr storage.ErrAPIKeyNotFound
+			if errors.As(err, &notFoundErr) {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			h.logger.Info("failed to revoke api key", zap.Error(err))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/ilya-burinskiy/birthday-notify/internal/middlewares"
+	"github.com/ilya-burinskiy/birthday-notify/internal/services"
+	"github.com/ilya-burinskiy/birthday-notify/internal/storage"
+	"go.uber.org/zap"
+)
+
+type EmailVerificationService interface {
+	Confirm(ctx context.Context, token string) error
+	Resend(ctx context.Context, userID int) error
+}
+
+type EmailVerificationHandler struct {
+	logger *zap.Logger
+}
+
+func NewEmailVerificationHandler(logger *zap.Logger) EmailVerificationHandler {
+	return EmailVerificationHandler{
+		logger: logger,
+	}
+}
+
+// Confirm accepts the token from the ?token= query parameter of the emailed
+// link or from the {"token"} request body.
+func (h EmailVerificationHandler) Confirm(verificationSrv EmailVerificationService) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		encoder := json.NewEncoder(w)
+		token := r.URL.Query().Get("token")
+		if token == "" && r.Method == http.MethodPost {
+			var requestBody struct {
+				Token string `json:"token"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&requestBody); err == nil {
+				token = requestBody.Token
+			}
+		}
+		if token == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			if err := encoder.Encode("token is required"); err != nil {
+				h.logger.Info("failed to encode response", zap.Error(err))
+			}
+			return
+		}
+
+		if err := verificationSrv.Confirm(r.Context(), token); err != nil {
+			if errors.Is(err, services.ErrInvalidVerificationToken) {
+				w.WriteHeader(http.StatusBadRequest)
+				if err := encoder.Encode(err.Error()); err != nil {
+					h.logger.Info("failed to encode response", zap.Error(err))
+				}
+				return
+			}
+			// another user took the new email while the change was pending
+			var notUniqErr storage.ErrUserNotUniq
+			if errors.As(err, &notUniqErr) {
+				w.WriteHeader(http.StatusConflict)
+				if err := encoder.Encode(err.Error()); err != nil {
+					h.logger.Info("failed to encode response", zap.Error(err))
+				}
+				return
+			}
+			h.logger.Info("failed to verify email", zap.Error(err))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+func (h EmailVerificationHandler) Resend(verificationSrv EmailVerificationService) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		userID, _ := middlewares.UserIDFromContext(r.Context())
+		if err := verificationSrv.Resend(r.Context(), userID); err != nil {
+			if errors.Is(err, services.ErrEmailAlreadyVerified) {
+				w.WriteHeader(http.StatusConflict)
+				if err := json.NewEncoder(w).Encode(err.Error()); err != nil {
+					h.logger.Info("failed to encode response", zap.Error(err))
+				}
+				return
+			}
+			h.logger.Info("failed to resend verification email", zap.Error(err))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/ilya-burinskiy/birthday-notify/internal/auth"
+	"go.uber.org/zap"
+)
+
+type JWKSProvider interface {
+	JWKS() auth.JWKSet
+}
+
+type JWKSHandler struct {
+	logger *zap.Logger
+}
+
+func NewJWKSHandler(logger *zap.Logger) JWKSHandler {
+	return JWKSHandler{
+		logger: logger,
+	}
+}
+
+func (h JWKSHandler) Get(provider JWKSProvider) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		if err := json.NewEncoder(w).Encode(provider.JWKS()); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			h.logger.Info("failed to encode response", zap.Error(err))
+			return
+		}
+	}
+}
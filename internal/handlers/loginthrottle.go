@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ilya-burinskiy/birthday-notify/internal/models"
+	"github.com/ilya-burinskiy/birthday-notify/internal/storage"
+	"go.uber.org/zap"
+)
+
+type LoginThrottleService interface {
+	Unlock(ctx context.Context, userID int) error
+	Lockouts(ctx context.Context) ([]models.LoginLockout, error)
+}
+
+type LoginThrottleHandler struct {
+	logger *zap.Logger
+}
+
+func NewLoginThrottleHandler(logger *zap.Logger) LoginThrottleHandler {
+	return LoginThrottleHandler{
+		logger: logger,
+	}
+}
+
+func (h LoginThrottleHandler) Unlock(throttleSrv LoginThrottleService) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		userID, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			h.logger.Info("invalid user id", zap.Error(err))
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if err := throttleSrv.Unlock(r.Context(), userID); err != nil {
+			var notFoundErr storage.ErrUserNotFound
+			if errors.As(err, &notFoundErr) {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			h.logger.Info("failed to unlock user", zap.Error(err))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (h LoginThrottleHandler) Lockouts(throttleSrv LoginThrottleService) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		lockouts, err := throttleSrv.Lockouts(r.Context())
+		if err != nil {
+			h.logger.Info("failed to list lockouts", zap.Error(err))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		if err := json.NewEncoder(w).Encode(lockouts); err != nil {
+			h.logger.Info("failed to encode response", zap.Error(err))
+		}
+	}
+}
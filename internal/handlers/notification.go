@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/ilya-burinskiy/birthday-notify/internal/services"
+	"go.uber.org/zap"
+)
+
+type RunNotificationsService interface {
+	Trigger(ctx context.Context, date time.Time, dryRun bool) (services.NotificationReport, error)
+}
+
+type NotificationHandler struct {
+	logger *zap.Logger
+}
+
+func NewNotificationHandler(logger *zap.Logger) NotificationHandler {
+	return NotificationHandler{
+		logger: logger,
+	}
+}
+
+func (h NotificationHandler) Run(runSrv RunNotificationsService) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		type payload struct {
+			Date   string `json:"date"`
+			DryRun bool   `json:"dry_run"`
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		var requestBody payload
+		decoder := json.NewDecoder(r.Body)
+		encoder := json.NewEncoder(w)
+		err := decoder.Decode(&requestBody)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			if err := encoder.Encode("invalid request body"); err != nil {
+				h.logger.Info("failed to encode response", zap.Error(err))
+			}
+			return
+		}
+
+		date := time.Now().UTC()
+		if requestBody.Date != "" {
+			date, err = time.Parse(time.DateOnly, requestBody.Date)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				if err := encoder.Encode("invalid date, expected YYYY-MM-DD"); err != nil {
+					h.logger.Info("failed to encode response", zap.Error(err))
+				}
+				return
+			}
+		}
+
+		report, err := runSrv.Trigger(r.Context(), date, requestBody.DryRun)
+		if err != nil {
+			if errors.Is(err, services.ErrNotifierStopped) {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.WriteHeader(http.StatusInternalServerError)
+			h.logger.Info("failed to run notifications", zap.Error(err))
+			return
+		}
+
+		if err := encoder.Encode(report); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			h.logger.Info("failed to encode response", zap.Error(err))
+			return
+		}
+	}
+}
@@ -3,12 +3,15 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/ilya-burinskiy/birthday-notify/internal/middlewares"
 	"github.com/ilya-burinskiy/birthday-notify/internal/models"
+	"github.com/ilya-burinskiy/birthday-notify/internal/services"
+	"github.com/ilya-burinskiy/birthday-notify/internal/storage"
 	"go.uber.org/zap"
 )
 
@@ -17,7 +20,7 @@ type CreateNotificationSettingService interface {
 }
 
 type UpdateNotificationSettingService interface {
-	UpdateNotificationSetting(ctx context.Context, settingID, daysBeforeNotify int) (models.NotifySetting, error)
+	UpdateNotificationSetting(ctx context.Context, userID, settingID, daysBeforeNotify int) (models.NotifySetting, error)
 }
 
 type NotificationSettingHandler struct {
@@ -56,9 +59,23 @@ func (h NotificationSettingHandler) Create(createSrv CreateNotificationSettingSe
 			requestBody.DaysBeforeNotify,
 		)
 
-		// TODO: response with proper http status
 		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
+			var notUniqErr storage.ErrNotifySettingNotUniq
+			var userNotFoundErr storage.ErrUserNotFound
+			switch {
+			case errors.Is(err, services.ErrInvalidDaysBefore):
+				w.WriteHeader(http.StatusUnprocessableEntity)
+				if err := encoder.Encode(err.Error()); err != nil {
+					h.logger.Info("failed to encode response", zap.Error(err))
+				}
+				return
+			case errors.As(err, &notUniqErr):
+				w.WriteHeader(http.StatusConflict)
+			case errors.As(err, &userNotFoundErr):
+				w.WriteHeader(http.StatusNotFound)
+			default:
+				w.WriteHeader(http.StatusBadRequest)
+			}
 			h.logger.Info("failed to create notification setting", zap.Error(err))
 			return
 		}
@@ -97,14 +114,29 @@ func (h NotificationSettingHandler) Update(updateSrv UpdateNotificationSettingSe
 			return
 		}
 
+		userID, _ := middlewares.UserIDFromContext(r.Context())
 		notifySetting, err := updateSrv.UpdateNotificationSetting(
 			context.Background(),
+			userID,
 			settingID,
 			requestBody.DaysBeforeNotify,
 		)
 		if err != nil {
-			h.logger.Info("failed to update notification setting", zap.Error(err))
-			w.WriteHeader(http.StatusInternalServerError)
+			var notFoundErr storage.ErrNotifySettingNotFound
+			switch {
+			case errors.Is(err, services.ErrInvalidDaysBefore):
+				w.WriteHeader(http.StatusUnprocessableEntity)
+				if err := encoder.Encode(err.Error()); err != nil {
+					h.logger.Info("failed to encode response", zap.Error(err))
+				}
+			case errors.As(err, &notFoundErr):
+				w.WriteHeader(http.StatusNotFound)
+			case errors.Is(err, services.ErrForbidden):
+				w.WriteHeader(http.StatusForbidden)
+			default:
+				h.logger.Info("failed to update notification setting", zap.Error(err))
+				w.WriteHeader(http.StatusInternalServerError)
+			}
 			return
 		}
 
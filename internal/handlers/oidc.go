@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/ilya-burinskiy/birthday-notify/internal/auth"
+	"github.com/ilya-burinskiy/birthday-notify/internal/oidc"
+	"github.com/ilya-burinskiy/birthday-notify/internal/services"
+	"go.uber.org/zap"
+)
+
+const (
+	oidcCookie     = "oidc_auth"
+	oidcCookiePath = "/api/auth/oidc"
+	// the user has ten minutes to sign in at the provider
+	oidcCookieMaxAge = 600
+)
+
+type OIDCLoginService interface {
+	Begin(ctx context.Context) (oidc.AuthRequest, string, error)
+	Complete(ctx context.Context, req oidc.AuthRequest, code string) (auth.TokenPair, error)
+}
+
+type OIDCHandler struct {
+	logger *zap.Logger
+}
+
+func NewOIDCHandler(logger *zap.Logger) OIDCHandler {
+	return OIDCHandler{
+		logger: logger,
+	}
+}
+
+// Login redirects the user to the provider. State, nonce and PKCE verifier
+// are kept in a short-lived cookie until the provider redirects back.
+func (h OIDCHandler) Login(loginSrv OIDCLoginService) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req, authURL, err := loginSrv.Begin(r.Context())
+		if err != nil {
+			h.logger.Info("failed to start single sign-on", zap.Error(err))
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+
+		http.SetCookie(w, &http.Cookie{
+			Name:     oidcCookie,
+			Value:    strings.Join([]string{req.State, req.Nonce, req.CodeVerifier}, "."),
+			Path:     oidcCookiePath,
+			MaxAge:   oidcCookieMaxAge,
+			HttpOnly: true,
+			// the provider redirects back with a top-level GET
+			SameSite: http.SameSiteLaxMode,
+		})
+		http.Redirect(w, r, authURL, http.StatusFound)
+	}
+}
+
+func (h OIDCHandler) Callback(loginSrv OIDCLoginService) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		encoder := json.NewEncoder(w)
+		http.SetCookie(w, &http.Cookie{Name: oidcCookie, Path: oidcCookiePath, MaxAge: -1, HttpOnly: true})
+
+		query := r.URL.Query()
+		if providerErr := query.Get("error"); providerErr != "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			if err := encoder.Encode("single sign-on failed: " + providerErr); err != nil {
+				h.logger.Info("failed to encode response", zap.Error(err))
+			}
+			return
+		}
+
+		req, ok := oidcAuthRequestFromCookie(r)
+		if !ok || subtle.ConstantTimeCompare([]byte(query.Get("state")), []byte(req.State)) != 1 {
+			w.WriteHeader(http.StatusBadRequest)
+			if err := encoder.Encode("invalid or expired single sign-on state"); err != nil {
+				h.logger.Info("failed to encode response", zap.Error(err))
+			}
+			return
+		}
+
+		tokens, err := loginSrv.Complete(r.Context(), req, query.Get("code"))
+		if err != nil {
+			if errors.Is(err, services.ErrUserDisabled) {
+				w.WriteHeader(http.StatusForbidden)
+				if err := encoder.Encode(err.Error()); err != nil {
+					h.logger.Info("failed to encode response", zap.Error(err))
+				}
+				return
+			}
+			if errors.Is(err, services.ErrOIDCLoginFailed) || errors.Is(err, services.ErrOIDCEmailNotVerified) {
+				h.logger.Info("single sign-on failed", zap.Error(err))
+				w.WriteHeader(http.StatusUnauthorized)
+				if err := encoder.Encode(services.ErrOIDCLoginFailed.Error()); err != nil {
+					h.logger.Info("failed to encode response", zap.Error(err))
+				}
+				return
+			}
+			h.logger.Info("failed to complete single sign-on", zap.Error(err))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		auth.SetAuthCookies(w, tokens)
+		w.WriteHeader(http.StatusOK)
+		if err := encoder.Encode(auth.NewTokenResponse(tokens)); err != nil {
+			h.logger.Info("failed to encode response", zap.Error(err))
+		}
+	}
+}
+
+func oidcAuthRequestFromCookie(r *http.Request) (oidc.AuthRequest, bool) {
+	cookie, err := r.Cookie(oidcCookie)
+	if err != nil {
+		return oidc.AuthRequest{}, false
+	}
+	parts := strings.Split(cookie.Value, ".")
+	if len(parts) != 3 || parts[0] == "" {
+		return oidc.AuthRequest{}, false
+	}
+
+	return oidc.AuthRequest{State: parts[0], Nonce: parts[1], CodeVerifier: parts[2]}, true
+}
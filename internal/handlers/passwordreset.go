@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/ilya-burinskiy/birthday-notify/internal/services"
+	"go.uber.org/zap"
+)
+
+type PasswordResetService interface {
+	Forgot(ctx context.Context, email, ip string) error
+	Reset(ctx context.Context, token, password string) error
+}
+
+type PasswordResetHandler struct {
+	logger *zap.Logger
+}
+
+func NewPasswordResetHandler(logger *zap.Logger) PasswordResetHandler {
+	return PasswordResetHandler{
+		logger: logger,
+	}
+}
+
+// Forgot responds with 202 whether or not the email is registered, and with
+// 429 to a client that made too many requests.
+func (h PasswordResetHandler) Forgot(resetSrv PasswordResetService) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		type payload struct {
+			Email string `json:"email"`
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		var requestBody payload
+		encoder := json.NewEncoder(w)
+		if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil || requestBody.Email == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			if err := encoder.Encode("invalid request body"); err != nil {
+				h.logger.Info("failed to encode response", zap.Error(err))
+			}
+			return
+		}
+
+		if err := resetSrv.Forgot(r.Context(), requestBody.Email, clientIP(r)); err != nil {
+			switch {
+			case errors.Is(err, services.ErrTooManyPasswordResets):
+				w.WriteHeader(http.StatusTooManyRequests)
+				if err := encoder.Encode(err.Error()); err != nil {
+					h.logger.Info("failed to encode response", zap.Error(err))
+				}
+			case errors.Is(err, services.ErrShuttingDown):
+				w.WriteHeader(http.StatusServiceUnavailable)
+			default:
+				h.logger.Info("failed to request password reset", zap.Error(err))
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+			return
+		}
+
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
+
+func (h PasswordResetHandler) Reset(resetSrv PasswordResetService) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		type payload struct {
+			Token    string `json:"token"`
+			Password string `json:"password"`
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		var requestBody payload
+		encoder := json.NewEncoder(w)
+		if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			if err := encoder.Encode("invalid request body"); err != nil {
+				h.logger.Info("failed to encode response", zap.Error(err))
+			}
+			return
+		}
+
+		err := resetSrv.Reset(r.Context(), requestBody.Token, requestBody.Password)
+		if err != nil {
+			var weakPasswordErr services.ErrWeakPassword
+			if errors.As(err, &weakPasswordErr) {
+				writeWeakPassword(w, weakPasswordErr, h.logger)
+				return
+			}
+			if errors.Is(err, services.ErrInvalidPasswordResetToken) || errors.Is(err, services.ErrEmptyPassword) {
+				w.WriteHeader(http.StatusBadRequest)
+				if err := encoder.Encode(err.Error()); err != nil {
+					h.logger.Info("failed to encode response", zap.Error(err))
+				}
+				return
+			}
+			h.logger.Info("failed to reset password", zap.Error(err))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ilya-burinskiy/birthday-notify/internal/middlewares"
+	"github.com/ilya-burinskiy/birthday-notify/internal/models"
+	"github.com/ilya-burinskiy/birthday-notify/internal/services"
+	"github.com/ilya-burinskiy/birthday-notify/internal/storage"
+	"go.uber.org/zap"
+)
+
+type PhotoService interface {
+	Upload(ctx context.Context, userID int, r io.Reader) (models.User, error)
+	Remove(ctx context.Context, userID int) error
+	Open(ctx context.Context, userID int) (io.ReadSeekCloser, string, error)
+}
+
+type PhotoHandler struct {
+	logger *zap.Logger
+}
+
+func NewPhotoHandler(logger *zap.Logger) PhotoHandler {
+	return PhotoHandler{
+		logger: logger,
+	}
+}
+
+// Upload takes the image as the raw request body.
+func (h PhotoHandler) Upload(photoSrv PhotoService) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		encoder := json.NewEncoder(w)
+		userID, _ := middlewares.UserIDFromContext(r.Context())
+		user, err := photoSrv.Upload(r.Context(), userID, r.Body)
+		if err != nil {
+			switch {
+			case errors.Is(err, services.ErrPhotoTooLarge):
+				w.WriteHeader(http.StatusRequestEntityTooLarge)
+			case errors.Is(err, services.ErrUnsupportedPhotoType):
+				w.WriteHeader(http.StatusUnsupportedMediaType)
+			default:
+				h.logger.Info("failed to upload photo", zap.Error(err))
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			if err := encoder.Encode(err.Error()); err != nil {
+				h.logger.Info("failed to encode response", zap.Error(err))
+			}
+			return
+		}
+
+		if err := encoder.Encode(user); err != nil {
+			h.logger.Info("failed to encode response", zap.Error(err))
+		}
+	}
+}
+
+func (h PhotoHandler) Delete(photoSrv PhotoService) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, _ := middlewares.UserIDFromContext(r.Context())
+		if err := photoSrv.Remove(r.Context(), userID); err != nil {
+			h.logger.Info("failed to remove photo", zap.Error(err))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// Get serves the photo of any user. The file name changes with every upload,
+// so it is used as the ETag and clients revalidate cached photos cheaply.
+func (h PhotoHandler) Get(photoSrv PhotoService) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		photo, name, err := photoSrv.Open(r.Context(), userID)
+		if err != nil {
+			var notFoundErr storage.ErrUserNotFound
+			if errors.Is(err, services.ErrPhotoNotFound) || errors.As(err, &notFoundErr) {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			h.logger.Info("failed to open photo", zap.Error(err))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		defer photo.Close()
+
+		w.Header().Set("Cache-Control", "public, no-cache")
+		w.Header().Set("ETag", strconv.Quote(name))
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		http.ServeContent(w, r, name, time.Time{}, photo)
+	}
+}
@@ -0,0 +1,218 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/ilya-burinskiy/birthday-notify/internal/middlewares"
+	"github.com/ilya-burinskiy/birthday-notify/internal/models"
+	"github.com/ilya-burinskiy/birthday-notify/internal/services"
+	"go.uber.org/zap"
+)
+
+type ProfileService interface {
+	Get(ctx context.Context, userID int) (models.User, error)
+	Update(ctx context.Context, userID int, update models.UserUpdate) (models.User, error)
+	ChangeEmail(ctx context.Context, userID int, ip, currentPassword, email string) (models.User, error)
+	ChangePassword(ctx context.Context, userID, sessionID int, ip, currentPassword, newPassword string) error
+}
+
+type ProfileHandler struct {
+	logger *zap.Logger
+}
+
+func NewProfileHandler(logger *zap.Logger) ProfileHandler {
+	return ProfileHandler{
+		logger: logger,
+	}
+}
+
+func (h ProfileHandler) Get(profileSrv ProfileService) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		userID, _ := middlewares.UserIDFromContext(r.Context())
+		user, err := profileSrv.Get(r.Context(), userID)
+		if err != nil {
+			h.logger.Info("failed to find user", zap.Error(err))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		if err := json.NewEncoder(w).Encode(user); err != nil {
+			h.logger.Info("failed to encode response", zap.Error(err))
+		}
+	}
+}
+
+// Update changes only the fields present in the request body. The email is
+// changed with ChangeEmail.
+func (h ProfileHandler) Update(profileSrv ProfileService) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		type payload struct {
+			Email       *string    `json:"email"`
+			Birthdate   *time.Time `json:"birthdate"`
+			FirstName   *string    `json:"first_name"`
+			LastName    *string    `json:"last_name"`
+			DisplayName *string    `json:"display_name"`
+			Bio         *string    `json:"bio"`
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		var requestBody payload
+		encoder := json.NewEncoder(w)
+		if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			if err := encoder.Encode("invalid request body"); err != nil {
+				h.logger.Info("failed to encode response", zap.Error(err))
+			}
+			return
+		}
+
+		if requestBody.Email != nil {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			if err := encoder.Encode("email is changed with POST /api/users/me/email"); err != nil {
+				h.logger.Info("failed to encode response", zap.Error(err))
+			}
+			return
+		}
+
+		userID, _ := middlewares.UserIDFromContext(r.Context())
+		user, err := profileSrv.Update(r.Context(), userID, models.UserUpdate{
+			BirthDate:   requestBody.Birthdate,
+			FirstName:   requestBody.FirstName,
+			LastName:    requestBody.LastName,
+			DisplayName: requestBody.DisplayName,
+			Bio:         requestBody.Bio,
+		})
+		if err != nil {
+			var tooLongErr services.ErrFieldTooLong
+			var invalidCharsErr services.ErrInvalidCharacters
+			if !errors.As(err, &tooLongErr) && !errors.As(err, &invalidCharsErr) {
+				h.logger.Info("failed to update profile", zap.Error(err))
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			if err := encoder.Encode(err.Error()); err != nil {
+				h.logger.Info("failed to encode response", zap.Error(err))
+			}
+			return
+		}
+
+		if err := encoder.Encode(user); err != nil {
+			h.logger.Info("failed to encode response", zap.Error(err))
+		}
+	}
+}
+
+// ChangeEmail responds with the profile, the new email is in pending_email
+// until it is confirmed.
+func (h ProfileHandler) ChangeEmail(profileSrv ProfileService) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		type payload struct {
+			Email           string `json:"email"`
+			CurrentPassword string `json:"current_password"`
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		var requestBody payload
+		encoder := json.NewEncoder(w)
+		if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			if err := encoder.Encode("invalid request body"); err != nil {
+				h.logger.Info("failed to encode response", zap.Error(err))
+			}
+			return
+		}
+
+		userID, _ := middlewares.UserIDFromContext(r.Context())
+		user, err := profileSrv.ChangeEmail(r.Context(), userID, clientIP(r), requestBody.CurrentPassword, requestBody.Email)
+		if err != nil {
+			var throttledErr services.ErrTooManyLoginAttempts
+			if errors.As(err, &throttledErr) {
+				writeTooManyAttempts(w, throttledErr, h.logger)
+				return
+			}
+			switch {
+			case errors.Is(err, services.ErrInvalidEmail):
+				w.WriteHeader(http.StatusUnprocessableEntity)
+			case errors.Is(err, services.ErrWrongPassword):
+				w.WriteHeader(http.StatusForbidden)
+			default:
+				h.logger.Info("failed to change email", zap.Error(err))
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			if err := encoder.Encode(err.Error()); err != nil {
+				h.logger.Info("failed to encode response", zap.Error(err))
+			}
+			return
+		}
+
+		if err := encoder.Encode(user); err != nil {
+			h.logger.Info("failed to encode response", zap.Error(err))
+		}
+	}
+}
+
+func (h ProfileHandler) ChangePassword(profileSrv ProfileService) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		type payload struct {
+			CurrentPassword string `json:"current_password"`
+			NewPassword     string `json:"new_password"`
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		var requestBody payload
+		encoder := json.NewEncoder(w)
+		if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			if err := encoder.Encode("invalid request body"); err != nil {
+				h.logger.Info("failed to encode response", zap.Error(err))
+			}
+			return
+		}
+
+		userID, _ := middlewares.UserIDFromContext(r.Context())
+		sessionID, _ := middlewares.SessionIDFromContext(r.Context())
+		err := profileSrv.ChangePassword(
+			r.Context(),
+			userID,
+			sessionID,
+			clientIP(r),
+			requestBody.CurrentPassword,
+			requestBody.NewPassword,
+		)
+		if err != nil {
+			var weakPasswordErr services.ErrWeakPassword
+			if errors.As(err, &weakPasswordErr) {
+				writeWeakPassword(w, weakPasswordErr, h.logger)
+				return
+			}
+			var throttledErr services.ErrTooManyLoginAttempts
+			if errors.As(err, &throttledErr) {
+				writeTooManyAttempts(w, throttledErr, h.logger)
+				return
+			}
+			switch {
+			case errors.Is(err, services.ErrWrongPassword):
+				w.WriteHeader(http.StatusForbidden)
+			case errors.Is(err, services.ErrEmptyPassword):
+				w.WriteHeader(http.StatusBadRequest)
+			default:
+				h.logger.Info("failed to change password", zap.Error(err))
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			if err := encoder.Encode(err.Error()); err != nil {
+				h.logger.Info("failed to encode response", zap.Error(err))
+			}
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
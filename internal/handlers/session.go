@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/ilya-burinskiy/birthday-notify/internal/auth"
+	"github.com/ilya-burinskiy/birthday-notify/internal/middlewares"
+	"github.com/ilya-burinskiy/birthday-notify/internal/services"
+	"go.uber.org/zap"
+)
+
+type RefreshSessionService interface {
+	Refresh(ctx context.Context, refreshToken string) (auth.TokenPair, error)
+}
+
+type LogoutService interface {
+	Logout(ctx context.Context, sessionID int) error
+	LogoutAll(ctx context.Context, userID int) error
+}
+
+type SessionHandler struct {
+	logger *zap.Logger
+}
+
+func NewSessionHandler(logger *zap.Logger) SessionHandler {
+	return SessionHandler{
+		logger: logger,
+	}
+}
+
+func (h SessionHandler) Refresh(refreshSrv RefreshSessionService) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		refreshToken, ok := auth.RefreshTokenFromCookie(r)
+		if !ok {
+			// clients without cookies send the refresh token in the body
+			var requestBody struct {
+				RefreshToken string `json:"refresh_token"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil || requestBody.RefreshToken == "" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			refreshToken = requestBody.RefreshToken
+		}
+
+		tokens, err := refreshSrv.Refresh(r.Context(), refreshToken)
+		if err != nil {
+			if errors.Is(err, services.ErrInvalidRefreshToken) {
+				auth.ClearAuthCookies(w)
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			h.logger.Info("failed to refresh session", zap.Error(err))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		if !ok {
+			w.WriteHeader(http.StatusOK)
+			if err := json.NewEncoder(w).Encode(auth.NewTokenResponse(tokens)); err != nil {
+				h.logger.Info("failed to encode response", zap.Error(err))
+			}
+			return
+		}
+		writeTokens(w, r, tokens, h.logger)
+	}
+}
+
+func (h SessionHandler) Logout(logoutSrv LogoutService) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		sessionID, _ := middlewares.SessionIDFromContext(r.Context())
+		if err := logoutSrv.Logout(r.Context(), sessionID); err != nil {
+			h.logger.Info("failed to logout", zap.Error(err))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		auth.ClearAuthCookies(w)
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+func (h SessionHandler) LogoutAll(logoutSrv LogoutService) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		userID, _ := middlewares.UserIDFromContext(r.Context())
+		if err := logoutSrv.LogoutAll(r.Context(), userID); err != nil {
+			h.logger.Info("failed to logout", zap.Error(err))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		auth.ClearAuthCookies(w)
+		w.WriteHeader(http.StatusOK)
+	}
+}
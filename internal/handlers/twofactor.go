@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/ilya-burinskiy/birthday-notify/internal/middlewares"
+	"github.com/ilya-burinskiy/birthday-notify/internal/services"
+	"go.uber.org/zap"
+)
+
+type TwoFactorService interface {
+	Enroll(ctx context.Context, userID int) (string, string, error)
+	Confirm(ctx context.Context, userID int, ip, code string) ([]string, error)
+	Disable(ctx context.Context, userID int, ip, code string) error
+	RegenerateRecoveryCodes(ctx context.Context, userID int, ip, code string) ([]string, error)
+}
+
+type TwoFactorHandler struct {
+	logger *zap.Logger
+}
+
+func NewTwoFactorHandler(logger *zap.Logger) TwoFactorHandler {
+	return TwoFactorHandler{
+		logger: logger,
+	}
+}
+
+func (h TwoFactorHandler) Enroll(twoFactorSrv TwoFactorService) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		type response struct {
+			Secret          string `json:"secret"`
+			ProvisioningURI string `json:"provisioning_uri"`
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		userID, _ := middlewares.UserIDFromContext(r.Context())
+		secret, uri, err := twoFactorSrv.Enroll(r.Context(), userID)
+		if err != nil {
+			h.writeError(w, err)
+			return
+		}
+
+		if err := json.NewEncoder(w).Encode(response{Secret: secret, ProvisioningURI: uri}); err != nil {
+			h.logger.Info("failed to encode response", zap.Error(err))
+		}
+	}
+}
+
+func (h TwoFactorHandler) Confirm(twoFactorSrv TwoFactorService) func(http.ResponseWriter, *http.Request) {
+	return h.withCode(func(w http.ResponseWriter, r *http.Request, userID int, code string) {
+		codes, err := twoFactorSrv.Confirm(r.Context(), userID, clientIP(r), code)
+		if err != nil {
+			h.writeError(w, err)
+			return
+		}
+		h.writeRecoveryCodes(w, codes)
+	})
+}
+
+func (h TwoFactorHandler) Disable(twoFactorSrv TwoFactorService) func(http.ResponseWriter, *http.Request) {
+	return h.withCode(func(w http.ResponseWriter, r *http.Request, userID int, code string) {
+		if err := twoFactorSrv.Disable(r.Context(), userID, clientIP(r), code); err != nil {
+			h.writeError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+func (h TwoFactorHandler) RegenerateRecoveryCodes(twoFactorSrv TwoFactorService) func(http.ResponseWriter, *http.Request) {
+	return h.withCode(func(w http.ResponseWriter, r *http.Request, userID int, code string) {
+		codes, err := twoFactorSrv.RegenerateRecoveryCodes(r.Context(), userID, clientIP(r), code)
+		if err != nil {
+			h.writeError(w, err)
+			return
+		}
+		h.writeRecoveryCodes(w, codes)
+	})
+}
+
+// withCode decodes the {"code"} request body shared by the endpoints.
+func (h TwoFactorHandler) withCode(
+	next func(w http.ResponseWriter, r *http.Request, userID int, code string),
+) func(http.ResponseWriter, *http.Request) {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		var requestBody struct {
+			Code string `json:"code"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			if err := json.NewEncoder(w).Encode("invalid request body"); err != nil {
+				h.logger.Info("failed to encode response", zap.Error(err))
+			}
+			return
+		}
+
+		userID, _ := middlewares.UserIDFromContext(r.Context())
+		next(w, r, userID, requestBody.Code)
+	}
+}
+
+func (h TwoFactorHandler) writeRecoveryCodes(w http.ResponseWriter, codes []string) {
+	type response struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+	if err := json.NewEncoder(w).Encode(response{RecoveryCodes: codes}); err != nil {
+		h.logger.Info("failed to encode response", zap.Error(err))
+	}
+}
+
+func (h TwoFactorHandler) writeError(w http.ResponseWriter, err error) {
+	var throttledErr services.ErrTooManyLoginAttempts
+	if errors.As(err, &throttledErr) {
+		writeTooManyAttempts(w, throttledErr, h.logger)
+		return
+	}
+
+	var status int
+	switch {
+	case errors.Is(err, services.ErrInvalidTOTPCode):
+		status = http.StatusUnprocessableEntity
+	case errors.Is(err, services.ErrTOTPAlreadyEnabled),
+		errors.Is(err, services.ErrTOTPNotEnabled),
+		errors.Is(err, services.ErrTOTPNotEnrolled):
+		status = http.StatusConflict
+	default:
+		h.logger.Info("two-factor authentication request failed", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(err.Error()); err != nil {
+		h.logger.Info("failed to encode response", zap.Error(err))
+	}
+}
@@ -4,21 +4,29 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"math"
+	"net"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/ilya-burinskiy/birthday-notify/internal/auth"
 	"github.com/ilya-burinskiy/birthday-notify/internal/models"
+	"github.com/ilya-burinskiy/birthday-notify/internal/services"
 	"github.com/ilya-burinskiy/birthday-notify/internal/storage"
 	"go.uber.org/zap"
 )
 
 type RegisterService interface {
-	Register(ctx context.Context, email, password string, birthDate time.Time) (string, error)
+	Register(ctx context.Context, email, password string, birthDate time.Time) (auth.TokenPair, error)
 }
 
 type AuthenticateService interface {
-	Authenticate(ctx context.Context, email, password string) (string, error)
+	Authenticate(ctx context.Context, email, password, ip string) (auth.TokenPair, error)
+}
+
+type SecondFactorLoginService interface {
+	CompleteSecondFactor(ctx context.Context, challenge, code, ip string) (auth.TokenPair, error)
 }
 
 type FetchUsersService interface {
@@ -30,7 +38,9 @@ type UserHandler struct {
 }
 
 func NewUserHandlers(logger *zap.Logger) UserHandler {
-	return UserHandler{}
+	return UserHandler{
+		logger: logger,
+	}
 }
 
 func (h UserHandler) Register(regSrv RegisterService) func(http.ResponseWriter, *http.Request) {
@@ -53,13 +63,18 @@ func (h UserHandler) Register(regSrv RegisterService) func(http.ResponseWriter,
 			}
 			return
 		}
-		jwtStr, err := regSrv.Register(
+		tokens, err := regSrv.Register(
 			r.Context(),
 			requestBody.Email,
 			requestBody.Password,
 			requestBody.Birthdate,
 		)
 		if err != nil {
+			var weakPasswordErr services.ErrWeakPassword
+			if errors.As(err, &weakPasswordErr) {
+				writeWeakPassword(w, weakPasswordErr, h.logger)
+				return
+			}
 			var notUniqErr storage.ErrUserNotUniq
 			if errors.As(err, &notUniqErr) {
 				w.WriteHeader((http.StatusConflict))
@@ -74,8 +89,7 @@ func (h UserHandler) Register(regSrv RegisterService) func(http.ResponseWriter,
 			}
 			return
 		}
-		auth.SetJWTCookie(w, jwtStr)
-		w.WriteHeader(http.StatusOK)
+		writeTokens(w, r, tokens, h.logger)
 	}
 }
 
@@ -98,8 +112,32 @@ func (h UserHandler) Authenticate(authService AuthenticateService) func(http.Res
 			}
 			return
 		}
-		jwtStr, err := authService.Authenticate(r.Context(), requestBody.Email, requestBody.Password)
+		tokens, err := authService.Authenticate(r.Context(), requestBody.Email, requestBody.Password, clientIP(r))
 		if err != nil {
+			var throttledErr services.ErrTooManyLoginAttempts
+			if errors.As(err, &throttledErr) {
+				writeTooManyAttempts(w, throttledErr, h.logger)
+				return
+			}
+			if errors.Is(err, services.ErrUserDisabled) {
+				w.WriteHeader(http.StatusForbidden)
+				if err := encoder.Encode(err.Error()); err != nil {
+					h.logger.Info("failed to encode response", zap.Error(err))
+				}
+				return
+			}
+			var secondFactorErr services.ErrSecondFactorRequired
+			if errors.As(err, &secondFactorErr) {
+				type response struct {
+					MFARequired bool   `json:"mfa_required"`
+					MFAToken    string `json:"mfa_token"`
+				}
+				w.WriteHeader(http.StatusUnauthorized)
+				if err := encoder.Encode(response{MFARequired: true, MFAToken: secondFactorErr.ChallengeToken}); err != nil {
+					h.logger.Info("failed to encode response", zap.Error(err))
+				}
+				return
+			}
 			w.WriteHeader(http.StatusUnauthorized)
 			if err := encoder.Encode(err.Error()); err != nil {
 				h.logger.Info("failed to encode response", zap.Error(err))
@@ -107,11 +145,77 @@ func (h UserHandler) Authenticate(authService AuthenticateService) func(http.Res
 			return
 		}
 
-		auth.SetJWTCookie(w, jwtStr)
-		w.WriteHeader(http.StatusOK)
+		writeTokens(w, r, tokens, h.logger)
 	}
 }
 
+func (h UserHandler) AuthenticateSecondFactor(authService SecondFactorLoginService) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		type payload struct {
+			MFAToken string `json:"mfa_token"`
+			Code     string `json:"code"`
+		}
+		var requestBody payload
+		encoder := json.NewEncoder(w)
+		if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			if err := encoder.Encode("invalid request body"); err != nil {
+				h.logger.Info("failed to encode response", zap.Error(err))
+			}
+			return
+		}
+
+		tokens, err := authService.CompleteSecondFactor(r.Context(), requestBody.MFAToken, requestBody.Code, clientIP(r))
+		if err != nil {
+			var throttledErr services.ErrTooManyLoginAttempts
+			if errors.As(err, &throttledErr) {
+				writeTooManyAttempts(w, throttledErr, h.logger)
+				return
+			}
+			if errors.Is(err, services.ErrUserDisabled) {
+				w.WriteHeader(http.StatusForbidden)
+				if err := encoder.Encode(err.Error()); err != nil {
+					h.logger.Info("failed to encode response", zap.Error(err))
+				}
+				return
+			}
+			if errors.Is(err, services.ErrInvalidSecondFactorChallenge) || errors.Is(err, services.ErrInvalidTOTPCode) {
+				w.WriteHeader(http.StatusUnauthorized)
+				if err := encoder.Encode(err.Error()); err != nil {
+					h.logger.Info("failed to encode response", zap.Error(err))
+				}
+				return
+			}
+			h.logger.Info("failed to verify two-factor code", zap.Error(err))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		writeTokens(w, r, tokens, h.logger)
+	}
+}
+
+func writeTooManyAttempts(w http.ResponseWriter, err services.ErrTooManyLoginAttempts, logger *zap.Logger) {
+	seconds := int(math.Ceil(err.RetryAfter.Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	w.WriteHeader(http.StatusTooManyRequests)
+	if err := json.NewEncoder(w).Encode(err.Error()); err != nil {
+		logger.Info("failed to encode response", zap.Error(err))
+	}
+}
+
+// clientIP returns the address of the client. Behind a proxy RemoteAddr is
+// rewritten by the RealIP middleware.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
 func (h UserHandler) Get(fetchSrv FetchUsersService) func(http.ResponseWriter, *http.Request) {
 	return func(w http.ResponseWriter, r * http.Request) {
 		w.Header().Set("Content-Type", "application/json")
@@ -129,3 +233,31 @@ func (h UserHandler) Get(fetchSrv FetchUsersService) func(http.ResponseWriter, *
 		}
 	}
 }
+
+// writeWeakPassword responds with every violated password rule so that
+// clients can show them next to the password field.
+func writeWeakPassword(w http.ResponseWriter, err services.ErrWeakPassword, logger *zap.Logger) {
+	type response struct {
+		Error      string                   `json:"error"`
+		Violations []auth.PasswordViolation `json:"violations"`
+	}
+
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	if err := json.NewEncoder(w).Encode(response{Error: "weak password", Violations: err.Violations}); err != nil {
+		logger.Info("failed to encode response", zap.Error(err))
+	}
+}
+
+// writeTokens sets the auth cookies and, if the client asked for it, also
+// returns the tokens in the response body.
+func writeTokens(w http.ResponseWriter, r *http.Request, tokens auth.TokenPair, logger *zap.Logger) {
+	auth.SetAuthCookies(w, tokens)
+	w.WriteHeader(http.StatusOK)
+	if !auth.WantsTokenInBody(r) {
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(auth.NewTokenResponse(tokens)); err != nil {
+		logger.Info("failed to encode response", zap.Error(err))
+	}
+}
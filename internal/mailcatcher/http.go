@@ -0,0 +1,143 @@
+package mailcatcher
+
+import (
+	"encoding/json"
+	"errors"
+	"html/template"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+var indexTmpl = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html>
+<head><title>mailcatcher</title></head>
+<body>
+<h1>Messages</h1>
+<form method="post" action="/clear"><button type="submit">Clear</button></form>
+<table>
+<tr><th>ID</th><th>Received</th><th>From</th><th>To</th><th>Subject</th></tr>
+{{range .}}<tr>
+<td><a href="/messages/{{.ID}}">{{.ID}}</a></td>
+<td>{{.ReceivedAt.Format "2006-01-02 15:04:05"}}</td>
+<td>{{.From}}</td>
+<td>{{range $i, $to := .To}}{{if $i}}, {{end}}{{$to}}{{end}}</td>
+<td><a href="/messages/{{.ID}}">{{.Subject}}</a></td>
+</tr>{{else}}<tr><td colspan="5">No messages</td></tr>{{end}}
+</table>
+</body>
+</html>
+`))
+
+var messageTmpl = template.Must(template.New("message").Parse(`<!DOCTYPE html>
+<html>
+<head><title>{{.Subject}}</title></head>
+<body>
+<p><a href="/">&larr; all messages</a></p>
+<dl>
+<dt>From</dt><dd>{{.From}}</dd>
+<dt>To</dt><dd>{{range $i, $to := .To}}{{if $i}}, {{end}}{{$to}}{{end}}</dd>
+<dt>Subject</dt><dd>{{.Subject}}</dd>
+<dt>Received</dt><dd>{{.ReceivedAt.Format "2006-01-02 15:04:05"}}</dd>
+</dl>
+<h2>Body</h2>
+<pre>{{.Body}}</pre>
+<h2>Source</h2>
+<pre>{{.Raw}}</pre>
+</body>
+</html>
+`))
+
+type Handler struct {
+	logger *zap.Logger
+	store  *Store
+}
+
+func NewHandler(logger *zap.Logger, store *Store) Handler {
+	return Handler{
+		logger: logger,
+		store:  store,
+	}
+}
+
+func (h Handler) Router() chi.Router {
+	router := chi.NewRouter()
+	router.Get("/", h.index)
+	router.Get("/messages/{id}", h.show)
+	router.Post("/clear", h.clear)
+	router.Get("/api/messages", h.list)
+	router.Get("/api/messages/{id}", h.get)
+	router.Delete("/api/messages", h.deleteAll)
+
+	return router
+}
+
+func (h Handler) index(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := indexTmpl.Execute(w, h.store.List()); err != nil {
+		h.logger.Info("failed to render messages", zap.Error(err))
+	}
+}
+
+func (h Handler) show(w http.ResponseWriter, r *http.Request) {
+	msg, status := h.findMessage(r)
+	if status != http.StatusOK {
+		w.WriteHeader(status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := messageTmpl.Execute(w, msg); err != nil {
+		h.logger.Info("failed to render message", zap.Error(err))
+	}
+}
+
+func (h Handler) clear(w http.ResponseWriter, r *http.Request) {
+	h.store.Clear()
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+func (h Handler) list(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(h.store.List()); err != nil {
+		h.logger.Info("failed to encode response", zap.Error(err))
+	}
+}
+
+func (h Handler) get(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	msg, status := h.findMessage(r)
+	if status != http.StatusOK {
+		w.WriteHeader(status)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(msg); err != nil {
+		h.logger.Info("failed to encode response", zap.Error(err))
+	}
+}
+
+func (h Handler) deleteAll(w http.ResponseWriter, r *http.Request) {
+	h.store.Clear()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h Handler) findMessage(r *http.Request) (Message, int) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		return Message{}, http.StatusBadRequest
+	}
+
+	msg, err := h.store.Get(id)
+	if err != nil {
+		var notFoundErr ErrMessageNotFound
+		if errors.As(err, &notFoundErr) {
+			return Message{}, http.StatusNotFound
+		}
+		return Message{}, http.StatusInternalServerError
+	}
+
+	return msg, http.StatusOK
+}
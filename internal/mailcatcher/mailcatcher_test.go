@@ -0,0 +1,58 @@
+package mailcatcher_test
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ilya-burinskiy/birthday-notify/internal/configs"
+	"github.com/ilya-burinskiy/birthday-notify/internal/mailcatcher"
+	"github.com/ilya-burinskiy/birthday-notify/internal/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestCatchEmail(t *testing.T) {
+	listener, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+
+	store := mailcatcher.NewStore(10)
+	smtpServer := mailcatcher.NewSMTPServer(listener.Addr().String(), store, zap.NewNop())
+	go smtpServer.Serve(listener)
+	defer smtpServer.Close()
+
+	_, port, err := net.SplitHostPort(listener.Addr().String())
+	require.NoError(t, err)
+	sender := services.NewEmailSender(configs.Config{
+		SMTPHost:         "localhost",
+		SMTPPort:         port,
+		SMTPAuthUsername: "notifier@example.com",
+		SMTPAuthPassword: "password",
+	})
+	err = sender.Send("user@example.com", "Birthday notification", "The user bob@example.com has birthday in 1 days")
+	require.NoError(t, err)
+
+	httpServer := httptest.NewServer(mailcatcher.NewHandler(zap.NewNop(), store).Router())
+	defer httpServer.Close()
+
+	resp, err := http.Get(httpServer.URL + "/api/messages")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var messages []mailcatcher.Message
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&messages))
+	require.Len(t, messages, 1)
+	assert.Equal(t, "notifier@example.com", messages[0].From)
+	assert.Equal(t, []string{"user@example.com"}, messages[0].To)
+	assert.Equal(t, "Birthday notification", messages[0].Subject)
+	assert.Equal(t, "The user bob@example.com has birthday in 1 days", messages[0].Body)
+
+	resp, err = http.Get(httpServer.URL + "/api/messages/42")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
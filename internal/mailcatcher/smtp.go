@@ -0,0 +1,202 @@
+package mailcatcher
+
+import (
+	"errors"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// SMTPServer is a minimal SMTP listener that accepts every message and puts
+// it into the store. It advertises AUTH PLAIN/LOGIN and accepts any
+// credentials so that clients configured with SMTP auth can talk to it.
+type SMTPServer struct {
+	addr     string
+	hostname string
+	store    *Store
+	logger   *zap.Logger
+
+	mu       sync.Mutex
+	listener net.Listener
+}
+
+func NewSMTPServer(addr string, store *Store, logger *zap.Logger) *SMTPServer {
+	return &SMTPServer{
+		addr:     addr,
+		hostname: "mailcatcher",
+		store:    store,
+		logger:   logger,
+	}
+}
+
+func (srv *SMTPServer) ListenAndServe() error {
+	listener, err := net.Listen("tcp", srv.addr)
+	if err != nil {
+		return err
+	}
+	return srv.Serve(listener)
+}
+
+func (srv *SMTPServer) Serve(listener net.Listener) error {
+	srv.mu.Lock()
+	srv.listener = listener
+	srv.mu.Unlock()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go srv.handle(conn)
+	}
+}
+
+func (srv *SMTPServer) Close() error {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if srv.listener == nil {
+		return nil
+	}
+	return srv.listener.Close()
+}
+
+type smtpSession struct {
+	from string
+	to   []string
+}
+
+func (srv *SMTPServer) handle(netConn net.Conn) {
+	conn := textproto.NewConn(netConn)
+	defer conn.Close()
+
+	reply := func(code int, msg string) bool {
+		if err := conn.PrintfLine("%d %s", code, msg); err != nil {
+			srv.logger.Info("failed to write smtp reply", zap.Error(err))
+			return false
+		}
+		return true
+	}
+
+	if !reply(220, srv.hostname+" ESMTP mailcatcher") {
+		return
+	}
+
+	var session smtpSession
+	for {
+		line, err := conn.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "HELO":
+			session = smtpSession{}
+			reply(250, srv.hostname)
+		case "EHLO":
+			session = smtpSession{}
+			lines := []string{srv.hostname, "8BITMIME", "AUTH PLAIN LOGIN"}
+			for i, l := range lines {
+				sep := "-"
+				if i == len(lines)-1 {
+					sep = " "
+				}
+				if err := conn.PrintfLine("250%s%s", sep, l); err != nil {
+					return
+				}
+			}
+		case "AUTH":
+			if !srv.auth(conn, arg) {
+				return
+			}
+		case "MAIL":
+			session.from = extractAddress(arg)
+			session.to = nil
+			reply(250, "OK")
+		case "RCPT":
+			if session.from == "" {
+				reply(503, "need MAIL command")
+				continue
+			}
+			session.to = append(session.to, extractAddress(arg))
+			reply(250, "OK")
+		case "DATA":
+			if len(session.to) == 0 {
+				reply(503, "need RCPT command")
+				continue
+			}
+			if !reply(354, "end data with <CR><LF>.<CR><LF>") {
+				return
+			}
+			data, err := conn.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			msg := srv.store.Add(session.from, session.to, data)
+			srv.logger.Info("message received", zap.Int("id", msg.ID), zap.Strings("to", msg.To))
+			session = smtpSession{}
+			reply(250, "OK")
+		case "RSET":
+			session = smtpSession{}
+			reply(250, "OK")
+		case "NOOP":
+			reply(250, "OK")
+		case "QUIT":
+			reply(221, "bye")
+			return
+		default:
+			reply(502, "command not implemented")
+		}
+	}
+}
+
+func (srv *SMTPServer) auth(conn *textproto.Conn, arg string) bool {
+	mechanism, initial, _ := strings.Cut(arg, " ")
+	switch strings.ToUpper(mechanism) {
+	case "PLAIN":
+		if initial == "" {
+			if err := conn.PrintfLine("334 "); err != nil {
+				return false
+			}
+			if _, err := conn.ReadLine(); err != nil {
+				return false
+			}
+		}
+	case "LOGIN":
+		// base64 of "Username:" and "Password:"
+		for _, prompt := range []string{"VXNlcm5hbWU6", "UGFzc3dvcmQ6"} {
+			if err := conn.PrintfLine("334 %s", prompt); err != nil {
+				return false
+			}
+			if _, err := conn.ReadLine(); err != nil {
+				return false
+			}
+		}
+	default:
+		return conn.PrintfLine("504 unrecognized authentication type") == nil
+	}
+
+	return conn.PrintfLine("235 authentication successful") == nil
+}
+
+// extractAddress turns "FROM:<user@example.com> SIZE=10" into
+// "user@example.com".
+func extractAddress(arg string) string {
+	_, addr, found := strings.Cut(arg, ":")
+	if !found {
+		return ""
+	}
+	addr = strings.TrimSpace(addr)
+	if start := strings.Index(addr, "<"); start >= 0 {
+		if end := strings.Index(addr[start:], ">"); end >= 0 {
+			return addr[start+1 : start+end]
+		}
+	}
+	addr, _, _ = strings.Cut(addr, " ")
+	return addr
+}
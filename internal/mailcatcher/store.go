@@ -0,0 +1,104 @@
+package mailcatcher
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/mail"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Message struct {
+	ID         int       `json:"id"`
+	From       string    `json:"from"`
+	To         []string  `json:"to"`
+	Subject    string    `json:"subject"`
+	Body       string    `json:"body"`
+	Raw        string    `json:"raw"`
+	ReceivedAt time.Time `json:"received_at"`
+}
+
+type ErrMessageNotFound struct {
+	ID int
+}
+
+func (err ErrMessageNotFound) Error() string {
+	return fmt.Sprintf("message with id=%d not found", err.ID)
+}
+
+// Store keeps received messages in memory. When capacity is reached the
+// oldest message is dropped.
+type Store struct {
+	mu       sync.RWMutex
+	messages []Message
+	nextID   int
+	capacity int
+}
+
+func NewStore(capacity int) *Store {
+	return &Store{
+		nextID:   1,
+		capacity: capacity,
+	}
+}
+
+func (s *Store) Add(from string, to []string, raw []byte) Message {
+	msg := Message{
+		From:       from,
+		To:         to,
+		Raw:        string(raw),
+		ReceivedAt: time.Now().UTC(),
+	}
+	if parsed, err := mail.ReadMessage(strings.NewReader(msg.Raw)); err == nil {
+		msg.Subject = parsed.Header.Get("Subject")
+		if subject, err := new(mime.WordDecoder).DecodeHeader(msg.Subject); err == nil {
+			msg.Subject = subject
+		}
+		if body, err := io.ReadAll(parsed.Body); err == nil {
+			msg.Body = strings.TrimRight(string(body), "\r\n")
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	msg.ID = s.nextID
+	s.nextID++
+	s.messages = append(s.messages, msg)
+	if s.capacity > 0 && len(s.messages) > s.capacity {
+		s.messages = s.messages[len(s.messages)-s.capacity:]
+	}
+
+	return msg
+}
+
+// List returns messages, newest first.
+func (s *Store) List() []Message {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]Message, 0, len(s.messages))
+	for i := len(s.messages) - 1; i >= 0; i-- {
+		result = append(result, s.messages[i])
+	}
+	return result
+}
+
+func (s *Store) Get(id int) (Message, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, msg := range s.messages {
+		if msg.ID == id {
+			return msg, nil
+		}
+	}
+	return Message{}, ErrMessageNotFound{ID: id}
+}
+
+func (s *Store) Clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = nil
+}
@@ -2,40 +2,203 @@ package middlewares
 
 import (
 	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
 	"net/http"
 
-	"github.com/golang-jwt/jwt/v4"
 	"github.com/ilya-burinskiy/birthday-notify/internal/auth"
-	"github.com/ilya-burinskiy/birthday-notify/internal/configs"
+	"github.com/ilya-burinskiy/birthday-notify/internal/models"
+	"github.com/ilya-burinskiy/birthday-notify/internal/services"
 )
 
 type ctxKey string
 
-const userIDKey ctxKey = "user_id"
+const (
+	userIDKey    ctxKey = "user_id"
+	sessionIDKey ctxKey = "session_id"
+	scopesKey    ctxKey = "scopes"
+)
 
-func Authenticate(h http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		cookie, err := r.Cookie("jwt")
-		if err != nil {
-			w.WriteHeader(http.StatusUnauthorized)
-			return
-		}
+type TokenParser interface {
+	ParseJWTString(tokenString string) (auth.Claims, error)
+}
+
+type SessionChecker interface {
+	IsSessionActive(ctx context.Context, sessionID int) (bool, error)
+}
+
+type APIKeyAuthenticator interface {
+	AuthenticateAPIKey(ctx context.Context, key string) (models.APIKey, error)
+}
+
+// Authenticate accepts requests with a valid access token whose session has
+// not been revoked, or with a valid API key. The token is read from the
+// "Authorization: Bearer" header or from the jwt cookie.
+func Authenticate(
+	parser TokenParser,
+	checker SessionChecker,
+	keys APIKeyAuthenticator,
+) func(http.Handler) http.Handler {
+
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, ok := auth.AccessTokenFromRequest(r)
+			if !ok {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+
+			if auth.IsAPIKey(token) {
+				apiKey, err := keys.AuthenticateAPIKey(r.Context(), token)
+				if err != nil {
+					if errors.Is(err, services.ErrInvalidAPIKey) {
+						w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+						w.WriteHeader(http.StatusUnauthorized)
+						return
+					}
+					w.WriteHeader(http.StatusInternalServerError)
+					return
+				}
+
+				ctx := context.WithValue(r.Context(), userIDKey, apiKey.UserID)
+				ctx = context.WithValue(ctx, scopesKey, apiKey.Scopes)
+				h.ServeHTTP(w, r.WithContext(ctx))
+				return
+			}
+
+			claims, err := parser.ParseJWTString(token)
+			if err != nil || claims.SessionID == 0 {
+				w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+
+			active, err := checker.IsSessionActive(r.Context(), claims.SessionID)
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			if !active {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), userIDKey, claims.UserID)
+			ctx = context.WithValue(ctx, sessionIDKey, claims.SessionID)
+			h.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+func UserIDFromContext(ctx context.Context) (int, bool) {
+	userID, ok := ctx.Value(userIDKey).(int)
+	return userID, ok
+}
 
-		claims := &auth.Claims{}
-		token, err := jwt.ParseWithClaims(cookie.Value, claims, func(token *jwt.Token) (interface{}, error) {
-			return []byte(configs.SecretKey), nil
+func SessionIDFromContext(ctx context.Context) (int, bool) {
+	sessionID, ok := ctx.Value(sessionIDKey).(int)
+	return sessionID, ok
+}
+
+// RequireScope rejects requests authenticated with an API key that was not
+// granted the scope. Requests authenticated with a session are allowed.
+func RequireScope(scope string) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			scopes, ok := r.Context().Value(scopesKey).([]string)
+			if ok && !hasScope(scopes, scope) {
+				w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer error="insufficient_scope", scope=%q`, scope))
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+
+			h.ServeHTTP(w, r)
 		})
-		if err != nil || !token.Valid {
-			w.WriteHeader(http.StatusUnauthorized)
+	}
+}
+
+// RequireSession rejects requests authenticated with an API key, e.g. to
+// prevent API keys from creating new API keys.
+func RequireSession(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := SessionIDFromContext(r.Context()); !ok {
+			w.WriteHeader(http.StatusForbidden)
 			return
 		}
 
-		ctx := context.WithValue(r.Context(), userIDKey, claims.UserID)
-		h.ServeHTTP(w, r.WithContext(ctx))
+		h.ServeHTTP(w, r)
 	})
 }
 
-func UserIDFromContext(ctx context.Context) (int, bool) {
-	userID, ok := ctx.Value(userIDKey).(int)
-	return userID, ok
+func hasScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// RequireAdminToken allows the request only if the X-Admin-Token header
+// matches token. An empty token disables the protected routes entirely.
+func RequireAdminToken(token string) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if token == "" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			provided := r.Header.Get("X-Admin-Token")
+			if subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+type UserFinder interface {
+	FindUserByID(ctx context.Context, userID int) (models.User, error)
+}
+
+// RequireRole allows the request only if the authenticated user has the
+// role and is not disabled. The role is read from the storage on every
+// request, so a demoted user loses access immediately.
+func RequireRole(users UserFinder, role string) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userID, ok := UserIDFromContext(r.Context())
+			if !ok {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			user, err := users.FindUserByID(r.Context(), userID)
+			if err != nil || user.Role != role || user.DisabledAt != nil {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireAdmin accepts the X-Admin-Token header, meant for scripts, or a
+// session of a user with the admin role.
+func RequireAdmin(token string, authenticate func(http.Handler) http.Handler, users UserFinder) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		byToken := RequireAdminToken(token)(h)
+		byRole := authenticate(RequireSession(RequireRole(users, models.RoleAdmin)(h)))
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("X-Admin-Token") != "" {
+				byToken.ServeHTTP(w, r)
+				return
+			}
+			byRole.ServeHTTP(w, r)
+		})
+	}
 }
@@ -0,0 +1,10 @@
+package models
+
+import "time"
+
+// Identity links a user to an account at a single sign-on provider.
+type Identity struct {
+	Issuer    string    `json:"issuer"`
+	Subject   string    `json:"subject"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+const (
+	LoginScopeAccount = "account"
+	LoginScopeIP      = "ip"
+	// password reset requests are counted in the same table
+	PasswordResetScopeAccount = "reset_account"
+	PasswordResetScopeIP      = "reset_ip"
+)
+
+// LoginThrottle counts failed logins for an account or a client address.
+type LoginThrottle struct {
+	Scope        string
+	Key          string
+	Failures     int
+	LastFailedAt time.Time
+	LockedUntil  *time.Time
+}
+
+// LoginLockout is an audit record of a temporary lockout.
+type LoginLockout struct {
+	ID          int        `json:"id"`
+	Scope       string     `json:"scope"`
+	Key         string     `json:"key"`
+	IP          string     `json:"ip"`
+	Failures    int        `json:"failures"`
+	LockedAt    time.Time  `json:"locked_at"`
+	LockedUntil time.Time  `json:"locked_until"`
+	UnlockedAt  *time.Time `json:"unlocked_at,omitempty"`
+}
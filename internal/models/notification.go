@@ -1,7 +1,26 @@
 package models
 
+import "time"
+
+// Notification tells the subscribing user about the birthday of the
+// subscribed user. The names are display names as returned by User.Name.
 type Notification struct {
-	SubscribingUserEmail string
-	DaysBeforeNotify     int
-	SubscribedUserEmail  string
+	SubscribingUserID    int    `json:"subscribing_user_id"`
+	SubscribingUserEmail string `json:"subscribing_user_email"`
+	SubscribingUserName  string `json:"subscribing_user_name"`
+	DaysBeforeNotify     int    `json:"days_before_notify"`
+	SubscribedUserID     int    `json:"subscribed_user_id"`
+	SubscribedUserEmail  string `json:"subscribed_user_email"`
+	SubscribedUserName   string `json:"subscribed_user_name"`
+}
+
+// NotificationRecord is a sent notification. SubscribedUserID is nil after
+// the user whose birthday it was deleted the account.
+type NotificationRecord struct {
+	ID               int       `json:"id"`
+	RecipientUserID  int       `json:"recipient_user_id"`
+	SubscribedUserID *int      `json:"subscribed_user_id"`
+	DaysBeforeNotify int       `json:"days_before_notify"`
+	NotifyDate       time.Time `json:"notify_date"`
+	SentAt           time.Time `json:"sent_at"`
 }
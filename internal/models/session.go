@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+type Session struct {
+	ID        int        `json:"id"`
+	UserID    int        `json:"user_id"`
+	CreatedAt time.Time  `json:"created_at"`
+	RevokedAt *time.Time `json:"revoked_at"`
+}
+
+type RefreshToken struct {
+	ID        int
+	SessionID int
+	UserID    int
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	// SessionRevoked is true when the session the token belongs to was revoked.
+	SessionRevoked bool
+}
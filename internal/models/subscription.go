@@ -1,7 +1,7 @@
 package models
 
 type Subscription struct {
-	ID                int
-	SubscribedUserID  int
-	SubscribingUserID int
+	ID                int `json:"id"`
+	SubscribedUserID  int `json:"subscribed_user_id"`
+	SubscribingUserID int `json:"subscribing_user_id"`
 }
@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// TOTP is the two-factor authentication secret of a user. It is pending
+// until the user confirms the enrollment with a code.
+type TOTP struct {
+	UserID    int
+	Secret    string
+	EnabledAt *time.Time
+	LastStep  *int64
+}
@@ -1,10 +1,72 @@
 package models
 
-import "time"
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
 
 type User struct {
-	ID                int       `json:"id"`
-	Email             string    `json:"email"`
-	EncryptedPassword []byte    `json:"-"`
-	BirthDate         time.Time `json:"birthdate"`
+	ID                int    `json:"id"`
+	Email             string `json:"email"`
+	EncryptedPassword []byte `json:"-"`
+	// BirthDate is nil for users who signed in with single sign-on and have
+	// not set it yet.
+	BirthDate *time.Time `json:"birthdate"`
+	// EmailVerifiedAt is nil until the user confirms the email address.
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty"`
+	// PendingEmail replaces Email once the user confirms it.
+	PendingEmail *string `json:"pending_email,omitempty"`
+	Role         string  `json:"role,omitempty"`
+	// DisabledAt is set when an administrator disabled the account.
+	DisabledAt *time.Time `json:"disabled_at,omitempty"`
+
+	FirstName   string `json:"first_name"`
+	LastName    string `json:"last_name"`
+	DisplayName string `json:"display_name"`
+	Bio         string `json:"bio,omitempty"`
+	// Photo is the file name of the profile photo, PhotoURL the path it is
+	// served at. Both are empty when the user has no photo.
+	Photo    string `json:"-"`
+	PhotoURL string `json:"photo_url,omitempty"`
+}
+
+// Name returns how the user is shown to other users.
+func (u User) Name() string {
+	return DisplayName(u.DisplayName, u.FirstName, u.LastName, u.Email)
+}
+
+// DisplayName returns the display name, the full name or, if neither is
+// set, the email.
+func DisplayName(displayName, firstName, lastName, email string) string {
+	if name := strings.TrimSpace(displayName); name != "" {
+		return name
+	}
+	if name := strings.TrimSpace(firstName + " " + lastName); name != "" {
+		return name
+	}
+	return email
+}
+
+// PhotoPath is the API path serving the profile photo of the user.
+func PhotoPath(userID int) string {
+	return fmt.Sprintf("/api/users/%d/photo", userID)
+}
+
+// UserUpdate holds the profile fields to change, nil fields are left as is.
+type UserUpdate struct {
+	BirthDate   *time.Time
+	FirstName   *string
+	LastName    *string
+	DisplayName *string
+	Bio         *string
+}
+
+func IsKnownRole(role string) bool {
+	return role == RoleUser || role == RoleAdmin
 }
@@ -0,0 +1,278 @@
+// Package oidc implements the relying party side of the OpenID Connect
+// authorization code flow with PKCE.
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// Metadata is the part of the provider discovery document the client uses.
+type Metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Identity is the verified end-user identity from an ID token.
+type Identity struct {
+	Issuer        string
+	Subject       string
+	Email         string
+	EmailVerified bool
+}
+
+// AuthRequest holds per-login secrets that must survive the redirect to the
+// provider and back.
+type AuthRequest struct {
+	State        string
+	Nonce        string
+	CodeVerifier string
+}
+
+type Client struct {
+	issuer       string
+	clientID     string
+	clientSecret string
+	redirectURL  string
+	scopes       []string
+	httpClient   *http.Client
+
+	mu       sync.Mutex
+	metadata *Metadata
+	keys     map[string]interface{}
+}
+
+func NewClient(issuer, clientID, clientSecret, redirectURL string, scopes []string) *Client {
+	return &Client{
+		issuer:       strings.TrimSuffix(issuer, "/"),
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		redirectURL:  redirectURL,
+		scopes:       scopes,
+		httpClient:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func NewAuthRequest() (AuthRequest, error) {
+	var values [3]string
+	for i := range values {
+		buf := make([]byte, 32)
+		if _, err := rand.Read(buf); err != nil {
+			return AuthRequest{}, fmt.Errorf("failed to generate auth request: %w", err)
+		}
+		values[i] = base64.RawURLEncoding.EncodeToString(buf)
+	}
+
+	return AuthRequest{State: values[0], Nonce: values[1], CodeVerifier: values[2]}, nil
+}
+
+// AuthCodeURL returns the provider URL the user is redirected to.
+func (c *Client) AuthCodeURL(ctx context.Context, req AuthRequest) (string, error) {
+	metadata, err := c.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	challenge := sha256.Sum256([]byte(req.CodeVerifier))
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {c.clientID},
+		"redirect_uri":          {c.redirectURL},
+		"scope":                 {strings.Join(c.scopes, " ")},
+		"state":                 {req.State},
+		"nonce":                 {req.Nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+	separator := "?"
+	if strings.Contains(metadata.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+
+	return metadata.AuthorizationEndpoint + separator + query.Encode(), nil
+}
+
+// Exchange redeems the authorization code and verifies the returned ID
+// token against the provider keys and the nonce of the request.
+func (c *Client) Exchange(ctx context.Context, req AuthRequest, code string) (Identity, error) {
+	metadata, err := c.discover(ctx)
+	if err != nil {
+		return Identity{}, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {c.redirectURL},
+		"client_id":     {c.clientID},
+		"code_verifier": {req.CodeVerifier},
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return Identity{}, fmt.Errorf("failed to exchange code: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	httpReq.Header.Set("Accept", "application/json")
+	if c.clientSecret != "" {
+		httpReq.SetBasicAuth(url.QueryEscape(c.clientID), url.QueryEscape(c.clientSecret))
+	}
+
+	var tokenResp struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := c.doJSON(httpReq, &tokenResp); err != nil {
+		return Identity{}, fmt.Errorf("failed to exchange code: %w", err)
+	}
+	if tokenResp.IDToken == "" {
+		return Identity{}, errors.New("failed to exchange code: no id_token in response")
+	}
+
+	return c.verifyIDToken(ctx, tokenResp.IDToken, req.Nonce)
+}
+
+type idTokenClaims struct {
+	jwt.RegisteredClaims
+	Nonce         string `json:"nonce"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+}
+
+func (c *Client) verifyIDToken(ctx context.Context, rawToken, nonce string) (Identity, error) {
+	claims := idTokenClaims{}
+	parser := jwt.NewParser(jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "ES256", "ES384", "EdDSA"}))
+	_, err := parser.ParseWithClaims(rawToken, &claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return c.key(ctx, kid)
+	})
+	if err != nil {
+		return Identity{}, fmt.Errorf("invalid id token: %w", err)
+	}
+	if claims.Issuer != c.issuer {
+		return Identity{}, fmt.Errorf("invalid id token: unexpected issuer %q", claims.Issuer)
+	}
+	if !claims.VerifyAudience(c.clientID, true) {
+		return Identity{}, errors.New("invalid id token: unexpected audience")
+	}
+	if claims.ExpiresAt == nil {
+		return Identity{}, errors.New("invalid id token: missing exp")
+	}
+	if claims.Nonce != nonce {
+		return Identity{}, errors.New("invalid id token: nonce mismatch")
+	}
+	if claims.Subject == "" {
+		return Identity{}, errors.New("invalid id token: missing sub")
+	}
+
+	return Identity{
+		Issuer:        claims.Issuer,
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: claims.EmailVerified,
+	}, nil
+}
+
+// discover fetches the provider metadata on first use, so that the API
+// starts even when the provider is temporarily unavailable.
+func (c *Client) discover(ctx context.Context) (Metadata, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.metadata != nil {
+		return *c.metadata, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.issuer+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return Metadata{}, fmt.Errorf("failed to discover oidc provider: %w", err)
+	}
+	var metadata Metadata
+	if err := c.doJSON(req, &metadata); err != nil {
+		return Metadata{}, fmt.Errorf("failed to discover oidc provider: %w", err)
+	}
+	if metadata.Issuer != c.issuer {
+		return Metadata{}, fmt.Errorf("failed to discover oidc provider: issuer %q does not match %q", metadata.Issuer, c.issuer)
+	}
+	if metadata.AuthorizationEndpoint == "" || metadata.TokenEndpoint == "" || metadata.JWKSURI == "" {
+		return Metadata{}, errors.New("failed to discover oidc provider: incomplete metadata")
+	}
+
+	c.metadata = &metadata
+	return metadata, nil
+}
+
+// key returns the provider key by id. Keys are fetched again when an
+// unknown id appears, which happens after the provider rotates its keys.
+func (c *Client) key(ctx context.Context, kid string) (interface{}, error) {
+	c.mu.Lock()
+	key, ok := c.lookupKey(kid)
+	c.mu.Unlock()
+	if ok {
+		return key, nil
+	}
+
+	metadata, err := c.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, metadata.JWKSURI, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch provider keys: %w", err)
+	}
+	var set jsonWebKeySet
+	if err := c.doJSON(req, &set); err != nil {
+		return nil, fmt.Errorf("failed to fetch provider keys: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.keys = set.publicKeys()
+	key, ok = c.lookupKey(kid)
+	if !ok {
+		return nil, fmt.Errorf("unknown provider key %q", kid)
+	}
+	return key, nil
+}
+
+// lookupKey must be called with c.mu held. A token without kid is accepted
+// only if the provider publishes exactly one key.
+func (c *Client) lookupKey(kid string) (interface{}, bool) {
+	if kid == "" && len(c.keys) == 1 {
+		for _, key := range c.keys {
+			return key, true
+		}
+	}
+	key, ok := c.keys[kid]
+	return key, ok
+}
+
+func (c *Client) doJSON(req *http.Request, v interface{}) error {
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s %s: unexpected status %d: %s", req.Method, req.URL, resp.StatusCode, body)
+	}
+
+	return json.Unmarshal(body, v)
+}
@@ -0,0 +1,145 @@
+package oidc_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/ilya-burinskiy/birthday-notify/internal/oidc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockProvider is a minimal OpenID provider that issues an ID token for a
+// single authorization code.
+type mockProvider struct {
+	server   *httptest.Server
+	key      *rsa.PrivateKey
+	audience string
+	// nonce and code challenge of the last authorization request
+	nonce     string
+	challenge string
+}
+
+func newMockProvider(t *testing.T) *mockProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	provider := &mockProvider{key: key, audience: "client-id"}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 provider.server.URL,
+			"authorization_endpoint": provider.server.URL + "/authorize",
+			"token_endpoint":         provider.server.URL + "/token",
+			"jwks_uri":               provider.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "idp-key",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		clientID, clientSecret, _ := r.BasicAuth()
+		verifier := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+		if r.PostFormValue("code") != "code" || clientID != "client-id" || clientSecret != "client-secret" ||
+			base64.RawURLEncoding.EncodeToString(verifier[:]) != provider.challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+			"iss":            provider.server.URL,
+			"sub":            "user-1",
+			"aud":            provider.audience,
+			"exp":            time.Now().Add(time.Hour).Unix(),
+			"nonce":          provider.nonce,
+			"email":          "email@example.com",
+			"email_verified": true,
+		})
+		token.Header["kid"] = "idp-key"
+		idToken, err := token.SignedString(key)
+		require.NoError(t, err)
+		json.NewEncoder(w).Encode(map[string]string{"id_token": idToken, "token_type": "Bearer"})
+	})
+	provider.server = httptest.NewServer(mux)
+	t.Cleanup(provider.server.Close)
+
+	return provider
+}
+
+// authorize simulates the user signing in at the provider.
+func (p *mockProvider) authorize(t *testing.T, authURL string) {
+	u, err := url.Parse(authURL)
+	require.NoError(t, err)
+	assert.Equal(t, p.server.URL+"/authorize", u.Scheme+"://"+u.Host+u.Path)
+	assert.Equal(t, "S256", u.Query().Get("code_challenge_method"))
+	assert.Equal(t, "openid email", u.Query().Get("scope"))
+	p.nonce = u.Query().Get("nonce")
+	p.challenge = u.Query().Get("code_challenge")
+}
+
+func TestClientLogin(t *testing.T) {
+	provider := newMockProvider(t)
+	client := oidc.NewClient(
+		provider.server.URL,
+		"client-id",
+		"client-secret",
+		"http://localhost:8000/api/auth/oidc/callback",
+		[]string{"openid", "email"},
+	)
+
+	req, err := oidc.NewAuthRequest()
+	require.NoError(t, err)
+	authURL, err := client.AuthCodeURL(context.TODO(), req)
+	require.NoError(t, err)
+	provider.authorize(t, authURL)
+
+	identity, err := client.Exchange(context.TODO(), req, "code")
+	require.NoError(t, err)
+	assert.Equal(t, oidc.Identity{
+		Issuer:        provider.server.URL,
+		Subject:       "user-1",
+		Email:         "email@example.com",
+		EmailVerified: true,
+	}, identity)
+
+	t.Run("rejects wrong code verifier", func(t *testing.T) {
+		otherReq := req
+		otherReq.CodeVerifier = "other"
+		_, err := client.Exchange(context.TODO(), otherReq, "code")
+		assert.ErrorContains(t, err, "invalid_grant")
+	})
+
+	t.Run("rejects nonce mismatch", func(t *testing.T) {
+		otherReq := req
+		otherReq.Nonce = "other"
+		_, err := client.Exchange(context.TODO(), otherReq, "code")
+		assert.ErrorContains(t, err, "nonce mismatch")
+	})
+
+	t.Run("rejects token for another client", func(t *testing.T) {
+		provider.audience = "other-client"
+		defer func() { provider.audience = "client-id" }()
+		_, err := client.Exchange(context.TODO(), req, "code")
+		assert.ErrorContains(t, err, "unexpected audience")
+	})
+}
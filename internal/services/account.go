@@ -0,0 +1,151 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ilya-burinskiy/birthday-notify/internal/models"
+	"github.com/ilya-burinskiy/birthday-notify/internal/storage"
+	"go.uber.org/zap"
+)
+
+type AccountStorage interface {
+	FindUserByID(ctx context.Context, userID int) (models.User, error)
+	DeleteUser(ctx context.Context, userID int) error
+	ListUserIdentities(ctx context.Context, userID int) ([]models.Identity, error)
+	ListUserAPIKeys(ctx context.Context, userID int) ([]models.APIKey, error)
+	FindTOTP(ctx context.Context, userID int) (models.TOTP, error)
+	ListUserSubscriptions(ctx context.Context, userID int) ([]models.Subscription, error)
+	FindUserNotificationSetting(ctx context.Context, userID int) (models.NotifySetting, error)
+	ListNotificationHistory(ctx context.Context, userID int) ([]models.NotificationRecord, error)
+	ListAccountLoginLockouts(ctx context.Context, email string) ([]models.LoginLockout, error)
+}
+
+// AccountExport is everything stored about a user. Secrets (the password,
+// API keys and the TOTP secret) are stored as hashes or are not exported.
+type AccountExport struct {
+	ExportedAt       time.Time                   `json:"exported_at"`
+	Profile          models.User                 `json:"profile"`
+	Identities       []models.Identity           `json:"identities"`
+	APIKeys          []models.APIKey             `json:"api_keys"`
+	TwoFactorEnabled bool                        `json:"two_factor_enabled"`
+	Subscriptions    UserSubscriptions           `json:"subscriptions"`
+	NotifySetting    *models.NotifySetting       `json:"notify_setting"`
+	Notifications    []models.NotificationRecord `json:"notifications"`
+	LoginLockouts    []models.LoginLockout       `json:"login_lockouts"`
+}
+
+// AccountService lets users delete their account and export their data.
+type AccountService struct {
+	logger  *zap.Logger
+	storage AccountStorage
+	photos  PhotoStore
+	limiter LoginLimiter
+}
+
+func NewAccountService(logger *zap.Logger, storage AccountStorage, photos PhotoStore, limiter LoginLimiter) AccountService {
+	return AccountService{
+		logger:  logger,
+		storage: storage,
+		photos:  photos,
+		limiter: limiter,
+	}
+}
+
+// Delete deletes the account. Users with a password have to confirm the
+// deletion with it, users who signed up with single sign-on only have one
+// with the session. Wrong passwords count as failed logins.
+func (srv AccountService) Delete(ctx context.Context, userID int, ip, password string) error {
+	user, err := srv.storage.FindUserByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete account: %w", err)
+	}
+	if len(user.EncryptedPassword) > 0 {
+		if err := checkCurrentPassword(ctx, srv.limiter, user, ip, password); err != nil {
+			return err
+		}
+	}
+
+	if err := srv.storage.DeleteUser(ctx, userID); err != nil {
+		return fmt.Errorf("failed to delete account: %w", err)
+	}
+	deletePhoto(srv.logger, srv.photos, user)
+	return nil
+}
+
+// deletePhoto deletes the photo file of a deleted user. The account is gone
+// at this point, so a failure is only logged: the file is wasted space, and
+// reporting an error would make the client retry a deletion that succeeded.
+func deletePhoto(logger *zap.Logger, photos PhotoStore, user models.User) {
+	if user.Photo == "" {
+		return
+	}
+	if err := photos.Delete(user.Photo); err != nil {
+		logger.Info("failed to delete photo of deleted user", zap.Int("user_id", user.ID), zap.Error(err))
+	}
+}
+
+func (srv AccountService) Export(ctx context.Context, userID int) (AccountExport, error) {
+	user, err := srv.storage.FindUserByID(ctx, userID)
+	if err != nil {
+		return AccountExport{}, fmt.Errorf("failed to export account: %w", err)
+	}
+	export := AccountExport{
+		ExportedAt:    time.Now().UTC(),
+		Profile:       user,
+		Identities:    []models.Identity{},
+		APIKeys:       []models.APIKey{},
+		Notifications: []models.NotificationRecord{},
+		LoginLockouts: []models.LoginLockout{},
+	}
+
+	identities, err := srv.storage.ListUserIdentities(ctx, userID)
+	if err != nil {
+		return AccountExport{}, fmt.Errorf("failed to export account: %w", err)
+	}
+	export.Identities = append(export.Identities, identities...)
+
+	apiKeys, err := srv.storage.ListUserAPIKeys(ctx, userID)
+	if err != nil {
+		return AccountExport{}, fmt.Errorf("failed to export account: %w", err)
+	}
+	export.APIKeys = append(export.APIKeys, apiKeys...)
+
+	totp, err := srv.storage.FindTOTP(ctx, userID)
+	var totpNotFoundErr storage.ErrTOTPNotFound
+	if err != nil && !errors.As(err, &totpNotFoundErr) {
+		return AccountExport{}, fmt.Errorf("failed to export account: %w", err)
+	}
+	export.TwoFactorEnabled = err == nil && totp.EnabledAt != nil
+
+	subscriptions, err := srv.storage.ListUserSubscriptions(ctx, userID)
+	if err != nil {
+		return AccountExport{}, fmt.Errorf("failed to export account: %w", err)
+	}
+	export.Subscriptions = splitSubscriptions(userID, subscriptions)
+
+	notifySetting, err := srv.storage.FindUserNotificationSetting(ctx, userID)
+	var settingNotFoundErr storage.ErrNotifySettingNotFound
+	if err != nil && !errors.As(err, &settingNotFoundErr) {
+		return AccountExport{}, fmt.Errorf("failed to export account: %w", err)
+	}
+	if err == nil {
+		export.NotifySetting = &notifySetting
+	}
+
+	notifications, err := srv.storage.ListNotificationHistory(ctx, userID)
+	if err != nil {
+		return AccountExport{}, fmt.Errorf("failed to export account: %w", err)
+	}
+	export.Notifications = append(export.Notifications, notifications...)
+
+	lockouts, err := srv.storage.ListAccountLoginLockouts(ctx, user.Email)
+	if err != nil {
+		return AccountExport{}, fmt.Errorf("failed to export account: %w", err)
+	}
+	export.LoginLockouts = append(export.LoginLockouts, lockouts...)
+
+	return export, nil
+}
@@ -0,0 +1,183 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ilya-burinskiy/birthday-notify/internal/models"
+	"github.com/ilya-burinskiy/birthday-notify/internal/services"
+	"github.com/ilya-burinskiy/birthday-notify/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type accountStorage struct{ mock.Mock }
+
+func (s *accountStorage) FindUserByID(ctx context.Context, userID int) (models.User, error) {
+	args := s.Called(ctx, userID)
+	return args.Get(0).(models.User), args.Error(1)
+}
+
+func (s *accountStorage) DeleteUser(ctx context.Context, userID int) error {
+	args := s.Called(ctx, userID)
+	return args.Error(0)
+}
+
+func (s *accountStorage) ListUserIdentities(ctx context.Context, userID int) ([]models.Identity, error) {
+	args := s.Called(ctx, userID)
+	return args.Get(0).([]models.Identity), args.Error(1)
+}
+
+func (s *accountStorage) ListUserAPIKeys(ctx context.Context, userID int) ([]models.APIKey, error) {
+	args := s.Called(ctx, userID)
+	return args.Get(0).([]models.APIKey), args.Error(1)
+}
+
+func (s *accountStorage) FindTOTP(ctx context.Context, userID int) (models.TOTP, error) {
+	args := s.Called(ctx, userID)
+	return args.Get(0).(models.TOTP), args.Error(1)
+}
+
+func (s *accountStorage) ListUserSubscriptions(ctx context.Context, userID int) ([]models.Subscription, error) {
+	args := s.Called(ctx, userID)
+	return args.Get(0).([]models.Subscription), args.Error(1)
+}
+
+func (s *accountStorage) FindUserNotificationSetting(ctx context.Context, userID int) (models.NotifySetting, error) {
+	args := s.Called(ctx, userID)
+	return args.Get(0).(models.NotifySetting), args.Error(1)
+}
+
+func (s *accountStorage) ListNotificationHistory(ctx context.Context, userID int) ([]models.NotificationRecord, error) {
+	args := s.Called(ctx, userID)
+	return args.Get(0).([]models.NotificationRecord), args.Error(1)
+}
+
+func (s *accountStorage) ListAccountLoginLockouts(ctx context.Context, email string) ([]models.LoginLockout, error) {
+	args := s.Called(ctx, email)
+	return args.Get(0).([]models.LoginLockout), args.Error(1)
+}
+
+func TestAccountDelete(t *testing.T) {
+	testCases := []struct {
+		name       string
+		user       models.User
+		password   string
+		photoErr   error
+		wantErr    error
+		wantDelete bool
+	}{
+		{
+			name:       "deletes account confirmed with password",
+			user:       models.User{ID: 1, EncryptedPassword: hashPassword(t, "correct horse battery")},
+			password:   "correct horse battery",
+			wantDelete: true,
+		},
+		{
+			name:     "rejects wrong password",
+			user:     models.User{ID: 1, EncryptedPassword: hashPassword(t, "correct horse battery")},
+			password: "wrong",
+			wantErr:  services.ErrWrongPassword,
+		},
+		{
+			name:       "deletes account without password",
+			user:       models.User{ID: 1},
+			wantDelete: true,
+		},
+		{
+			name:       "deletes account with photo",
+			user:       models.User{ID: 1, Photo: "1-abc.png"},
+			wantDelete: true,
+		},
+		{
+			name:       "deletes account if photo file can not be deleted",
+			user:       models.User{ID: 1, Photo: "1-abc.png"},
+			photoErr:   errors.New("permission denied"),
+			wantDelete: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := new(accountStorage)
+			store.On("FindUserByID", mock.Anything, 1).Return(tc.user, nil)
+			store.On("DeleteUser", mock.Anything, 1).Return(nil)
+			photos := new(photoStore)
+			photos.On("Delete", mock.Anything).Return(tc.photoErr)
+			limiter := newLoginLimiter()
+			accountSrv := services.NewAccountService(zap.NewNop(), store, photos, limiter)
+
+			err := accountSrv.Delete(context.TODO(), 1, "127.0.0.1", tc.password)
+			if tc.wantErr == nil {
+				require.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tc.wantErr)
+				limiter.AssertCalled(t, "Fail", mock.Anything, tc.user.Email, "127.0.0.1")
+			}
+			if tc.wantDelete {
+				store.AssertCalled(t, "DeleteUser", mock.Anything, 1)
+			} else {
+				store.AssertNotCalled(t, "DeleteUser", mock.Anything, mock.Anything)
+			}
+			if tc.wantDelete && tc.user.Photo != "" {
+				photos.AssertCalled(t, "Delete", tc.user.Photo)
+			} else {
+				photos.AssertNotCalled(t, "Delete", mock.Anything)
+			}
+		})
+	}
+}
+
+func TestAccountDeleteThrottled(t *testing.T) {
+	user := models.User{ID: 1, Email: "email@example.com", EncryptedPassword: hashPassword(t, "correct horse battery")}
+	store := new(accountStorage)
+	store.On("FindUserByID", mock.Anything, 1).Return(user, nil)
+	limiter := new(loginLimiter)
+	limiter.On("Check", mock.Anything, "email@example.com", "127.0.0.1").
+		Return(services.ErrTooManyLoginAttempts{RetryAfter: time.Minute})
+	accountSrv := services.NewAccountService(zap.NewNop(), store, new(photoStore), limiter)
+
+	err := accountSrv.Delete(context.TODO(), 1, "127.0.0.1", "correct horse battery")
+	var throttledErr services.ErrTooManyLoginAttempts
+	assert.ErrorAs(t, err, &throttledErr)
+	store.AssertNotCalled(t, "DeleteUser", mock.Anything, mock.Anything)
+}
+
+func TestAccountExport(t *testing.T) {
+	enabledAt := time.Now()
+	subscribedUserID := 3
+	store := new(accountStorage)
+	store.On("FindUserByID", mock.Anything, 1).Return(models.User{ID: 1, Email: "alice@example.com"}, nil)
+	store.On("ListUserIdentities", mock.Anything, 1).Return([]models.Identity(nil), nil)
+	store.On("ListUserAPIKeys", mock.Anything, 1).Return([]models.APIKey{{ID: 1, UserID: 1, Name: "ci"}}, nil)
+	store.On("FindTOTP", mock.Anything, 1).Return(models.TOTP{UserID: 1, EnabledAt: &enabledAt}, nil)
+	store.On("ListUserSubscriptions", mock.Anything, 1).Return([]models.Subscription{
+		{ID: 1, SubscribedUserID: 3, SubscribingUserID: 1},
+		{ID: 2, SubscribedUserID: 1, SubscribingUserID: 2},
+	}, nil)
+	store.On("FindUserNotificationSetting", mock.Anything, 1).
+		Return(models.NotifySetting{}, storage.ErrNotifySettingNotFound{NotifySetting: models.NotifySetting{UserID: 1}})
+	store.On("ListNotificationHistory", mock.Anything, 1).Return([]models.NotificationRecord{
+		{ID: 1, RecipientUserID: 1, SubscribedUserID: &subscribedUserID, DaysBeforeNotify: 1},
+	}, nil)
+	store.On("ListAccountLoginLockouts", mock.Anything, "alice@example.com").Return([]models.LoginLockout{
+		{ID: 1, Scope: models.LoginScopeAccount, Key: "alice@example.com", IP: "192.0.2.1", Failures: 5},
+	}, nil)
+	accountSrv := services.NewAccountService(zap.NewNop(), store, new(photoStore), newLoginLimiter())
+
+	export, err := accountSrv.Export(context.TODO(), 1)
+	require.NoError(t, err)
+	assert.Equal(t, "alice@example.com", export.Profile.Email)
+	assert.NotNil(t, export.Identities, "empty lists must be exported as []")
+	assert.Len(t, export.APIKeys, 1)
+	assert.True(t, export.TwoFactorEnabled)
+	assert.Equal(t, []models.Subscription{{ID: 1, SubscribedUserID: 3, SubscribingUserID: 1}}, export.Subscriptions.Subscriptions)
+	assert.Equal(t, []models.Subscription{{ID: 2, SubscribedUserID: 1, SubscribingUserID: 2}}, export.Subscriptions.Subscribers)
+	assert.Nil(t, export.NotifySetting)
+	assert.Len(t, export.Notifications, 1)
+	assert.Len(t, export.LoginLockouts, 1)
+}
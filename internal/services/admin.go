@@ -0,0 +1,155 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ilya-burinskiy/birthday-notify/internal/models"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrUnknownRole      = errors.New("unknown role")
+	ErrCannotModifySelf = errors.New("administrators can not disable, delete or demote themselves")
+)
+
+type AdminStorage interface {
+	ListUsers(ctx context.Context) ([]models.User, error)
+	FindUserByID(ctx context.Context, userID int) (models.User, error)
+	FindUserByEmail(ctx context.Context, email string) (models.User, error)
+	SetUserRole(ctx context.Context, userID int, role string) error
+	DisableUser(ctx context.Context, userID int) error
+	EnableUser(ctx context.Context, userID int) error
+	DeleteUser(ctx context.Context, userID int) error
+	ListUserSubscriptions(ctx context.Context, userID int) ([]models.Subscription, error)
+	FindUserNotificationSetting(ctx context.Context, userID int) (models.NotifySetting, error)
+	SaveUserNotificationSetting(ctx context.Context, userID, daysBeforeNotify int) (models.NotifySetting, error)
+}
+
+// UserSubscriptions are subscriptions of a user split by direction.
+type UserSubscriptions struct {
+	// Subscriptions are the users the user is notified about.
+	Subscriptions []models.Subscription `json:"subscriptions"`
+	// Subscribers are the users notified about the user.
+	Subscribers []models.Subscription `json:"subscribers"`
+}
+
+func splitSubscriptions(userID int, subscriptions []models.Subscription) UserSubscriptions {
+	result := UserSubscriptions{
+		Subscriptions: []models.Subscription{},
+		Subscribers:   []models.Subscription{},
+	}
+	for _, subscription := range subscriptions {
+		if subscription.SubscribingUserID == userID {
+			result.Subscriptions = append(result.Subscriptions, subscription)
+		}
+		if subscription.SubscribedUserID == userID {
+			result.Subscribers = append(result.Subscribers, subscription)
+		}
+	}
+	return result
+}
+
+// AdminService manages any user account. Methods that change an account take
+// the id of the administrator making the request, so that administrators can
+// not lock themselves out.
+type AdminService struct {
+	logger  *zap.Logger
+	storage AdminStorage
+	photos  PhotoStore
+}
+
+func NewAdminService(logger *zap.Logger, storage AdminStorage, photos PhotoStore) AdminService {
+	return AdminService{
+		logger:  logger,
+		storage: storage,
+		photos:  photos,
+	}
+}
+
+func (srv AdminService) ListUsers(ctx context.Context) ([]models.User, error) {
+	return srv.storage.ListUsers(ctx)
+}
+
+func (srv AdminService) GetUser(ctx context.Context, userID int) (models.User, error) {
+	return srv.storage.FindUserByID(ctx, userID)
+}
+
+func (srv AdminService) SetRole(ctx context.Context, adminID, userID int, role string) error {
+	if !models.IsKnownRole(role) {
+		return ErrUnknownRole
+	}
+	if adminID == userID && role != models.RoleAdmin {
+		return ErrCannotModifySelf
+	}
+	return srv.storage.SetUserRole(ctx, userID, role)
+}
+
+// SetRoleByEmail is used from the command line to create the first
+// administrator.
+func (srv AdminService) SetRoleByEmail(ctx context.Context, email, role string) error {
+	if !models.IsKnownRole(role) {
+		return ErrUnknownRole
+	}
+	user, err := srv.storage.FindUserByEmail(ctx, email)
+	if err != nil {
+		return err
+	}
+	return srv.storage.SetUserRole(ctx, user.ID, role)
+}
+
+// Disable blocks sign-in and revokes all sessions and API keys of the user.
+func (srv AdminService) Disable(ctx context.Context, adminID, userID int) error {
+	if adminID == userID {
+		return ErrCannotModifySelf
+	}
+	return srv.storage.DisableUser(ctx, userID)
+}
+
+func (srv AdminService) Enable(ctx context.Context, userID int) error {
+	return srv.storage.EnableUser(ctx, userID)
+}
+
+func (srv AdminService) Delete(ctx context.Context, adminID, userID int) error {
+	if adminID == userID {
+		return ErrCannotModifySelf
+	}
+	user, err := srv.storage.FindUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if err := srv.storage.DeleteUser(ctx, userID); err != nil {
+		return err
+	}
+	deletePhoto(srv.logger, srv.photos, user)
+	return nil
+}
+
+func (srv AdminService) Subscriptions(ctx context.Context, userID int) (UserSubscriptions, error) {
+	if _, err := srv.storage.FindUserByID(ctx, userID); err != nil {
+		return UserSubscriptions{}, err
+	}
+	subscriptions, err := srv.storage.ListUserSubscriptions(ctx, userID)
+	if err != nil {
+		return UserSubscriptions{}, err
+	}
+
+	return splitSubscriptions(userID, subscriptions), nil
+}
+
+func (srv AdminService) NotificationSetting(ctx context.Context, userID int) (models.NotifySetting, error) {
+	return srv.storage.FindUserNotificationSetting(ctx, userID)
+}
+
+// SetNotificationSetting creates or updates the notification setting of the
+// user.
+func (srv AdminService) SetNotificationSetting(ctx context.Context, userID, daysBeforeNotify int) (models.NotifySetting, error) {
+	if err := validateDaysBefore(daysBeforeNotify); err != nil {
+		return models.NotifySetting{}, err
+	}
+	if _, err := srv.storage.FindUserByID(ctx, userID); err != nil {
+		return models.NotifySetting{}, fmt.Errorf("failed to set notification setting: %w", err)
+	}
+	return srv.storage.SaveUserNotificationSetting(ctx, userID, daysBeforeNotify)
+}
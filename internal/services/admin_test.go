@@ -0,0 +1,147 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ilya-burinskiy/birthday-notify/internal/models"
+	"github.com/ilya-burinskiy/birthday-notify/internal/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type adminStorage struct{ mock.Mock }
+
+func (s *adminStorage) ListUsers(ctx context.Context) ([]models.User, error) {
+	args := s.Called(ctx)
+	return args.Get(0).([]models.User), args.Error(1)
+}
+
+func (s *adminStorage) FindUserByID(ctx context.Context, userID int) (models.User, error) {
+	args := s.Called(ctx, userID)
+	return args.Get(0).(models.User), args.Error(1)
+}
+
+func (s *adminStorage) FindUserByEmail(ctx context.Context, email string) (models.User, error) {
+	args := s.Called(ctx, email)
+	return args.Get(0).(models.User), args.Error(1)
+}
+
+func (s *adminStorage) SetUserRole(ctx context.Context, userID int, role string) error {
+	args := s.Called(ctx, userID, role)
+	return args.Error(0)
+}
+
+func (s *adminStorage) DisableUser(ctx context.Context, userID int) error {
+	args := s.Called(ctx, userID)
+	return args.Error(0)
+}
+
+func (s *adminStorage) EnableUser(ctx context.Context, userID int) error {
+	args := s.Called(ctx, userID)
+	return args.Error(0)
+}
+
+func (s *adminStorage) DeleteUser(ctx context.Context, userID int) error {
+	args := s.Called(ctx, userID)
+	return args.Error(0)
+}
+
+func (s *adminStorage) ListUserSubscriptions(ctx context.Context, userID int) ([]models.Subscription, error) {
+	args := s.Called(ctx, userID)
+	return args.Get(0).([]models.Subscription), args.Error(1)
+}
+
+func (s *adminStorage) FindUserNotificationSetting(ctx context.Context, userID int) (models.NotifySetting, error) {
+	args := s.Called(ctx, userID)
+	return args.Get(0).(models.NotifySetting), args.Error(1)
+}
+
+func (s *adminStorage) SaveUserNotificationSetting(
+	ctx context.Context,
+	userID, daysBeforeNotify int,
+) (models.NotifySetting, error) {
+
+	args := s.Called(ctx, userID, daysBeforeNotify)
+	return args.Get(0).(models.NotifySetting), args.Error(1)
+}
+
+func TestAdminCanNotModifySelf(t *testing.T) {
+	store := new(adminStorage)
+	adminSrv := services.NewAdminService(zap.NewNop(), store, new(photoStore))
+
+	assert.ErrorIs(t, adminSrv.Disable(context.TODO(), 1, 1), services.ErrCannotModifySelf)
+	assert.ErrorIs(t, adminSrv.Delete(context.TODO(), 1, 1), services.ErrCannotModifySelf)
+	assert.ErrorIs(t, adminSrv.SetRole(context.TODO(), 1, 1, models.RoleUser), services.ErrCannotModifySelf)
+	store.AssertNotCalled(t, "DisableUser", mock.Anything, mock.Anything)
+	store.AssertNotCalled(t, "DeleteUser", mock.Anything, mock.Anything)
+	store.AssertNotCalled(t, "SetUserRole", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestAdminDeleteRemovesPhoto(t *testing.T) {
+	store := new(adminStorage)
+	store.On("FindUserByID", mock.Anything, 2).Return(models.User{ID: 2, Photo: "2-abc.png"}, nil)
+	store.On("DeleteUser", mock.Anything, 2).Return(nil)
+	photos := new(photoStore)
+	photos.On("Delete", "2-abc.png").Return(nil)
+	adminSrv := services.NewAdminService(zap.NewNop(), store, photos)
+
+	require.NoError(t, adminSrv.Delete(context.TODO(), 1, 2))
+	photos.AssertCalled(t, "Delete", "2-abc.png")
+}
+
+func TestAdminDeleteIgnoresPhotoFailure(t *testing.T) {
+	store := new(adminStorage)
+	store.On("FindUserByID", mock.Anything, 2).Return(models.User{ID: 2, Photo: "2-abc.png"}, nil)
+	store.On("DeleteUser", mock.Anything, 2).Return(nil)
+	photos := new(photoStore)
+	photos.On("Delete", "2-abc.png").Return(errors.New("permission denied"))
+	adminSrv := services.NewAdminService(zap.NewNop(), store, photos)
+
+	assert.NoError(t, adminSrv.Delete(context.TODO(), 1, 2))
+	store.AssertCalled(t, "DeleteUser", mock.Anything, 2)
+}
+
+func TestAdminSetRole(t *testing.T) {
+	store := new(adminStorage)
+	store.On("SetUserRole", mock.Anything, 2, models.RoleAdmin).Return(nil)
+	adminSrv := services.NewAdminService(zap.NewNop(), store, new(photoStore))
+
+	require.NoError(t, adminSrv.SetRole(context.TODO(), 1, 2, models.RoleAdmin))
+	assert.ErrorIs(t, adminSrv.SetRole(context.TODO(), 1, 2, "root"), services.ErrUnknownRole)
+	store.AssertNumberOfCalls(t, "SetUserRole", 1)
+}
+
+func TestAdminSubscriptions(t *testing.T) {
+	store := new(adminStorage)
+	store.On("FindUserByID", mock.Anything, 1).Return(models.User{ID: 1}, nil)
+	store.On("ListUserSubscriptions", mock.Anything, 1).Return([]models.Subscription{
+		{ID: 1, SubscribedUserID: 2, SubscribingUserID: 1},
+		{ID: 2, SubscribedUserID: 1, SubscribingUserID: 3},
+		{ID: 3, SubscribedUserID: 4, SubscribingUserID: 1},
+	}, nil)
+	adminSrv := services.NewAdminService(zap.NewNop(), store, new(photoStore))
+
+	subscriptions, err := adminSrv.Subscriptions(context.TODO(), 1)
+	require.NoError(t, err)
+	assert.Len(t, subscriptions.Subscriptions, 2)
+	assert.Equal(t, []models.Subscription{{ID: 2, SubscribedUserID: 1, SubscribingUserID: 3}}, subscriptions.Subscribers)
+}
+
+func TestAdminSetNotificationSetting(t *testing.T) {
+	store := new(adminStorage)
+	store.On("FindUserByID", mock.Anything, 2).Return(models.User{ID: 2}, nil)
+	store.On("SaveUserNotificationSetting", mock.Anything, 2, 3).
+		Return(models.NotifySetting{ID: 1, UserID: 2, DaysBeforeNotify: 3}, nil)
+	adminSrv := services.NewAdminService(zap.NewNop(), store, new(photoStore))
+
+	_, err := adminSrv.SetNotificationSetting(context.TODO(), 2, -1)
+	assert.ErrorIs(t, err, services.ErrInvalidDaysBefore)
+
+	setting, err := adminSrv.SetNotificationSetting(context.TODO(), 2, 3)
+	require.NoError(t, err)
+	assert.Equal(t, 3, setting.DaysBeforeNotify)
+}
@@ -0,0 +1,121 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ilya-burinskiy/birthday-notify/internal/auth"
+	"github.com/ilya-burinskiy/birthday-notify/internal/models"
+	"github.com/ilya-burinskiy/birthday-notify/internal/storage"
+)
+
+var ErrInvalidAPIKey = errors.New("invalid api key")
+
+// ErrInvalidAPIKeyParams is returned when an API key can not be created
+// with the requested name, scopes or expiry.
+type ErrInvalidAPIKeyParams struct {
+	Reason string
+}
+
+func (err ErrInvalidAPIKeyParams) Error() string {
+	return "invalid api key: " + err.Reason
+}
+
+type APIKeyStorage interface {
+	CreateAPIKey(
+		ctx context.Context,
+		userID int,
+		name string,
+		scopes []string,
+		keyHash []byte,
+		expiresAt *time.Time,
+	) (models.APIKey, error)
+	FindAPIKeyByHash(ctx context.Context, keyHash []byte) (models.APIKey, error)
+	ListUserAPIKeys(ctx context.Context, userID int) ([]models.APIKey, error)
+	RevokeAPIKey(ctx context.Context, userID, apiKeyID int) error
+	TouchAPIKey(ctx context.Context, apiKeyID int) error
+}
+
+type APIKeyService struct {
+	storage APIKeyStorage
+}
+
+func NewAPIKeyService(storage APIKeyStorage) APIKeyService {
+	return APIKeyService{storage: storage}
+}
+
+// Create issues a new key for the user. The key itself is returned only
+// once, the storage keeps its hash. A nil expiresAt creates a key that
+// never expires.
+func (srv APIKeyService) Create(
+	ctx context.Context,
+	userID int,
+	name string,
+	scopes []string,
+	expiresAt *time.Time,
+) (models.APIKey, string, error) {
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return models.APIKey{}, "", ErrInvalidAPIKeyParams{Reason: "name is required"}
+	}
+	if len(scopes) == 0 {
+		return models.APIKey{}, "", ErrInvalidAPIKeyParams{Reason: "at least one scope is required"}
+	}
+	for _, scope := range scopes {
+		if !auth.IsKnownScope(scope) {
+			return models.APIKey{}, "", ErrInvalidAPIKeyParams{Reason: fmt.Sprintf("unknown scope %q", scope)}
+		}
+	}
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return models.APIKey{}, "", ErrInvalidAPIKeyParams{Reason: "expires_at must be in the future"}
+	}
+
+	key, keyHash, err := auth.GenerateAPIKey()
+	if err != nil {
+		return models.APIKey{}, "", fmt.Errorf("failed to create api key: %w", err)
+	}
+	apiKey, err := srv.storage.CreateAPIKey(ctx, userID, name, scopes, keyHash, expiresAt)
+	if err != nil {
+		return models.APIKey{}, "", fmt.Errorf("failed to create api key: %w", err)
+	}
+
+	return apiKey, key, nil
+}
+
+func (srv APIKeyService) List(ctx context.Context, userID int) ([]models.APIKey, error) {
+	apiKeys, err := srv.storage.ListUserAPIKeys(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list api keys: %w", err)
+	}
+	return apiKeys, nil
+}
+
+func (srv APIKeyService) Revoke(ctx context.Context, userID, apiKeyID int) error {
+	return srv.storage.RevokeAPIKey(ctx, userID, apiKeyID)
+}
+
+// AuthenticateAPIKey returns the key if it is known, not revoked and not
+// expired, and records its use.
+func (srv APIKeyService) AuthenticateAPIKey(ctx context.Context, key string) (models.APIKey, error) {
+	apiKey, err := srv.storage.FindAPIKeyByHash(ctx, auth.HashAPIKey(key))
+	if err != nil {
+		var notFoundErr storage.ErrAPIKeyNotFound
+		if errors.As(err, &notFoundErr) {
+			return models.APIKey{}, ErrInvalidAPIKey
+		}
+		return models.APIKey{}, fmt.Errorf("failed to authenticate api key: %w", err)
+	}
+	if apiKey.RevokedAt != nil || (apiKey.ExpiresAt != nil && time.Now().After(*apiKey.ExpiresAt)) {
+		return models.APIKey{}, ErrInvalidAPIKey
+	}
+
+	if err := srv.storage.TouchAPIKey(ctx, apiKey.ID); err != nil {
+		return models.APIKey{}, fmt.Errorf("failed to authenticate api key: %w", err)
+	}
+
+	return apiKey, nil
+}
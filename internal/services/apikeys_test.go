@@ -0,0 +1,133 @@
+package services_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ilya-burinskiy/birthday-notify/internal/auth"
+	"github.com/ilya-burinskiy/birthday-notify/internal/models"
+	"github.com/ilya-burinskiy/birthday-notify/internal/services"
+	"github.com/ilya-burinskiy/birthday-notify/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type apiKeyStorage struct{ mock.Mock }
+
+func (s *apiKeyStorage) CreateAPIKey(
+	ctx context.Context,
+	userID int,
+	name string,
+	scopes []string,
+	keyHash []byte,
+	expiresAt *time.Time,
+) (models.APIKey, error) {
+
+	args := s.Called(ctx, userID, name, scopes, keyHash, expiresAt)
+	return args.Get(0).(models.APIKey), args.Error(1)
+}
+
+func (s *apiKeyStorage) FindAPIKeyByHash(ctx context.Context, keyHash []byte) (models.APIKey, error) {
+	args := s.Called(ctx, keyHash)
+	return args.Get(0).(models.APIKey), args.Error(1)
+}
+
+func (s *apiKeyStorage) ListUserAPIKeys(ctx context.Context, userID int) ([]models.APIKey, error) {
+	args := s.Called(ctx, userID)
+	return args.Get(0).([]models.APIKey), args.Error(1)
+}
+
+func (s *apiKeyStorage) RevokeAPIKey(ctx context.Context, userID, apiKeyID int) error {
+	args := s.Called(ctx, userID, apiKeyID)
+	return args.Error(0)
+}
+
+func (s *apiKeyStorage) TouchAPIKey(ctx context.Context, apiKeyID int) error {
+	args := s.Called(ctx, apiKeyID)
+	return args.Error(0)
+}
+
+func TestAPIKeyServiceCreate(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	testCases := []struct {
+		name      string
+		keyName   string
+		scopes    []string
+		expiresAt *time.Time
+		wantErr   string
+	}{
+		{name: "creates key", keyName: "ci", scopes: []string{auth.ScopeReadUsers}},
+		{name: "requires name", keyName: " ", scopes: []string{auth.ScopeReadUsers}, wantErr: "name is required"},
+		{name: "requires scopes", keyName: "ci", wantErr: "at least one scope is required"},
+		{name: "rejects unknown scope", keyName: "ci", scopes: []string{"admin"}, wantErr: `unknown scope "admin"`},
+		{
+			name:      "rejects expiry in the past",
+			keyName:   "ci",
+			scopes:    []string{auth.ScopeReadUsers},
+			expiresAt: &past,
+			wantErr:   "expires_at must be in the future",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := new(apiKeyStorage)
+			store.On("CreateAPIKey", mock.Anything, 1, tc.keyName, tc.scopes, mock.Anything, tc.expiresAt).
+				Return(models.APIKey{ID: 1, UserID: 1, Name: tc.keyName, Scopes: tc.scopes}, nil)
+			apiKeySrv := services.NewAPIKeyService(store)
+
+			apiKey, key, err := apiKeySrv.Create(context.TODO(), 1, tc.keyName, tc.scopes, tc.expiresAt)
+			if tc.wantErr != "" {
+				var paramsErr services.ErrInvalidAPIKeyParams
+				require.ErrorAs(t, err, &paramsErr)
+				assert.Equal(t, tc.wantErr, paramsErr.Reason)
+				store.AssertNotCalled(t, "CreateAPIKey", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, 1, apiKey.ID)
+			assert.True(t, auth.IsAPIKey(key))
+			store.AssertCalled(t, "CreateAPIKey", mock.Anything, 1, tc.keyName, tc.scopes, auth.HashAPIKey(key), tc.expiresAt)
+		})
+	}
+}
+
+func TestAPIKeyServiceAuthenticate(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+	testCases := []struct {
+		name    string
+		apiKey  models.APIKey
+		findErr error
+		wantErr error
+	}{
+		{name: "accepts key without expiry", apiKey: models.APIKey{ID: 1, UserID: 2}},
+		{name: "accepts key before expiry", apiKey: models.APIKey{ID: 1, UserID: 2, ExpiresAt: &future}},
+		{name: "rejects expired key", apiKey: models.APIKey{ID: 1, UserID: 2, ExpiresAt: &past}, wantErr: services.ErrInvalidAPIKey},
+		{name: "rejects revoked key", apiKey: models.APIKey{ID: 1, UserID: 2, RevokedAt: &past}, wantErr: services.ErrInvalidAPIKey},
+		{name: "rejects unknown key", findErr: storage.ErrAPIKeyNotFound{}, wantErr: services.ErrInvalidAPIKey},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := new(apiKeyStorage)
+			store.On("FindAPIKeyByHash", mock.Anything, auth.HashAPIKey("bnk_key")).Return(tc.apiKey, tc.findErr)
+			store.On("TouchAPIKey", mock.Anything, tc.apiKey.ID).Return(nil)
+			apiKeySrv := services.NewAPIKeyService(store)
+
+			apiKey, err := apiKeySrv.AuthenticateAPIKey(context.TODO(), "bnk_key")
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+				store.AssertNotCalled(t, "TouchAPIKey", mock.Anything, mock.Anything)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.apiKey.UserID, apiKey.UserID)
+			store.AssertCalled(t, "TouchAPIKey", mock.Anything, tc.apiKey.ID)
+		})
+	}
+}
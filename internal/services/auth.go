@@ -4,39 +4,225 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/ilya-burinskiy/birthday-notify/internal/auth"
 	"github.com/ilya-burinskiy/birthday-notify/internal/models"
+	"github.com/ilya-burinskiy/birthday-notify/internal/storage"
 )
 
 type UserFinder interface {
 	FindUserByEmail(ctx context.Context, email string) (models.User, error)
+	FindUserByID(ctx context.Context, userID int) (models.User, error)
+	UpdateUserPassword(ctx context.Context, userID int, encryptedPassword []byte) error
+}
+
+type SessionStarter interface {
+	Start(ctx context.Context, userID int) (auth.TokenPair, error)
+}
+
+type SecondFactorVerifier interface {
+	IsEnabled(ctx context.Context, userID int) (bool, error)
+	Verify(ctx context.Context, userID int, code string) error
+}
+
+// LoginLimiter tracks failed logins by email and client address. Check
+// counts the attempt up front, every attempt that passed it is settled with
+// Fail, Succeed or Release.
+type LoginLimiter interface {
+	Check(ctx context.Context, email, ip string) error
+	Fail(ctx context.Context, email, ip string) error
+	Succeed(ctx context.Context, email, ip string) error
+	Release(ctx context.Context, email, ip string) error
+}
+
+const (
+	secondFactorPurpose = "second_factor"
+	// the user has five minutes to enter the code after the password
+	secondFactorChallengeExp = 5 * time.Minute
+)
+
+var (
+	ErrInvalidSecondFactorChallenge = errors.New("invalid or expired two-factor challenge")
+	ErrUserDisabled                 = errors.New("account is disabled")
+)
+
+// ErrSecondFactorRequired is returned by Authenticate when the password is
+// correct but the user has two-factor authentication enabled. The challenge
+// token must be sent back together with the code.
+type ErrSecondFactorRequired struct {
+	ChallengeToken string
+}
+
+func (err ErrSecondFactorRequired) Error() string {
+	return "two-factor code required"
 }
 
 type AuthenticateService struct {
-	userFinder UserFinder
+	userFinder     UserFinder
+	sessionStarter SessionStarter
+	secondFactor   SecondFactorVerifier
+	challenges     ActionTokenManager
+	limiter        LoginLimiter
 }
 
-func NewAuthenticateService(usrFinder UserFinder) AuthenticateService {
+func NewAuthenticateService(
+	usrFinder UserFinder,
+	sessionStarter SessionStarter,
+	secondFactor SecondFactorVerifier,
+	challenges ActionTokenManager,
+	limiter LoginLimiter,
+) AuthenticateService {
+
 	return AuthenticateService{
-		userFinder: usrFinder,
+		userFinder:     usrFinder,
+		sessionStarter: sessionStarter,
+		secondFactor:   secondFactor,
+		challenges:     challenges,
+		limiter:        limiter,
 	}
 }
 
-func (srv AuthenticateService) Authenticate(ctx context.Context, email, password string) (string, error) {
+// Authenticate checks the password of the user signing in from ip. Every
+// failure, including an unknown email, counts towards the lockout; the
+// attempt is given back on every other outcome, including our own errors.
+func (srv AuthenticateService) Authenticate(ctx context.Context, email, password, ip string) (auth.TokenPair, error) {
+	if err := srv.limiter.Check(ctx, email, ip); err != nil {
+		return auth.TokenPair{}, err
+	}
+
 	user, err := srv.userFinder.FindUserByEmail(ctx, email)
+	var notFoundErr storage.ErrUserNotFound
+	if errors.As(err, &notFoundErr) {
+		return auth.TokenPair{}, srv.fail(ctx, email, ip, fmt.Errorf("failed to authenticate user: %w", err))
+	}
+	// errors of our own, e.g. a database outage, are not the user's failures
 	if err != nil {
-		return "", fmt.Errorf("failed to authenticate user: %w", err)
+		return auth.TokenPair{}, srv.release(ctx, email, ip, fmt.Errorf("failed to authenticate user: %w", err))
 	}
 
 	if !auth.ValidatePasswordHash(password, string(user.EncryptedPassword)) {
-		return "", errors.New("invalid email or password")
+		return auth.TokenPair{}, srv.fail(ctx, email, ip, errors.New("invalid email or password"))
+	}
+	// checked after the password so that the state of an account is not
+	// revealed to someone guessing it
+	if user.DisabledAt != nil {
+		return auth.TokenPair{}, srv.release(ctx, email, ip, ErrUserDisabled)
+	}
+	if err := srv.rehashPassword(ctx, user, password); err != nil {
+		return auth.TokenPair{}, srv.release(ctx, email, ip, fmt.Errorf("failed to authenticate user: %w", err))
+	}
+
+	enabled, err := srv.secondFactor.IsEnabled(ctx, user.ID)
+	if err != nil {
+		return auth.TokenPair{}, srv.release(ctx, email, ip, fmt.Errorf("failed to authenticate user: %w", err))
+	}
+	if enabled {
+		challenge, err := srv.challenges.BuildActionToken(secondFactorPurpose, user.ID, user.Email, secondFactorChallengeExp)
+		if err != nil {
+			return auth.TokenPair{}, srv.release(ctx, email, ip, fmt.Errorf("failed to authenticate user: %w", err))
+		}
+		// the account failures are kept until the code is right too
+		return auth.TokenPair{}, srv.release(ctx, email, ip, ErrSecondFactorRequired{ChallengeToken: challenge})
 	}
 
-	jwtStr, err := auth.BuildJWTString(user.ID)
+	return srv.startSession(ctx, user.Email, user.ID, ip)
+}
+
+// CompleteSecondFactor is the second login step: it checks the code for the
+// challenge issued by Authenticate and starts a session. Wrong codes count
+// towards the lockout like wrong passwords.
+func (srv AuthenticateService) CompleteSecondFactor(ctx context.Context, challenge, code, ip string) (auth.TokenPair, error) {
+	claims, err := srv.challenges.ParseActionToken(secondFactorPurpose, challenge)
+	if err != nil {
+		return auth.TokenPair{}, ErrInvalidSecondFactorChallenge
+	}
+	if err := srv.limiter.Check(ctx, claims.Email, ip); err != nil {
+		return auth.TokenPair{}, err
+	}
+
+	if err := srv.secondFactor.Verify(ctx, claims.UserID, code); err != nil {
+		if errors.Is(err, ErrTOTPNotEnabled) {
+			return auth.TokenPair{}, srv.release(ctx, claims.Email, ip, ErrInvalidSecondFactorChallenge)
+		}
+		if errors.Is(err, ErrInvalidTOTPCode) {
+			return auth.TokenPair{}, srv.fail(ctx, claims.Email, ip, err)
+		}
+		return auth.TokenPair{}, srv.release(ctx, claims.Email, ip, err)
+	}
+
+	// the account may have been disabled after the password was checked
+	user, err := srv.userFinder.FindUserByID(ctx, claims.UserID)
+	if err != nil {
+		return auth.TokenPair{}, srv.release(ctx, claims.Email, ip, fmt.Errorf("failed to authenticate user: %w", err))
+	}
+	if user.DisabledAt != nil {
+		return auth.TokenPair{}, srv.release(ctx, claims.Email, ip, ErrUserDisabled)
+	}
+
+	return srv.startSession(ctx, claims.Email, claims.UserID, ip)
+}
+
+// rehashPassword upgrades bcrypt hashes and hashes with outdated parameters
+// while the plain password is known.
+func (srv AuthenticateService) rehashPassword(ctx context.Context, user models.User, password string) error {
+	if !auth.PasswordNeedsRehash(string(user.EncryptedPassword)) {
+		return nil
+	}
+
+	encryptedPassword, err := auth.HashPassword(password)
+	if err != nil {
+		return err
+	}
+	return srv.userFinder.UpdateUserPassword(ctx, user.ID, encryptedPassword)
+}
+
+// fail records the failed attempt and returns err.
+func (srv AuthenticateService) fail(ctx context.Context, email, ip string, err error) error {
+	if limitErr := srv.limiter.Fail(ctx, email, ip); limitErr != nil {
+		return errors.Join(err, limitErr)
+	}
+	return err
+}
+
+// release gives back an attempt that was not a failure and returns err.
+func (srv AuthenticateService) release(ctx context.Context, email, ip string, err error) error {
+	if limitErr := srv.limiter.Release(ctx, email, ip); limitErr != nil {
+		return errors.Join(err, limitErr)
+	}
+	return err
+}
+
+// limitAttempt runs check as a login attempt of email from ip. An error
+// matching failure is recorded with Fail, any other outcome gives the attempt
+// back, so that only wrong secrets count towards the lockout.
+func limitAttempt(ctx context.Context, limiter LoginLimiter, email, ip string, failure error, check func() error) error {
+	if err := limiter.Check(ctx, email, ip); err != nil {
+		return err
+	}
+
+	err := check()
+	settle := limiter.Release
+	if errors.Is(err, failure) {
+		settle = limiter.Fail
+	}
+	if limitErr := settle(ctx, email, ip); limitErr != nil {
+		if err == nil {
+			return limitErr
+		}
+		return errors.Join(err, limitErr)
+	}
+	return err
+}
+
+func (srv AuthenticateService) startSession(ctx context.Context, email string, userID int, ip string) (auth.TokenPair, error) {
+	if err := srv.limiter.Succeed(ctx, email, ip); err != nil {
+		return auth.TokenPair{}, srv.release(ctx, email, ip, fmt.Errorf("failed to authenticate user: %w", err))
+	}
+	tokens, err := srv.sessionStarter.Start(ctx, userID)
 	if err != nil {
-		return "", fmt.Errorf("failed to authenticate user: %w", err)
+		return auth.TokenPair{}, fmt.Errorf("failed to authenticate user: %w", err)
 	}
 
-	return jwtStr, nil
+	return tokens, nil
 }
@@ -3,11 +3,14 @@ package services_test
 import (
 	"context"
 	"errors"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/ilya-burinskiy/birthday-notify/internal/auth"
 	"github.com/ilya-burinskiy/birthday-notify/internal/models"
 	"github.com/ilya-burinskiy/birthday-notify/internal/services"
+	"github.com/ilya-burinskiy/birthday-notify/internal/storage"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
@@ -21,6 +24,59 @@ func (f *userFinder) FindUserByEmail(ctx context.Context, login string) (models.
 	return args.Get(0).(models.User), args.Error(1)
 }
 
+func (f *userFinder) FindUserByID(ctx context.Context, userID int) (models.User, error) {
+	args := f.Called(ctx, userID)
+	return args.Get(0).(models.User), args.Error(1)
+}
+
+type secondFactorVerifier struct{ mock.Mock }
+
+func (v *secondFactorVerifier) IsEnabled(ctx context.Context, userID int) (bool, error) {
+	args := v.Called(ctx, userID)
+	return args.Bool(0), args.Error(1)
+}
+
+func (v *secondFactorVerifier) Verify(ctx context.Context, userID int, code string) error {
+	args := v.Called(ctx, userID, code)
+	return args.Error(0)
+}
+
+type loginLimiter struct{ mock.Mock }
+
+func (l *loginLimiter) Check(ctx context.Context, email, ip string) error {
+	args := l.Called(ctx, email, ip)
+	return args.Error(0)
+}
+
+func (l *loginLimiter) Fail(ctx context.Context, email, ip string) error {
+	args := l.Called(ctx, email, ip)
+	return args.Error(0)
+}
+
+func (l *loginLimiter) Succeed(ctx context.Context, email, ip string) error {
+	args := l.Called(ctx, email, ip)
+	return args.Error(0)
+}
+
+func (l *loginLimiter) Release(ctx context.Context, email, ip string) error {
+	args := l.Called(ctx, email, ip)
+	return args.Error(0)
+}
+
+func newLoginLimiter() *loginLimiter {
+	limiter := new(loginLimiter)
+	limiter.On("Check", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	limiter.On("Fail", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	limiter.On("Succeed", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	limiter.On("Release", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	return limiter
+}
+
+func (f *userFinder) UpdateUserPassword(ctx context.Context, userID int, encryptedPassword []byte) error {
+	args := f.Called(ctx, userID, encryptedPassword)
+	return args.Error(0)
+}
+
 func TestAuthenticate(t *testing.T) {
 	type want struct {
 		jwtStr string
@@ -30,8 +86,11 @@ func TestAuthenticate(t *testing.T) {
 		user models.User
 		err  error
 	}
+	birthDate := time.Date(1999, 1, 1, 0, 0, 0, 0, time.UTC)
 	usrFinder := new(userFinder)
-	authSrv := services.NewAuthenticateService(usrFinder)
+	secondFactor := new(secondFactorVerifier)
+	secondFactor.On("IsEnabled", mock.Anything, mock.Anything).Return(false, nil)
+	authSrv := services.NewAuthenticateService(usrFinder, sessionStarter{}, secondFactor, jwtManager, newLoginLimiter())
 	testCases := []struct {
 		name     string
 		login    string
@@ -48,7 +107,7 @@ func TestAuthenticate(t *testing.T) {
 					ID:                1,
 					Email:             "login",
 					EncryptedPassword: hashPassword(t, "password"),
-					BirthDate:         time.Date(1999, 1, 1, 0, 0, 0, 0, time.UTC),
+					BirthDate:         &birthDate,
 				},
 			},
 			want: want{
@@ -69,16 +128,16 @@ func TestAuthenticate(t *testing.T) {
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			ctx := context.TODO()
-			findCall := usrFinder.On("FindUserByLogin", mock.Anything, mock.Anything).
+			findCall := usrFinder.On("FindUserByEmail", mock.Anything, mock.Anything).
 				Return(tc.findRes.user, tc.findRes.err)
 			defer findCall.Unset()
 
-			jwtStr, err := authSrv.Authenticate(ctx, tc.login, tc.password)
+			tokens, err := authSrv.Authenticate(ctx, tc.login, tc.password, "127.0.0.1")
 			if err == nil {
 				assert.Equal(
 					t,
 					userIDFromJWT(t, tc.want.jwtStr),
-					userIDFromJWT(t, jwtStr),
+					userIDFromJWT(t, tokens.AccessToken),
 				)
 			} else {
 				assert.EqualError(t, err, tc.want.errMsg)
@@ -87,8 +146,157 @@ func TestAuthenticate(t *testing.T) {
 	}
 }
 
+func TestAuthenticateWithSecondFactor(t *testing.T) {
+	usrFinder := new(userFinder)
+	usrFinder.On("FindUserByEmail", mock.Anything, "login").Return(
+		models.User{ID: 1, Email: "login", EncryptedPassword: hashPassword(t, "password")},
+		nil,
+	)
+	usrFinder.On("FindUserByID", mock.Anything, 1).Return(models.User{ID: 1, Email: "login"}, nil)
+	secondFactor := new(secondFactorVerifier)
+	secondFactor.On("IsEnabled", mock.Anything, 1).Return(true, nil)
+	secondFactor.On("Verify", mock.Anything, 1, "123456").Return(nil)
+	secondFactor.On("Verify", mock.Anything, 1, "000000").Return(services.ErrInvalidTOTPCode)
+	authSrv := services.NewAuthenticateService(usrFinder, sessionStarter{}, secondFactor, jwtManager, newLoginLimiter())
+
+	_, err := authSrv.Authenticate(context.TODO(), "login", "password", "127.0.0.1")
+	var secondFactorErr services.ErrSecondFactorRequired
+	require.ErrorAs(t, err, &secondFactorErr)
+
+	_, err = jwtManager.ParseJWTString(secondFactorErr.ChallengeToken)
+	assert.Error(t, err, "challenge must not be accepted as an access token")
+
+	_, err = authSrv.CompleteSecondFactor(context.TODO(), secondFactorErr.ChallengeToken, "000000", "127.0.0.1")
+	assert.ErrorIs(t, err, services.ErrInvalidTOTPCode)
+
+	_, err = authSrv.CompleteSecondFactor(context.TODO(), "invalid", "123456", "127.0.0.1")
+	assert.ErrorIs(t, err, services.ErrInvalidSecondFactorChallenge)
+
+	tokens, err := authSrv.CompleteSecondFactor(context.TODO(), secondFactorErr.ChallengeToken, "123456", "127.0.0.1")
+	require.NoError(t, err)
+	assert.Equal(t, 1, userIDFromJWT(t, tokens.AccessToken))
+}
+
+func TestAuthenticateDisabledUser(t *testing.T) {
+	disabledAt := time.Now()
+	usrFinder := new(userFinder)
+	usrFinder.On("FindUserByEmail", mock.Anything, "login").Return(
+		models.User{ID: 1, Email: "login", EncryptedPassword: hashPassword(t, "password"), DisabledAt: &disabledAt},
+		nil,
+	)
+	secondFactor := new(secondFactorVerifier)
+	secondFactor.On("IsEnabled", mock.Anything, 1).Return(false, nil)
+	authSrv := services.NewAuthenticateService(usrFinder, sessionStarter{}, secondFactor, jwtManager, newLoginLimiter())
+
+	_, err := authSrv.Authenticate(context.TODO(), "login", "password", "127.0.0.1")
+	assert.ErrorIs(t, err, services.ErrUserDisabled)
+
+	_, err = authSrv.Authenticate(context.TODO(), "login", "wrong", "127.0.0.1")
+	assert.NotErrorIs(t, err, services.ErrUserDisabled, "a wrong password must not reveal the account state")
+}
+
+func TestAuthenticateThrottled(t *testing.T) {
+	usrFinder := new(userFinder)
+	usrFinder.On("FindUserByEmail", mock.Anything, "login").Return(
+		models.User{ID: 1, Email: "login", EncryptedPassword: hashPassword(t, "password")},
+		nil,
+	)
+	usrFinder.On("FindUserByEmail", mock.Anything, "unknown").Return(models.User{}, storage.ErrUserNotFound{})
+	secondFactor := new(secondFactorVerifier)
+	secondFactor.On("IsEnabled", mock.Anything, 1).Return(false, nil)
+
+	t.Run("rejects attempt without checking the password", func(t *testing.T) {
+		limiter := new(loginLimiter)
+		limiter.On("Check", mock.Anything, "login", "127.0.0.1").
+			Return(services.ErrTooManyLoginAttempts{RetryAfter: time.Second})
+		authSrv := services.NewAuthenticateService(usrFinder, sessionStarter{}, secondFactor, jwtManager, limiter)
+
+		_, err := authSrv.Authenticate(context.TODO(), "login", "password", "127.0.0.1")
+		assert.ErrorAs(t, err, &services.ErrTooManyLoginAttempts{})
+		usrFinder.AssertNotCalled(t, "FindUserByEmail", mock.Anything, mock.Anything)
+	})
+
+	testCases := []struct {
+		name     string
+		login    string
+		password string
+	}{
+		{name: "records wrong password", login: "login", password: "wrong"},
+		{name: "records unknown email", login: "unknown", password: "password"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			limiter := newLoginLimiter()
+			authSrv := services.NewAuthenticateService(usrFinder, sessionStarter{}, secondFactor, jwtManager, limiter)
+
+			_, err := authSrv.Authenticate(context.TODO(), tc.login, tc.password, "127.0.0.1")
+			assert.Error(t, err)
+			limiter.AssertCalled(t, "Fail", mock.Anything, tc.login, "127.0.0.1")
+			limiter.AssertNotCalled(t, "Succeed", mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+
+	t.Run("resets failures after login", func(t *testing.T) {
+		limiter := newLoginLimiter()
+		authSrv := services.NewAuthenticateService(usrFinder, sessionStarter{}, secondFactor, jwtManager, limiter)
+
+		_, err := authSrv.Authenticate(context.TODO(), "login", "password", "127.0.0.1")
+		require.NoError(t, err)
+		limiter.AssertCalled(t, "Succeed", mock.Anything, "login", "127.0.0.1")
+		limiter.AssertNotCalled(t, "Fail", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestAuthenticateReleasesAttemptOnOwnErrors(t *testing.T) {
+	user := models.User{ID: 1, Email: "login", EncryptedPassword: hashPassword(t, "password")}
+	testCases := []struct {
+		name       string
+		findErr    error
+		enabledErr error
+	}{
+		{name: "user lookup fails", findErr: errors.New("connection refused")},
+		{name: "two-factor lookup fails", enabledErr: errors.New("connection refused")},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			usrFinder := new(userFinder)
+			usrFinder.On("FindUserByEmail", mock.Anything, "login").Return(user, tc.findErr)
+			secondFactor := new(secondFactorVerifier)
+			secondFactor.On("IsEnabled", mock.Anything, 1).Return(false, tc.enabledErr)
+			limiter := newLoginLimiter()
+			authSrv := services.NewAuthenticateService(usrFinder, sessionStarter{}, secondFactor, jwtManager, limiter)
+
+			_, err := authSrv.Authenticate(context.TODO(), "login", "password", "127.0.0.1")
+			assert.Error(t, err)
+			limiter.AssertCalled(t, "Release", mock.Anything, "login", "127.0.0.1")
+			limiter.AssertNotCalled(t, "Fail", mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestAuthenticateRehashesBcryptPassword(t *testing.T) {
+	bcryptHash, err := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	require.NoError(t, err)
+	usrFinder := new(userFinder)
+	usrFinder.On("FindUserByEmail", mock.Anything, "login").Return(
+		models.User{ID: 1, Email: "login", EncryptedPassword: bcryptHash},
+		nil,
+	)
+	usrFinder.On("UpdateUserPassword", mock.Anything, 1, mock.Anything).Return(nil)
+	secondFactor := new(secondFactorVerifier)
+	secondFactor.On("IsEnabled", mock.Anything, 1).Return(false, nil)
+	authSrv := services.NewAuthenticateService(usrFinder, sessionStarter{}, secondFactor, jwtManager, newLoginLimiter())
+
+	_, err = authSrv.Authenticate(context.TODO(), "login", "password", "127.0.0.1")
+	require.NoError(t, err)
+
+	newHash := usrFinder.Calls[len(usrFinder.Calls)-1].Arguments.Get(2).([]byte)
+	assert.True(t, strings.HasPrefix(string(newHash), "$argon2id$"))
+	assert.True(t, auth.ValidatePasswordHash("password", string(newHash)))
+}
+
 func hashPassword(t *testing.T, pwd string) []byte {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(pwd), bcrypt.DefaultCost)
+	bytes, err := auth.HashPassword(pwd)
 	require.NoError(t, err)
 
 	return bytes
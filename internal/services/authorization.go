@@ -0,0 +1,47 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ilya-burinskiy/birthday-notify/internal/models"
+)
+
+// ErrForbidden is returned when a user acts on a resource of another user.
+var ErrForbidden = errors.New("access denied")
+
+type RoleFinder interface {
+	FindUserByID(ctx context.Context, userID int) (models.User, error)
+}
+
+// Authorizer decides whether a user may act on resources owned by users.
+// Services load the resource first, so that a missing resource is reported
+// as not found and an existing one of another user as forbidden.
+type Authorizer struct {
+	users RoleFinder
+}
+
+func NewAuthorizer(users RoleFinder) Authorizer {
+	return Authorizer{
+		users: users,
+	}
+}
+
+// AuthorizeOwner allows the owner of a resource and administrators. The
+// role is only looked up when the user is not the owner.
+func (a Authorizer) AuthorizeOwner(ctx context.Context, userID, ownerID int) error {
+	if userID == ownerID {
+		return nil
+	}
+
+	user, err := a.users.FindUserByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to authorize user with id=%d: %w", userID, err)
+	}
+	if user.Role != models.RoleAdmin || user.DisabledAt != nil {
+		return ErrForbidden
+	}
+
+	return nil
+}
@@ -0,0 +1,71 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ilya-burinskiy/birthday-notify/internal/models"
+	"github.com/ilya-burinskiy/birthday-notify/internal/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type roleFinder struct{ mock.Mock }
+
+func (f *roleFinder) FindUserByID(ctx context.Context, userID int) (models.User, error) {
+	args := f.Called(ctx, userID)
+	return args.Get(0).(models.User), args.Error(1)
+}
+
+func newRoleFinder() *roleFinder {
+	disabledAt := time.Now()
+	users := new(roleFinder)
+	users.On("FindUserByID", mock.Anything, 1).Return(models.User{ID: 1, Role: models.RoleUser}, nil)
+	users.On("FindUserByID", mock.Anything, 2).Return(models.User{ID: 2, Role: models.RoleUser}, nil)
+	users.On("FindUserByID", mock.Anything, 3).Return(models.User{ID: 3, Role: models.RoleAdmin}, nil)
+	users.On("FindUserByID", mock.Anything, 4).
+		Return(models.User{ID: 4, Role: models.RoleAdmin, DisabledAt: &disabledAt}, nil)
+	return users
+}
+
+func TestAuthorizeOwner(t *testing.T) {
+	authorizer := services.NewAuthorizer(newRoleFinder())
+	testCases := []struct {
+		name    string
+		userID  int
+		ownerID int
+		wantErr error
+	}{
+		{name: "allows owner", userID: 1, ownerID: 1},
+		{name: "forbids another user", userID: 2, ownerID: 1, wantErr: services.ErrForbidden},
+		{name: "allows admin", userID: 3, ownerID: 1},
+		{name: "forbids disabled admin", userID: 4, ownerID: 1, wantErr: services.ErrForbidden},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := authorizer.AuthorizeOwner(context.TODO(), tc.userID, tc.ownerID)
+			if tc.wantErr == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tc.wantErr)
+			}
+		})
+	}
+
+	t.Run("does not look up the owner", func(t *testing.T) {
+		users := new(roleFinder)
+		assert.NoError(t, services.NewAuthorizer(users).AuthorizeOwner(context.TODO(), 1, 1))
+		users.AssertNotCalled(t, "FindUserByID", mock.Anything, mock.Anything)
+	})
+
+	t.Run("returns lookup error", func(t *testing.T) {
+		users := new(roleFinder)
+		users.On("FindUserByID", mock.Anything, 2).Return(models.User{}, errors.New("error"))
+		err := services.NewAuthorizer(users).AuthorizeOwner(context.TODO(), 2, 1)
+		assert.Error(t, err)
+		assert.NotErrorIs(t, err, services.ErrForbidden)
+	})
+}
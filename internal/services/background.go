@@ -0,0 +1,49 @@
+package services
+
+import (
+	"context"
+	"errors"
+)
+
+var ErrShuttingDown = errors.New("server is shutting down")
+
+// Background runs work that outlives the request starting it, e.g. sending
+// an email, and lets the shutdown wait for it before the database is closed.
+type Background struct {
+	running *batches
+}
+
+func NewBackground() Background {
+	return Background{
+		running: &batches{},
+	}
+}
+
+// Go runs task in its own goroutine. It returns false without running task
+// once Stop has been called.
+func (bg Background) Go(task func()) bool {
+	if !bg.running.start() {
+		return false
+	}
+	go func() {
+		defer bg.running.done()
+		task()
+	}()
+	return true
+}
+
+// Stop refuses new work and waits for the running work until ctx is done.
+func (bg Background) Stop(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		bg.running.stop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -2,10 +2,22 @@ package services
 
 import (
 	"context"
+	"errors"
 
 	"github.com/ilya-burinskiy/birthday-notify/internal/models"
 )
 
+var ErrInvalidDaysBefore = errors.New("days_before_notify must not be negative")
+
+// validateDaysBefore checks days_before_notify for every path that saves a
+// notification setting.
+func validateDaysBefore(daysBeforeNotify int) error {
+	if daysBeforeNotify < 0 {
+		return ErrInvalidDaysBefore
+	}
+	return nil
+}
+
 type NotificationSettingCreator interface {
 	CreateNotificationSetting(ctx context.Context, userID, daysBeforeNotify int) (models.NotifySetting, error)
 }
@@ -26,5 +38,8 @@ func (srv CreateNotificationSettingService) CreateNotificationSetting(
 	daysBeforeNotiy int,
 ) (models.NotifySetting, error) {
 
+	if err := validateDaysBefore(daysBeforeNotiy); err != nil {
+		return models.NotifySetting{}, err
+	}
 	return srv.creator.CreateNotificationSetting(ctx, userID, daysBeforeNotiy)
 }
@@ -0,0 +1,53 @@
+package services_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ilya-burinskiy/birthday-notify/internal/models"
+	"github.com/ilya-burinskiy/birthday-notify/internal/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type notificationSettingCreator struct{ mock.Mock }
+
+func (c *notificationSettingCreator) CreateNotificationSetting(
+	ctx context.Context,
+	userID, daysBeforeNotify int,
+) (models.NotifySetting, error) {
+
+	args := c.Called(ctx, userID, daysBeforeNotify)
+	return args.Get(0).(models.NotifySetting), args.Error(1)
+}
+
+func TestCreateNotificationSetting(t *testing.T) {
+	testCases := []struct {
+		name             string
+		daysBeforeNotify int
+		wantErr          error
+	}{
+		{name: "creates setting", daysBeforeNotify: 3},
+		{name: "creates setting for the birthday itself", daysBeforeNotify: 0},
+		{name: "rejects negative days", daysBeforeNotify: -1, wantErr: services.ErrInvalidDaysBefore},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			creator := new(notificationSettingCreator)
+			creator.On("CreateNotificationSetting", mock.Anything, 1, tc.daysBeforeNotify).
+				Return(models.NotifySetting{ID: 1, UserID: 1, DaysBeforeNotify: tc.daysBeforeNotify}, nil)
+			createSrv := services.NewCreateNotificationSettingService(creator)
+
+			notifySetting, err := createSrv.CreateNotificationSetting(context.TODO(), 1, tc.daysBeforeNotify)
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+				creator.AssertNotCalled(t, "CreateNotificationSetting", mock.Anything, mock.Anything, mock.Anything)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.daysBeforeNotify, notifySetting.DaysBeforeNotify)
+		})
+	}
+}
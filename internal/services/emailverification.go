@@ -0,0 +1,142 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ilya-burinskiy/birthday-notify/internal/auth"
+	"github.com/ilya-burinskiy/birthday-notify/internal/models"
+	"github.com/ilya-burinskiy/birthday-notify/internal/storage"
+)
+
+const emailVerificationPurpose = "email_verification"
+
+var (
+	ErrInvalidVerificationToken = errors.New("invalid or expired verification token")
+	ErrEmailAlreadyVerified     = errors.New("email already verified")
+)
+
+type EmailVerificationStorage interface {
+	FindUserByID(ctx context.Context, userID int) (models.User, error)
+	MarkEmailVerified(ctx context.Context, userID int, email string, issuedAt time.Time) error
+}
+
+// ActionTokenManager signs and verifies single-purpose tokens sent by email.
+type ActionTokenManager interface {
+	BuildActionToken(purpose string, userID int, email string, exp time.Duration) (string, error)
+	ParseActionToken(purpose, tokenString string) (auth.Claims, error)
+}
+
+type EmailVerificationService struct {
+	storage   EmailVerificationStorage
+	tokens    ActionTokenManager
+	sender    NotificationSender
+	publicURL string
+	tokenExp  time.Duration
+}
+
+func NewEmailVerificationService(
+	storage EmailVerificationStorage,
+	tokens ActionTokenManager,
+	sender NotificationSender,
+	publicURL string,
+	tokenExp time.Duration,
+) EmailVerificationService {
+
+	return EmailVerificationService{
+		storage:   storage,
+		tokens:    tokens,
+		sender:    sender,
+		publicURL: strings.TrimSuffix(publicURL, "/"),
+		tokenExp:  tokenExp,
+	}
+}
+
+// SendVerification emails the user a link that confirms the address.
+func (srv EmailVerificationService) SendVerification(ctx context.Context, user models.User) error {
+	return srv.sendLink(user.ID, user.Email)
+}
+
+// SendEmailChange emails the link confirming the new address to it and
+// warns the current address, so that its owner notices a change they did
+// not make.
+func (srv EmailVerificationService) SendEmailChange(ctx context.Context, user models.User, newEmail string) error {
+	if err := srv.sendLink(user.ID, newEmail); err != nil {
+		return err
+	}
+
+	body := fmt.Sprintf(
+		"The email of your account is being changed to %s. The change takes effect once the new "+
+			"address is confirmed.\n\nIf you did not request it, change your password right away.",
+		newEmail,
+	)
+	if err := srv.sender.Send(user.Email, "Your email is being changed", body); err != nil {
+		return fmt.Errorf("failed to send email change notice: %w", err)
+	}
+
+	return nil
+}
+
+func (srv EmailVerificationService) sendLink(userID int, email string) error {
+	token, err := srv.tokens.BuildActionToken(emailVerificationPurpose, userID, email, srv.tokenExp)
+	if err != nil {
+		return fmt.Errorf("failed to send verification email: %w", err)
+	}
+
+	link := srv.publicURL + "/api/users/verify_email?token=" + url.QueryEscape(token)
+	body := fmt.Sprintf(
+		"Confirm your email address by opening the link below:\n\n%s\n\nThe link expires in %s.",
+		link,
+		srv.tokenExp,
+	)
+	if err := srv.sender.Send(email, "Confirm your email address", body); err != nil {
+		return fmt.Errorf("failed to send verification email: %w", err)
+	}
+
+	return nil
+}
+
+// Resend sends a new verification link to a user who has not confirmed the
+// address or the pending new address yet.
+func (srv EmailVerificationService) Resend(ctx context.Context, userID int) error {
+	user, err := srv.storage.FindUserByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to send verification email: %w", err)
+	}
+	if user.PendingEmail != nil {
+		return srv.sendLink(user.ID, *user.PendingEmail)
+	}
+	if user.EmailVerifiedAt != nil {
+		return ErrEmailAlreadyVerified
+	}
+
+	return srv.SendVerification(ctx, user)
+}
+
+func (srv EmailVerificationService) Confirm(ctx context.Context, token string) error {
+	claims, err := srv.tokens.ParseActionToken(emailVerificationPurpose, token)
+	if err != nil {
+		return ErrInvalidVerificationToken
+	}
+
+	var issuedAt time.Time
+	if claims.IssuedAt != nil {
+		issuedAt = claims.IssuedAt.Time
+	}
+	err = srv.storage.MarkEmailVerified(ctx, claims.UserID, claims.Email, issuedAt)
+	if err != nil {
+		// the user was deleted, changed the email again or reset the password
+		// after the link was sent
+		var notFoundErr storage.ErrUserNotFound
+		if errors.As(err, &notFoundErr) {
+			return ErrInvalidVerificationToken
+		}
+		return fmt.Errorf("failed to verify email: %w", err)
+	}
+
+	return nil
+}
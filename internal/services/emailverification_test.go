@@ -0,0 +1,102 @@
+package services_test
+
+import (
+	"context"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/ilya-burinskiy/birthday-notify/internal/models"
+	"github.com/ilya-burinskiy/birthday-notify/internal/services"
+	"github.com/ilya-burinskiy/birthday-notify/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type emailVerificationStorage struct{ mock.Mock }
+
+func (s *emailVerificationStorage) FindUserByID(ctx context.Context, userID int) (models.User, error) {
+	args := s.Called(ctx, userID)
+	return args.Get(0).(models.User), args.Error(1)
+}
+
+func (s *emailVerificationStorage) MarkEmailVerified(ctx context.Context, userID int, email string, issuedAt time.Time) error {
+	args := s.Called(ctx, userID, email, issuedAt)
+	return args.Error(0)
+}
+
+func TestEmailVerification(t *testing.T) {
+	store := new(emailVerificationStorage)
+	sender := new(notificationSender)
+	verificationSrv := services.NewEmailVerificationService(
+		store,
+		jwtManager,
+		sender,
+		"https://birthday.example.com/",
+		time.Hour,
+	)
+	user := models.User{ID: 1, Email: "email@example.com"}
+
+	var body string
+	sender.On("Send", user.Email, "Confirm your email address", mock.Anything).
+		Run(func(args mock.Arguments) { body = args.String(2) }).
+		Return(nil)
+	require.NoError(t, verificationSrv.SendVerification(context.TODO(), user))
+
+	link := regexp.MustCompile(`https://birthday\.example\.com/api/users/verify_email\?token=\S+`).FindString(body)
+	require.NotEmpty(t, link, "email body must contain the verification link")
+	parsedLink, err := url.Parse(link)
+	require.NoError(t, err)
+	token := parsedLink.Query().Get("token")
+
+	t.Run("confirms email", func(t *testing.T) {
+		call := store.On("MarkEmailVerified", mock.Anything, user.ID, user.Email, mock.Anything).Return(nil)
+		defer call.Unset()
+
+		assert.NoError(t, verificationSrv.Confirm(context.TODO(), token))
+		store.AssertCalled(t, "MarkEmailVerified", mock.Anything, user.ID, user.Email, mock.Anything)
+		issuedAt := store.Calls[len(store.Calls)-1].Arguments.Get(3).(time.Time)
+		assert.WithinDuration(t, time.Now(), issuedAt, time.Minute, "the storage needs the time the link was issued")
+	})
+
+	t.Run("rejects token for changed email", func(t *testing.T) {
+		call := store.On("MarkEmailVerified", mock.Anything, user.ID, user.Email, mock.Anything).
+			Return(storage.ErrUserNotFound{User: user})
+		defer call.Unset()
+
+		assert.ErrorIs(t, verificationSrv.Confirm(context.TODO(), token), services.ErrInvalidVerificationToken)
+	})
+
+	t.Run("rejects access token", func(t *testing.T) {
+		accessToken := buildJWTString(t, user.ID)
+		assert.ErrorIs(t, verificationSrv.Confirm(context.TODO(), accessToken), services.ErrInvalidVerificationToken)
+	})
+
+	t.Run("does not resend to verified email", func(t *testing.T) {
+		verifiedAt := time.Now()
+		verifiedUser := models.User{ID: 2, Email: "verified@example.com", EmailVerifiedAt: &verifiedAt}
+		store.On("FindUserByID", mock.Anything, verifiedUser.ID).Return(verifiedUser, nil)
+
+		assert.ErrorIs(t, verificationSrv.Resend(context.TODO(), verifiedUser.ID), services.ErrEmailAlreadyVerified)
+		sender.AssertNotCalled(t, "Send", verifiedUser.Email, mock.Anything, mock.Anything)
+	})
+}
+
+func TestEmailChange(t *testing.T) {
+	sender := new(notificationSender)
+	sender.On("Send", "new@example.com", "Confirm your email address", mock.Anything).Return(nil)
+	sender.On("Send", "old@example.com", "Your email is being changed", mock.Anything).Return(nil)
+	verificationSrv := services.NewEmailVerificationService(
+		new(emailVerificationStorage),
+		jwtManager,
+		sender,
+		"https://birthday.example.com",
+		time.Hour,
+	)
+
+	user := models.User{ID: 1, Email: "old@example.com"}
+	require.NoError(t, verificationSrv.SendEmailChange(context.TODO(), user, "new@example.com"))
+	sender.AssertExpectations(t)
+}
@@ -0,0 +1,229 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ilya-burinskiy/birthday-notify/internal/models"
+)
+
+const (
+	// the longest delay between failed logins before the lockout
+	maxLoginDelay = time.Minute
+	// lockouts returned by the admin endpoint
+	loginLockoutsLimit = 100
+)
+
+// ErrTooManyLoginAttempts is returned when the account or the client address
+// has to wait before the next login attempt.
+type ErrTooManyLoginAttempts struct {
+	RetryAfter time.Duration
+}
+
+func (err ErrTooManyLoginAttempts) Error() string {
+	return "too many failed login attempts, try again later"
+}
+
+type LoginThrottleStorage interface {
+	FindUserByID(ctx context.Context, userID int) (models.User, error)
+	FindLoginThrottle(ctx context.Context, scope, key string) (models.LoginThrottle, error)
+	ReserveLoginAttempt(
+		ctx context.Context,
+		scope, key string,
+		at, windowStart time.Time,
+		allow func(models.LoginThrottle) error,
+	) (models.LoginThrottle, error)
+	ReleaseLoginAttempt(ctx context.Context, scope, key string) error
+	LockLogin(ctx context.Context, lockout models.LoginLockout) (models.LoginLockout, error)
+	ResetLoginThrottle(ctx context.Context, scope, key string) error
+	UnlockLogin(ctx context.Context, scope, key string) error
+	ListLoginLockouts(ctx context.Context, limit int) ([]models.LoginLockout, error)
+}
+
+// LoginThrottleService slows down password guessing. Failures are counted
+// per account and per client address. After half of the allowed attempts
+// every next attempt has to wait twice as long as the previous one, after
+// all of them the key is locked for the lockout duration. Failures older
+// than the lockout duration are forgotten. Every attempt is counted as a
+// failure up front and given back once it succeeds, so parallel guesses are
+// throttled like sequential ones.
+type LoginThrottleService struct {
+	storage       LoginThrottleStorage
+	maxAttempts   int
+	ipMaxAttempts int
+	lockout       time.Duration
+}
+
+func NewLoginThrottleService(
+	storage LoginThrottleStorage,
+	maxAttempts int,
+	ipMaxAttempts int,
+	lockout time.Duration,
+) LoginThrottleService {
+
+	return LoginThrottleService{
+		storage:       storage,
+		maxAttempts:   maxAttempts,
+		ipMaxAttempts: ipMaxAttempts,
+		lockout:       lockout,
+	}
+}
+
+type loginKey struct {
+	scope string
+	key   string
+	limit int
+}
+
+// Check reserves an attempt for the account and the address. It returns
+// ErrTooManyLoginAttempts, without reserving anything, if either of them is
+// locked or has to wait after the last failure. A reserved attempt has to be
+// settled with Fail, Succeed or Release.
+func (srv LoginThrottleService) Check(ctx context.Context, email, ip string) error {
+	now := time.Now()
+	var reserved []loginKey
+	for _, k := range srv.keys(email, ip) {
+		allow := func(throttle models.LoginThrottle) error {
+			if retryAfter := srv.retryAfter(throttle, k.limit, now); retryAfter > 0 {
+				return ErrTooManyLoginAttempts{RetryAfter: retryAfter}
+			}
+			return nil
+		}
+		_, err := srv.storage.ReserveLoginAttempt(ctx, k.scope, k.key, now, now.Add(-srv.lockout), allow)
+		if err != nil {
+			srv.release(ctx, reserved)
+			var throttledErr ErrTooManyLoginAttempts
+			if errors.As(err, &throttledErr) {
+				return throttledErr
+			}
+			return fmt.Errorf("failed to check login attempts: %w", err)
+		}
+		reserved = append(reserved, k)
+	}
+
+	return nil
+}
+
+// Fail locks the keys that ran out of attempts. The attempt itself was
+// counted by Check.
+func (srv LoginThrottleService) Fail(ctx context.Context, email, ip string) error {
+	now := time.Now()
+	for _, k := range srv.keys(email, ip) {
+		throttle, err := srv.storage.FindLoginThrottle(ctx, k.scope, k.key)
+		if err != nil {
+			return fmt.Errorf("failed to record failed login: %w", err)
+		}
+		if throttle.Failures < k.limit || (throttle.LockedUntil != nil && throttle.LockedUntil.After(now)) {
+			continue
+		}
+
+		_, err = srv.storage.LockLogin(ctx, models.LoginLockout{
+			Scope:       k.scope,
+			Key:         k.key,
+			IP:          ip,
+			Failures:    throttle.Failures,
+			LockedAt:    now,
+			LockedUntil: now.Add(srv.lockout),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to record failed login: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Succeed forgets the failures of the account. Failures of the address are
+// kept, otherwise an attacker could reset them with their own account, only
+// the attempt reserved by Check is given back.
+func (srv LoginThrottleService) Succeed(ctx context.Context, email, ip string) error {
+	if err := srv.storage.ResetLoginThrottle(ctx, models.LoginScopeAccount, normalizeEmail(email)); err != nil {
+		return fmt.Errorf("failed to reset login attempts: %w", err)
+	}
+	if ip == "" {
+		return nil
+	}
+	if err := srv.storage.ReleaseLoginAttempt(ctx, models.LoginScopeIP, ip); err != nil {
+		return fmt.Errorf("failed to reset login attempts: %w", err)
+	}
+	return nil
+}
+
+// Release gives back the attempt reserved by Check when it was neither a
+// failure nor a completed login, e.g. a right password waiting for the
+// second factor.
+func (srv LoginThrottleService) Release(ctx context.Context, email, ip string) error {
+	if err := srv.release(ctx, srv.keys(email, ip)); err != nil {
+		return fmt.Errorf("failed to release login attempt: %w", err)
+	}
+	return nil
+}
+
+func (srv LoginThrottleService) release(ctx context.Context, keys []loginKey) error {
+	for _, k := range keys {
+		if err := srv.storage.ReleaseLoginAttempt(ctx, k.scope, k.key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// retryAfter is the time left until the next attempt is allowed.
+func (srv LoginThrottleService) retryAfter(throttle models.LoginThrottle, limit int, now time.Time) time.Duration {
+	if throttle.LockedUntil != nil && throttle.LockedUntil.After(now) {
+		return throttle.LockedUntil.Sub(now)
+	}
+	if throttle.LastFailedAt.Before(now.Add(-srv.lockout)) {
+		return 0
+	}
+	return throttle.LastFailedAt.Add(loginDelay(throttle.Failures, limit)).Sub(now)
+}
+
+// Unlock lifts the lockout of the user account.
+func (srv LoginThrottleService) Unlock(ctx context.Context, userID int) error {
+	user, err := srv.storage.FindUserByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to unlock user: %w", err)
+	}
+	if err := srv.storage.UnlockLogin(ctx, models.LoginScopeAccount, normalizeEmail(user.Email)); err != nil {
+		return fmt.Errorf("failed to unlock user: %w", err)
+	}
+	return nil
+}
+
+// Lockouts returns the latest lockouts.
+func (srv LoginThrottleService) Lockouts(ctx context.Context) ([]models.LoginLockout, error) {
+	return srv.storage.ListLoginLockouts(ctx, loginLockoutsLimit)
+}
+
+func (srv LoginThrottleService) keys(email, ip string) []loginKey {
+	keys := []loginKey{{scope: models.LoginScopeAccount, key: normalizeEmail(email), limit: srv.maxAttempts}}
+	if ip != "" {
+		keys = append(keys, loginKey{scope: models.LoginScopeIP, key: ip, limit: srv.ipMaxAttempts})
+	}
+	return keys
+}
+
+// loginDelay is the time to wait after the last of failures.
+func loginDelay(failures, limit int) time.Duration {
+	free := limit / 2
+	if failures <= free {
+		return 0
+	}
+
+	delay := time.Second
+	for i := free + 1; i < failures && delay < maxLoginDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxLoginDelay {
+		return maxLoginDelay
+	}
+	return delay
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
@@ -0,0 +1,177 @@
+package services_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ilya-burinskiy/birthday-notify/internal/models"
+	"github.com/ilya-burinskiy/birthday-notify/internal/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type loginThrottleStorage struct{ mock.Mock }
+
+func (s *loginThrottleStorage) FindUserByID(ctx context.Context, userID int) (models.User, error) {
+	args := s.Called(ctx, userID)
+	return args.Get(0).(models.User), args.Error(1)
+}
+
+func (s *loginThrottleStorage) FindLoginThrottle(ctx context.Context, scope, key string) (models.LoginThrottle, error) {
+	args := s.Called(ctx, scope, key)
+	return args.Get(0).(models.LoginThrottle), args.Error(1)
+}
+
+// ReserveLoginAttempt decides on the throttle the test returns for the key.
+func (s *loginThrottleStorage) ReserveLoginAttempt(
+	ctx context.Context,
+	scope, key string,
+	at, windowStart time.Time,
+	allow func(models.LoginThrottle) error,
+) (models.LoginThrottle, error) {
+
+	args := s.Called(ctx, scope, key, at, windowStart)
+	throttle := args.Get(0).(models.LoginThrottle)
+	if err := allow(throttle); err != nil {
+		return throttle, err
+	}
+	throttle.Failures++
+	throttle.LastFailedAt = at
+	return throttle, args.Error(1)
+}
+
+func (s *loginThrottleStorage) ReleaseLoginAttempt(ctx context.Context, scope, key string) error {
+	args := s.Called(ctx, scope, key)
+	return args.Error(0)
+}
+
+func (s *loginThrottleStorage) LockLogin(ctx context.Context, lockout models.LoginLockout) (models.LoginLockout, error) {
+	args := s.Called(ctx, lockout)
+	return args.Get(0).(models.LoginLockout), args.Error(1)
+}
+
+func (s *loginThrottleStorage) ResetLoginThrottle(ctx context.Context, scope, key string) error {
+	args := s.Called(ctx, scope, key)
+	return args.Error(0)
+}
+
+func (s *loginThrottleStorage) UnlockLogin(ctx context.Context, scope, key string) error {
+	args := s.Called(ctx, scope, key)
+	return args.Error(0)
+}
+
+func (s *loginThrottleStorage) ListLoginLockouts(ctx context.Context, limit int) ([]models.LoginLockout, error) {
+	args := s.Called(ctx, limit)
+	return args.Get(0).([]models.LoginLockout), args.Error(1)
+}
+
+func TestLoginThrottleCheck(t *testing.T) {
+	now := time.Now()
+	lockedUntil := now.Add(10 * time.Minute)
+
+	testCases := []struct {
+		name           string
+		account        models.LoginThrottle
+		wantRetryAfter time.Duration
+	}{
+		{
+			name:    "allows first attempt",
+			account: models.LoginThrottle{},
+		},
+		{
+			name:    "allows attempts without delay up to half of the limit",
+			account: models.LoginThrottle{Failures: 2, LastFailedAt: now},
+		},
+		{
+			name:           "delays attempts after half of the limit",
+			account:        models.LoginThrottle{Failures: 4, LastFailedAt: now},
+			wantRetryAfter: 2 * time.Second,
+		},
+		{
+			name:    "allows attempt after the delay",
+			account: models.LoginThrottle{Failures: 4, LastFailedAt: now.Add(-3 * time.Second)},
+		},
+		{
+			name:           "rejects locked account",
+			account:        models.LoginThrottle{Failures: 5, LastFailedAt: now, LockedUntil: &lockedUntil},
+			wantRetryAfter: 10 * time.Minute,
+		},
+		{
+			name:    "forgets old failures",
+			account: models.LoginThrottle{Failures: 4, LastFailedAt: now.Add(-time.Hour)},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := new(loginThrottleStorage)
+			store.On("ReserveLoginAttempt", mock.Anything, models.LoginScopeAccount, "email@example.com", mock.Anything, mock.Anything).
+				Return(tc.account, nil)
+			store.On("ReserveLoginAttempt", mock.Anything, models.LoginScopeIP, "127.0.0.1", mock.Anything, mock.Anything).
+				Return(models.LoginThrottle{}, nil)
+			throttleSrv := services.NewLoginThrottleService(store, 5, 50, 15*time.Minute)
+
+			err := throttleSrv.Check(context.TODO(), " Email@Example.com", "127.0.0.1")
+			if tc.wantRetryAfter == 0 {
+				assert.NoError(t, err)
+				return
+			}
+
+			var throttledErr services.ErrTooManyLoginAttempts
+			require.ErrorAs(t, err, &throttledErr)
+			assert.InDelta(t, tc.wantRetryAfter, throttledErr.RetryAfter, float64(time.Second))
+		})
+	}
+}
+
+func TestLoginThrottleCheckReleasesOnRejection(t *testing.T) {
+	lockedUntil := time.Now().Add(10 * time.Minute)
+	store := new(loginThrottleStorage)
+	store.On("ReserveLoginAttempt", mock.Anything, models.LoginScopeAccount, "email@example.com", mock.Anything, mock.Anything).
+		Return(models.LoginThrottle{}, nil)
+	store.On("ReserveLoginAttempt", mock.Anything, models.LoginScopeIP, "127.0.0.1", mock.Anything, mock.Anything).
+		Return(models.LoginThrottle{Failures: 50, LastFailedAt: time.Now(), LockedUntil: &lockedUntil}, nil)
+	store.On("ReleaseLoginAttempt", mock.Anything, models.LoginScopeAccount, "email@example.com").Return(nil)
+	throttleSrv := services.NewLoginThrottleService(store, 5, 50, 15*time.Minute)
+
+	err := throttleSrv.Check(context.TODO(), "email@example.com", "127.0.0.1")
+	assert.ErrorAs(t, err, &services.ErrTooManyLoginAttempts{})
+	store.AssertCalled(t, "ReleaseLoginAttempt", mock.Anything, models.LoginScopeAccount, "email@example.com")
+}
+
+func TestLoginThrottleSucceed(t *testing.T) {
+	store := new(loginThrottleStorage)
+	store.On("ResetLoginThrottle", mock.Anything, models.LoginScopeAccount, "email@example.com").Return(nil)
+	store.On("ReleaseLoginAttempt", mock.Anything, models.LoginScopeIP, "127.0.0.1").Return(nil)
+	throttleSrv := services.NewLoginThrottleService(store, 5, 50, 15*time.Minute)
+
+	require.NoError(t, throttleSrv.Succeed(context.TODO(), "Email@example.com", "127.0.0.1"))
+	store.AssertExpectations(t)
+}
+
+func TestLoginThrottleFail(t *testing.T) {
+	store := new(loginThrottleStorage)
+	store.On("FindLoginThrottle", mock.Anything, models.LoginScopeAccount, "email@example.com").
+		Return(models.LoginThrottle{Failures: 5}, nil)
+	store.On("FindLoginThrottle", mock.Anything, models.LoginScopeIP, "127.0.0.1").
+		Return(models.LoginThrottle{Failures: 5}, nil)
+	store.On("LockLogin", mock.Anything, mock.Anything).Return(models.LoginLockout{}, nil)
+	throttleSrv := services.NewLoginThrottleService(store, 5, 50, 15*time.Minute)
+
+	err := throttleSrv.Fail(context.TODO(), "email@example.com", "127.0.0.1")
+	require.NoError(t, err)
+
+	store.AssertNumberOfCalls(t, "LockLogin", 1)
+	var lockout models.LoginLockout
+	for _, call := range store.Calls {
+		if call.Method == "LockLogin" {
+			lockout = call.Arguments.Get(1).(models.LoginLockout)
+		}
+	}
+	assert.Equal(t, models.LoginScopeAccount, lockout.Scope)
+	assert.Equal(t, "email@example.com", lockout.Key)
+	assert.Equal(t, "127.0.0.1", lockout.IP)
+	assert.Equal(t, 15*time.Minute, lockout.LockedUntil.Sub(lockout.LockedAt))
+}
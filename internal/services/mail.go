@@ -1,38 +1,115 @@
 package services
 
 import (
+	"errors"
 	"fmt"
-	"net/smtp"
+	"mime"
+	"strings"
+	"time"
 
 	"github.com/ilya-burinskiy/birthday-notify/internal/configs"
 )
 
+const defaultMailFrom = "birthday-notify@localhost"
+
+// EmailSender sends notifications through a MailTransport.
 type EmailSender struct {
-	host     string
-	port     string
-	userName string
-	auth     smtp.Auth
+	from      string
+	transport MailTransport
 }
 
 func NewEmailSender(config configs.Config) EmailSender {
+	return NewTransportEmailSender(mailFrom(config), NewSMTPTransport(config))
+}
+
+func NewTransportEmailSender(from string, transport MailTransport) EmailSender {
 	return EmailSender{
-		host:     config.SMTPHost,
-		port:     config.SMTPPort,
-		userName: config.SMTPAuthUsername,
-		auth: smtp.PlainAuth(
-			config.SMTPAuthIdentity,
-			config.SMTPAuthUsername,
-			config.SMTPAuthPassword,
-			config.SMTPHost,
-		),
+		from:      from,
+		transport: transport,
 	}
 }
 
 func (sender EmailSender) Send(to string, subject string, body string) error {
-	smtpAddr := sender.host + ":" + sender.port
-	msg := "Subject: " + subject + "\n" + body
-	if err := smtp.SendMail(smtpAddr, sender.auth, sender.userName, []string{to}, []byte(msg)); err != nil {
+	err := sender.transport.Deliver(Email{
+		From:    sender.from,
+		To:      to,
+		Subject: subject,
+		Body:    body,
+	})
+	if err != nil {
 		return fmt.Errorf("failed to send email: %w", err)
 	}
 	return nil
 }
+
+// NewNotificationSender returns the sender selected by config.MailSender.
+// In sandbox mode the sender is wrapped so that no real recipient is emailed.
+func NewNotificationSender(config configs.Config) (NotificationSender, error) {
+	sender, err := newBaseNotificationSender(config)
+	if err != nil {
+		return nil, err
+	}
+	if !config.MailSandbox {
+		return sender, nil
+	}
+	if config.MailSandboxRecipient == "" && len(config.MailSandboxAllowedDomains) == 0 {
+		return nil, errors.New("sandbox mode requires a catch-all recipient or allowed domains")
+	}
+
+	return NewSandboxSender(sender, config.MailSandboxRecipient, config.MailSandboxAllowedDomains), nil
+}
+
+func newBaseNotificationSender(config configs.Config) (NotificationSender, error) {
+	switch config.MailSender {
+	case configs.MailSenderSMTP, "":
+		transport, err := NewMailTransport(config)
+		if err != nil {
+			return nil, err
+		}
+		return NewTransportEmailSender(mailFrom(config), transport), nil
+	case configs.MailSenderStdout:
+		return NewStdoutSender(mailFrom(config)), nil
+	case configs.MailSenderFile:
+		return NewFileSender(mailFrom(config), config.MailDir)
+	case configs.MailSenderMaildir:
+		return NewMaildirSender(mailFrom(config), config.MailDir)
+	default:
+		return nil, fmt.Errorf("unknown mail sender %q", config.MailSender)
+	}
+}
+
+func mailFrom(config configs.Config) string {
+	if config.MailFrom != "" {
+		return config.MailFrom
+	}
+	if config.SMTPAuthUsername != "" {
+		return config.SMTPAuthUsername
+	}
+	return defaultMailFrom
+}
+
+var ErrInvalidMailHeader = errors.New("mail header must not contain line breaks")
+
+// buildMessage refuses header values with line breaks, otherwise a value
+// could add headers of its own. The subject carries user names, so it is
+// encoded as RFC 2047 words when it is not plain ASCII.
+func buildMessage(from, to, subject, body string) ([]byte, error) {
+	for _, value := range []string{from, to, subject} {
+		if strings.ContainsAny(value, "\r\n") {
+			return nil, ErrInvalidMailHeader
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("From: " + from + "\r\n")
+	b.WriteString("To: " + to + "\r\n")
+	b.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", subject) + "\r\n")
+	b.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(body)
+	b.WriteString("\r\n")
+
+	return []byte(b.String()), nil
+}
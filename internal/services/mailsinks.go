@@ -0,0 +1,128 @@
+package services
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// StdoutSender writes every message to an io.Writer instead of delivering it.
+type StdoutSender struct {
+	from string
+	out  io.Writer
+	mu   *sync.Mutex
+}
+
+func NewStdoutSender(from string) StdoutSender {
+	return NewWriterSender(from, os.Stdout)
+}
+
+func NewWriterSender(from string, out io.Writer) StdoutSender {
+	return StdoutSender{
+		from: from,
+		out:  out,
+		mu:   &sync.Mutex{},
+	}
+}
+
+func (sender StdoutSender) Send(to string, subject string, body string) error {
+	sender.mu.Lock()
+	defer sender.mu.Unlock()
+
+	msg, err := buildMessage(sender.from, to, subject, body)
+	if err != nil {
+		return fmt.Errorf("failed to write email: %w", err)
+	}
+	if _, err := fmt.Fprintf(sender.out, "%s\n", msg); err != nil {
+		return fmt.Errorf("failed to write email: %w", err)
+	}
+	return nil
+}
+
+// FileSender stores every message as a separate .eml file in dir.
+type FileSender struct {
+	from string
+	dir  string
+}
+
+func NewFileSender(from, dir string) (FileSender, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return FileSender{}, fmt.Errorf("failed to create mail directory: %w", err)
+	}
+
+	return FileSender{
+		from: from,
+		dir:  dir,
+	}, nil
+}
+
+func (sender FileSender) Send(to string, subject string, body string) error {
+	msg, err := buildMessage(sender.from, to, subject, body)
+	if err != nil {
+		return fmt.Errorf("failed to write email: %w", err)
+	}
+	path := filepath.Join(sender.dir, uniqueMailName()+".eml")
+	if err := os.WriteFile(path, msg, 0o644); err != nil {
+		return fmt.Errorf("failed to write email: %w", err)
+	}
+	return nil
+}
+
+// MaildirSender delivers messages into a Maildir: each message is written to
+// tmp/ and then atomically moved to new/.
+type MaildirSender struct {
+	from string
+	dir  string
+}
+
+func NewMaildirSender(from, dir string) (MaildirSender, error) {
+	for _, sub := range []string{"tmp", "new", "cur"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o755); err != nil {
+			return MaildirSender{}, fmt.Errorf("failed to create maildir: %w", err)
+		}
+	}
+
+	return MaildirSender{
+		from: from,
+		dir:  dir,
+	}, nil
+}
+
+func (sender MaildirSender) Send(to string, subject string, body string) error {
+	msg, err := buildMessage(sender.from, to, subject, body)
+	if err != nil {
+		return fmt.Errorf("failed to write email: %w", err)
+	}
+	name := uniqueMailName()
+	tmpPath := filepath.Join(sender.dir, "tmp", name)
+	if err := os.WriteFile(tmpPath, msg, 0o644); err != nil {
+		return fmt.Errorf("failed to write email: %w", err)
+	}
+	if err := os.Rename(tmpPath, filepath.Join(sender.dir, "new", name)); err != nil {
+		return fmt.Errorf("failed to deliver email: %w", err)
+	}
+	return nil
+}
+
+var mailCounter atomic.Uint64
+
+func uniqueMailName() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "localhost"
+	}
+	now := time.Now()
+
+	return fmt.Sprintf(
+		"%d.M%dP%dQ%d.%s",
+		now.Unix(),
+		now.Nanosecond()/1000,
+		os.Getpid(),
+		mailCounter.Add(1),
+		hostname,
+	)
+}
@@ -0,0 +1,86 @@
+package services_test
+
+import (
+	"bytes"
+	"mime"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ilya-burinskiy/birthday-notify/internal/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriterSender(t *testing.T) {
+	var out bytes.Buffer
+	sender := services.NewWriterSender("from@example.com", &out)
+
+	err := sender.Send("to@example.com", "Birthday notification", "body")
+	require.NoError(t, err)
+
+	assert.Contains(t, out.String(), "From: from@example.com\r\n")
+	assert.Contains(t, out.String(), "To: to@example.com\r\n")
+	assert.Contains(t, out.String(), "Subject: Birthday notification\r\n")
+	assert.Contains(t, out.String(), "\r\n\r\nbody\r\n")
+}
+
+func TestWriterSenderRejectsHeaderInjection(t *testing.T) {
+	var out bytes.Buffer
+	sender := services.NewWriterSender("from@example.com", &out)
+
+	err := sender.Send("to@example.com", "Hi\r\nBcc: attacker@example.com", "body")
+	assert.ErrorIs(t, err, services.ErrInvalidMailHeader)
+	err = sender.Send("to@example.com\nBcc: attacker@example.com", "subject", "body")
+	assert.ErrorIs(t, err, services.ErrInvalidMailHeader)
+	assert.Empty(t, out.String())
+}
+
+func TestWriterSenderEncodesSubject(t *testing.T) {
+	var out bytes.Buffer
+	sender := services.NewWriterSender("from@example.com", &out)
+
+	err := sender.Send("to@example.com", "Upcoming birthday: Алиса", "body")
+	require.NoError(t, err)
+
+	assert.Contains(t, out.String(), "Subject: =?utf-8?q?Upcoming_birthday:_")
+	msg, err := mail.ReadMessage(&out)
+	require.NoError(t, err)
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	require.NoError(t, err)
+	assert.Equal(t, "Upcoming birthday: Алиса", subject)
+}
+
+func TestFileSender(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "mail")
+	sender, err := services.NewFileSender("from@example.com", dir)
+	require.NoError(t, err)
+
+	require.NoError(t, sender.Send("first@example.com", "subject", "body"))
+	require.NoError(t, sender.Send("second@example.com", "subject", "body"))
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	require.NoError(t, err)
+	assert.Len(t, files, 2)
+}
+
+func TestMaildirSender(t *testing.T) {
+	dir := t.TempDir()
+	sender, err := services.NewMaildirSender("from@example.com", dir)
+	require.NoError(t, err)
+
+	require.NoError(t, sender.Send("to@example.com", "subject", "body"))
+
+	tmpEntries, err := os.ReadDir(filepath.Join(dir, "tmp"))
+	require.NoError(t, err)
+	assert.Empty(t, tmpEntries)
+
+	newEntries, err := os.ReadDir(filepath.Join(dir, "new"))
+	require.NoError(t, err)
+	require.Len(t, newEntries, 1)
+
+	msg, err := os.ReadFile(filepath.Join(dir, "new", newEntries[0].Name()))
+	require.NoError(t, err)
+	assert.Contains(t, string(msg), "To: to@example.com\r\n")
+}
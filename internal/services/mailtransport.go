@@ -0,0 +1,189 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/smtp"
+	"time"
+
+	"github.com/ilya-burinskiy/birthday-notify/internal/configs"
+)
+
+type Email struct {
+	From    string
+	To      string
+	Subject string
+	Body    string
+}
+
+// MailTransport delivers a single email to a mail provider.
+type MailTransport interface {
+	Name() string
+	Deliver(email Email) error
+}
+
+type SMTPTransport struct {
+	host string
+	port string
+	user string
+	auth smtp.Auth
+}
+
+func NewSMTPTransport(config configs.Config) SMTPTransport {
+	return SMTPTransport{
+		host: config.SMTPHost,
+		port: config.SMTPPort,
+		user: config.SMTPAuthUsername,
+		auth: smtp.PlainAuth(
+			config.SMTPAuthIdentity,
+			config.SMTPAuthUsername,
+			config.SMTPAuthPassword,
+			config.SMTPHost,
+		),
+	}
+}
+
+func (transport SMTPTransport) Name() string {
+	return configs.MailTransportSMTP
+}
+
+func (transport SMTPTransport) Deliver(email Email) error {
+	smtpAddr := transport.host + ":" + transport.port
+	msg, err := buildMessage(email.From, email.To, email.Subject, email.Body)
+	if err != nil {
+		return fmt.Errorf("failed to send email via smtp: %w", err)
+	}
+	envelopeFrom := transport.user
+	if envelopeFrom == "" {
+		envelopeFrom = email.From
+	}
+	if err := smtp.SendMail(smtpAddr, transport.auth, envelopeFrom, []string{email.To}, msg); err != nil {
+		return fmt.Errorf("failed to send email via smtp: %w", err)
+	}
+	return nil
+}
+
+// HTTPTransport posts emails as JSON to a mail provider API:
+//
+//	{"from": "...", "to": "...", "subject": "...", "text": "..."}
+//
+// Any non-2xx response is treated as a delivery failure.
+type HTTPTransport struct {
+	url    string
+	apiKey string
+	client *http.Client
+}
+
+func NewHTTPTransport(url, apiKey string, timeout time.Duration) HTTPTransport {
+	return HTTPTransport{
+		url:    url,
+		apiKey: apiKey,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+func (transport HTTPTransport) Name() string {
+	return configs.MailTransportHTTP
+}
+
+func (transport HTTPTransport) Deliver(email Email) error {
+	type payload struct {
+		From    string `json:"from"`
+		To      string `json:"to"`
+		Subject string `json:"subject"`
+		Text    string `json:"text"`
+	}
+
+	reqBody, err := json.Marshal(payload{
+		From:    email.From,
+		To:      email.To,
+		Subject: email.Subject,
+		Text:    email.Body,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode email: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, transport.url, bytes.NewReader(reqBody))
+	if err != nil {
+		return fmt.Errorf("failed to build mail api request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if transport.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+transport.apiKey)
+	}
+
+	resp, err := transport.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send email via http: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("failed to send email via http: status %d: %s", resp.StatusCode, bytes.TrimSpace(respBody))
+	}
+	return nil
+}
+
+// FailoverTransport tries transports in priority order and stops at the first
+// one that delivers the email.
+type FailoverTransport struct {
+	transports []MailTransport
+}
+
+func NewFailoverTransport(transports ...MailTransport) FailoverTransport {
+	return FailoverTransport{
+		transports: transports,
+	}
+}
+
+func (transport FailoverTransport) Name() string {
+	return "failover"
+}
+
+func (transport FailoverTransport) Deliver(email Email) error {
+	if len(transport.transports) == 0 {
+		return errors.New("no mail transports configured")
+	}
+
+	var errs []error
+	for _, t := range transport.transports {
+		err := t.Deliver(email)
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", t.Name(), err))
+	}
+	return fmt.Errorf("all mail transports failed: %w", errors.Join(errs...))
+}
+
+// NewMailTransport builds the transport chain listed in config.MailTransports.
+func NewMailTransport(config configs.Config) (MailTransport, error) {
+	transports := make([]MailTransport, 0, len(config.MailTransports))
+	for _, name := range config.MailTransports {
+		switch name {
+		case configs.MailTransportSMTP:
+			transports = append(transports, NewSMTPTransport(config))
+		case configs.MailTransportHTTP:
+			if config.MailHTTPURL == "" {
+				return nil, errors.New("MAIL_HTTP_URL is required for http mail transport")
+			}
+			transports = append(
+				transports,
+				NewHTTPTransport(config.MailHTTPURL, config.MailHTTPAPIKey, config.MailHTTPTimeout),
+			)
+		default:
+			return nil, fmt.Errorf("unknown mail transport %q", name)
+		}
+	}
+
+	if len(transports) == 1 {
+		return transports[0], nil
+	}
+	return NewFailoverTransport(transports...), nil
+}
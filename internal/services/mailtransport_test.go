@@ -0,0 +1,83 @@
+package services_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ilya-burinskiy/birthday-notify/internal/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type mailTransport struct{ mock.Mock }
+
+func (m *mailTransport) Name() string {
+	return "mock"
+}
+
+func (m *mailTransport) Deliver(email services.Email) error {
+	args := m.Called(email)
+	return args.Error(0)
+}
+
+func TestHTTPTransport(t *testing.T) {
+	var received map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer key", r.Header.Get("Authorization"))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	transport := services.NewHTTPTransport(server.URL, "key", time.Second)
+	err := transport.Deliver(services.Email{From: "from", To: "to", Subject: "subject", Body: "body"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"from": "from", "to": "to", "subject": "subject", "text": "body"}, received)
+}
+
+func TestFailoverTransport(t *testing.T) {
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+	email := services.Email{From: "from", To: "to", Subject: "subject", Body: "body"}
+
+	testCases := []struct {
+		name        string
+		fallbackErr error
+		errMsg      string
+	}{
+		{
+			name: "delivers via fallback when primary fails",
+		},
+		{
+			name:        "returns error when all transports fail",
+			fallbackErr: errors.New("error"),
+			errMsg:      "all mail transports failed: http: failed to send email via http: status 503: \nmock: error",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fallback := new(mailTransport)
+			fallback.On("Deliver", email).Return(tc.fallbackErr)
+			transport := services.NewFailoverTransport(
+				services.NewHTTPTransport(failing.URL, "", time.Second),
+				fallback,
+			)
+
+			err := transport.Deliver(email)
+			if tc.errMsg == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.errMsg)
+			}
+			fallback.AssertExpectations(t)
+		})
+	}
+}
@@ -2,7 +2,9 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/go-co-op/gocron"
@@ -10,51 +12,229 @@ import (
 	"go.uber.org/zap"
 )
 
-type NotificationsForCurrentDateFetcher interface {
-	FetchNotificationsForCurrentDate(ctx context.Context) ([]models.Notification, error)
+type NotificationsForDateFetcher interface {
+	FetchNotificationsForDate(ctx context.Context, date time.Time) ([]models.Notification, error)
+}
+
+type NotificationRecorder interface {
+	RecordNotification(ctx context.Context, notification models.Notification, date time.Time) error
 }
 
 type NotificationSender interface {
 	Send(to string, subject string, body string) error
 }
 
+var ErrNotifierStopped = errors.New("notifier is shutting down")
+
+type NotificationReport struct {
+	Date          string                `json:"date"`
+	DryRun        bool                  `json:"dry_run"`
+	Notifications []models.Notification `json:"notifications"`
+	Sent          int                   `json:"sent"`
+	Failed        int                   `json:"failed"`
+}
+
 type Notifier struct {
 	logger  *zap.Logger
-	fetcher NotificationsForCurrentDateFetcher
+	fetcher NotificationsForDateFetcher
 	sender  NotificationSender
+	history NotificationRecorder
+
+	scheduler *gocron.Scheduler
+	inFlight  *batches
+	runCtx    context.Context
+	cancelRun context.CancelFunc
 }
 
-func NewNotifier(logger *zap.Logger, fetcher NotificationsForCurrentDateFetcher, sender NotificationSender) Notifier {
+func NewNotifier(
+	logger *zap.Logger,
+	fetcher NotificationsForDateFetcher,
+	sender NotificationSender,
+	history NotificationRecorder,
+) Notifier {
+
+	runCtx, cancelRun := context.WithCancel(context.Background())
 	return Notifier{
-		logger:  logger,
-		fetcher: fetcher,
-		sender:  sender,
+		logger:    logger,
+		fetcher:   fetcher,
+		sender:    sender,
+		history:   history,
+		scheduler: gocron.NewScheduler(time.UTC),
+		inFlight:  &batches{},
+		runCtx:    runCtx,
+		cancelRun: cancelRun,
 	}
 }
 
 func (notifier Notifier) Start() {
-	scheduler := gocron.NewScheduler(time.UTC)
-	scheduler.Cron("0 12 * * *").Do(notifier.notify)
-	scheduler.StartAsync()
+	notifier.scheduler.Cron("0 12 * * *").Do(notifier.notify)
+	notifier.scheduler.StartAsync()
 }
 
-func (notifier Notifier) notify() {
-	notifications, err := notifier.fetcher.FetchNotificationsForCurrentDate(context.Background()) 
+// Stop prevents new scheduled runs and waits for the current batch to finish.
+// If ctx expires first, the batch is interrupted between two sends and the
+// progress is logged so that the remaining notifications can be re-run with
+// the notify command. Stop still waits for the send in progress, so that the
+// caller can close the database afterwards. Stop can be called again.
+func (notifier Notifier) Stop(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		notifier.scheduler.Stop()
+		notifier.inFlight.stop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		notifier.cancelRun()
+		return nil
+	case <-ctx.Done():
+		notifier.cancelRun()
+		<-done
+		return fmt.Errorf("notification batch interrupted: %w", ctx.Err())
+	}
+}
+
+// Run sends notifications due on date. In dry-run mode the notifications are
+// only fetched and returned.
+func (notifier Notifier) Run(ctx context.Context, date time.Time, dryRun bool) (NotificationReport, error) {
+	report := NotificationReport{
+		Date:   date.Format(time.DateOnly),
+		DryRun: dryRun,
+	}
+	notifications, err := notifier.fetcher.FetchNotificationsForDate(ctx, date)
 	if err != nil {
-		notifier.logger.Info("failed to fetch notifications", zap.Error(err))
-		return
+		return report, fmt.Errorf("failed to fetch notifications: %w", err)
+	}
+	report.Notifications = notifications
+	if dryRun {
+		return report, nil
 	}
 
-	subject := "Birthday notification"
-	body := "The user %s has birthday in %d days"
-	for _, notification := range notifications {
-		err := notifier.sender.Send(
-			notification.SubscribingUserEmail,
-			subject,
-			fmt.Sprintf(body, notification.SubscribedUserEmail, notification.DaysBeforeNotify),
-		)
-		if err != nil {
+	for i, notification := range notifications {
+		if err := ctx.Err(); err != nil {
+			notifier.logger.Info(
+				"notification batch interrupted",
+				zap.String("date", report.Date),
+				zap.Int("sent", report.Sent),
+				zap.Int("failed", report.Failed),
+				zap.Int("remaining", len(notifications)-i),
+			)
+			return report, fmt.Errorf("notification batch interrupted: %w", err)
+		}
+
+		subject, body := notificationMessage(notification)
+		if err := notifier.sender.Send(notification.SubscribingUserEmail, subject, body); err != nil {
 			notifier.logger.Info("failed to send notifiaction", zap.Error(err))
+			report.Failed++
+			continue
+		}
+		report.Sent++
+
+		// later runs for the date skip notifications in the history, one
+		// that failed to be recorded may be sent again
+		if err := notifier.history.RecordNotification(ctx, notification, date); err != nil {
+			notifier.logger.Info("failed to record notification", zap.Error(err))
 		}
 	}
+
+	return report, nil
+}
+
+// Trigger runs the batch for date on behalf of a request. The batch is not
+// tied to ctx: like a scheduled one it goes on if the caller goes away, and
+// Stop waits for it. The report is returned if the batch finishes before ctx
+// is done. Dry runs only read, so they run with ctx.
+func (notifier Notifier) Trigger(ctx context.Context, date time.Time, dryRun bool) (NotificationReport, error) {
+	if dryRun {
+		return notifier.Run(ctx, date, true)
+	}
+	if !notifier.inFlight.start() {
+		return NotificationReport{}, ErrNotifierStopped
+	}
+
+	type result struct {
+		report NotificationReport
+		err    error
+	}
+	finished := make(chan result, 1)
+	go func() {
+		defer notifier.inFlight.done()
+		report, err := notifier.Run(notifier.runCtx, date, false)
+		if err != nil {
+			notifier.logger.Info("failed to run notifications", zap.Error(err))
+		}
+		finished <- result{report: report, err: err}
+	}()
+
+	select {
+	case res := <-finished:
+		return res.report, res.err
+	case <-ctx.Done():
+		return NotificationReport{}, fmt.Errorf("notification batch continues in the background: %w", ctx.Err())
+	}
+}
+
+func (notifier Notifier) notify() {
+	if !notifier.inFlight.start() {
+		return
+	}
+	defer notifier.inFlight.done()
+
+	_, err := notifier.Run(notifier.runCtx, time.Now().UTC(), false)
+	if err != nil {
+		notifier.logger.Info("failed to run notifications", zap.Error(err))
+	}
+}
+
+// batches tracks running batches. The scheduler and Trigger start batches in
+// their own goroutines, so a batch is registered under the lock and refused
+// once Stop has begun waiting, instead of racing with the wait.
+type batches struct {
+	mu      sync.Mutex
+	stopped bool
+	running sync.WaitGroup
+}
+
+func (b *batches) start() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.stopped {
+		return false
+	}
+	b.running.Add(1)
+	return true
+}
+
+func (b *batches) done() {
+	b.running.Done()
+}
+
+func (b *batches) stop() {
+	b.mu.Lock()
+	b.stopped = true
+	b.mu.Unlock()
+	b.running.Wait()
+}
+
+// notificationMessage addresses both users by name, users who did not fill
+// in their names are addressed by email.
+func notificationMessage(notification models.Notification) (string, string) {
+	subject := "Upcoming birthday: " + notification.SubscribedUserName
+	var when string
+	switch notification.DaysBeforeNotify {
+	case 0:
+		when = "today"
+	case 1:
+		when = "tomorrow"
+	default:
+		when = fmt.Sprintf("in %d days", notification.DaysBeforeNotify)
+	}
+	body := fmt.Sprintf(
+		"Hi %s,\n\n%s has a birthday %s.",
+		notification.SubscribingUserName,
+		notification.SubscribedUserName,
+		when,
+	)
+	return subject, body
 }
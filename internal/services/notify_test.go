@@ -0,0 +1,199 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ilya-burinskiy/birthday-notify/internal/models"
+	"github.com/ilya-burinskiy/birthday-notify/internal/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type notificationsFetcher struct{ mock.Mock }
+
+func (f *notificationsFetcher) FetchNotificationsForDate(ctx context.Context, date time.Time) ([]models.Notification, error) {
+	args := f.Called(ctx, date)
+	return args.Get(0).([]models.Notification), args.Error(1)
+}
+
+type notificationRecorder struct{ mock.Mock }
+
+func (r *notificationRecorder) RecordNotification(
+	ctx context.Context,
+	notification models.Notification,
+	date time.Time,
+) error {
+
+	args := r.Called(ctx, notification, date)
+	return args.Error(0)
+}
+
+func TestNotifierRun(t *testing.T) {
+	date := time.Date(2024, 6, 10, 0, 0, 0, 0, time.UTC)
+	notifications := []models.Notification{
+		{
+			SubscribingUserID:    1,
+			SubscribingUserEmail: "alice@example.com",
+			SubscribingUserName:  "Alice",
+			DaysBeforeNotify:     1,
+			SubscribedUserID:     2,
+			SubscribedUserEmail:  "bob@example.com",
+			SubscribedUserName:   "Bob Smith",
+		},
+		{
+			SubscribingUserID:    3,
+			SubscribingUserEmail: "carol@example.com",
+			SubscribingUserName:  "carol@example.com",
+			DaysBeforeNotify:     2,
+			SubscribedUserID:     2,
+			SubscribedUserEmail:  "bob@example.com",
+			SubscribedUserName:   "Bob Smith",
+		},
+	}
+	fetcher := new(notificationsFetcher)
+	fetcher.On("FetchNotificationsForDate", mock.Anything, date).Return(notifications, nil)
+
+	t.Run("dry run does not send notifications", func(t *testing.T) {
+		sender := new(notificationSender)
+		history := new(notificationRecorder)
+		notifier := services.NewNotifier(zap.NewNop(), fetcher, sender, history)
+
+		report, err := notifier.Run(context.TODO(), date, true)
+		require.NoError(t, err)
+		assert.Equal(t, services.NotificationReport{
+			Date:          "2024-06-10",
+			DryRun:        true,
+			Notifications: notifications,
+		}, report)
+		sender.AssertNotCalled(t, "Send", mock.Anything, mock.Anything, mock.Anything)
+		history.AssertNotCalled(t, "RecordNotification", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("sends notifications and counts failures", func(t *testing.T) {
+		sender := new(notificationSender)
+		sender.On("Send", "alice@example.com", "Upcoming birthday: Bob Smith", "Hi Alice,\n\nBob Smith has a birthday tomorrow.").
+			Return(nil)
+		sender.On("Send", "carol@example.com", "Upcoming birthday: Bob Smith", "Hi carol@example.com,\n\nBob Smith has a birthday in 2 days.").
+			Return(errors.New("error"))
+		history := new(notificationRecorder)
+		history.On("RecordNotification", mock.Anything, notifications[0], date).Return(nil)
+		notifier := services.NewNotifier(zap.NewNop(), fetcher, sender, history)
+
+		report, err := notifier.Run(context.TODO(), date, false)
+		require.NoError(t, err)
+		assert.Equal(t, 1, report.Sent)
+		assert.Equal(t, 1, report.Failed)
+		sender.AssertExpectations(t)
+		history.AssertNumberOfCalls(t, "RecordNotification", 1)
+	})
+
+	t.Run("stops batch when context is canceled", func(t *testing.T) {
+		sender := new(notificationSender)
+		notifier := services.NewNotifier(zap.NewNop(), fetcher, sender, new(notificationRecorder))
+		ctx, cancel := context.WithCancel(context.TODO())
+		cancel()
+
+		report, err := notifier.Run(ctx, date, false)
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Equal(t, 0, report.Sent)
+		sender.AssertNotCalled(t, "Send", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestNotifierTrigger(t *testing.T) {
+	date := time.Date(2024, 6, 10, 0, 0, 0, 0, time.UTC)
+	notification := models.Notification{
+		SubscribingUserID:    1,
+		SubscribingUserEmail: "alice@example.com",
+		SubscribingUserName:  "Alice",
+		DaysBeforeNotify:     1,
+		SubscribedUserID:     2,
+		SubscribedUserName:   "Bob",
+	}
+	fetcher := new(notificationsFetcher)
+	fetcher.On("FetchNotificationsForDate", mock.Anything, date).Return([]models.Notification{notification}, nil)
+	sending := make(chan struct{})
+	unblock := make(chan struct{})
+	sender := new(notificationSender)
+	sender.On("Send", "alice@example.com", mock.Anything, mock.Anything).
+		Run(func(mock.Arguments) {
+			close(sending)
+			<-unblock
+		}).
+		Return(nil)
+	history := new(notificationRecorder)
+	history.On("RecordNotification", mock.Anything, notification, date).Return(nil)
+	notifier := services.NewNotifier(zap.NewNop(), fetcher, sender, history)
+
+	// the caller goes away while the batch is sending
+	ctx, cancel := context.WithCancel(context.TODO())
+	go func() {
+		<-sending
+		cancel()
+	}()
+	_, err := notifier.Trigger(ctx, date, false)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	stopped := make(chan error, 1)
+	go func() { stopped <- notifier.Stop(context.TODO()) }()
+	select {
+	case <-stopped:
+		t.Fatal("Stop must wait for the triggered batch")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(unblock)
+	require.NoError(t, <-stopped)
+	history.AssertCalled(t, "RecordNotification", mock.Anything, notification, date)
+
+	_, err = notifier.Trigger(context.TODO(), date, false)
+	assert.ErrorIs(t, err, services.ErrNotifierStopped)
+}
+
+func TestNotifierStopInterruptsBatch(t *testing.T) {
+	date := time.Date(2024, 6, 10, 0, 0, 0, 0, time.UTC)
+	notifications := []models.Notification{
+		{SubscribingUserID: 1, SubscribingUserEmail: "alice@example.com", SubscribedUserID: 2},
+		{SubscribingUserID: 3, SubscribingUserEmail: "carol@example.com", SubscribedUserID: 2},
+	}
+	fetcher := new(notificationsFetcher)
+	fetcher.On("FetchNotificationsForDate", mock.Anything, date).Return(notifications, nil)
+	sending := make(chan struct{})
+	unblock := make(chan struct{})
+	sender := new(notificationSender)
+	sender.On("Send", "alice@example.com", mock.Anything, mock.Anything).
+		Run(func(mock.Arguments) {
+			close(sending)
+			<-unblock
+		}).
+		Return(nil)
+	history := new(notificationRecorder)
+	history.On("RecordNotification", mock.Anything, mock.Anything, date).Return(nil)
+	notifier := services.NewNotifier(zap.NewNop(), fetcher, sender, history)
+
+	triggerCtx, cancelTrigger := context.WithCancel(context.TODO())
+	go func() {
+		<-sending
+		cancelTrigger()
+	}()
+	_, err := notifier.Trigger(triggerCtx, date, false)
+	require.ErrorIs(t, err, context.Canceled)
+
+	// the shutdown timeout expires while a send is in progress
+	ctx, cancel := context.WithTimeout(context.TODO(), 10*time.Millisecond)
+	defer cancel()
+	stopped := make(chan error, 1)
+	go func() { stopped <- notifier.Stop(ctx) }()
+	select {
+	case <-stopped:
+		t.Fatal("Stop must wait for the send in progress")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(unblock)
+	assert.ErrorIs(t, <-stopped, context.DeadlineExceeded)
+	sender.AssertNotCalled(t, "Send", "carol@example.com", mock.Anything, mock.Anything)
+}
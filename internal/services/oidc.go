@@ -0,0 +1,129 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ilya-burinskiy/birthday-notify/internal/auth"
+	"github.com/ilya-burinskiy/birthday-notify/internal/models"
+	"github.com/ilya-burinskiy/birthday-notify/internal/oidc"
+	"github.com/ilya-burinskiy/birthday-notify/internal/storage"
+)
+
+var (
+	ErrOIDCLoginFailed      = errors.New("single sign-on failed")
+	ErrOIDCEmailNotVerified = errors.New("single sign-on provider did not verify the email")
+)
+
+type OIDCProvider interface {
+	AuthCodeURL(ctx context.Context, req oidc.AuthRequest) (string, error)
+	Exchange(ctx context.Context, req oidc.AuthRequest, code string) (oidc.Identity, error)
+}
+
+type OIDCUserStorage interface {
+	FindUserByIdentity(ctx context.Context, issuer, subject string) (models.User, error)
+	FindUserByEmail(ctx context.Context, email string) (models.User, error)
+	LinkIdentity(ctx context.Context, userID int, issuer, subject string) error
+	CreateUserWithIdentity(ctx context.Context, email, issuer, subject string) (models.User, error)
+}
+
+type OIDCLoginService struct {
+	provider       OIDCProvider
+	storage        OIDCUserStorage
+	sessionStarter SessionStarter
+}
+
+func NewOIDCLoginService(
+	provider OIDCProvider,
+	storage OIDCUserStorage,
+	sessionStarter SessionStarter,
+) OIDCLoginService {
+
+	return OIDCLoginService{
+		provider:       provider,
+		storage:        storage,
+		sessionStarter: sessionStarter,
+	}
+}
+
+// Begin starts a login and returns the provider URL to redirect the user to.
+// The request must be kept by the client until Complete.
+func (srv OIDCLoginService) Begin(ctx context.Context) (oidc.AuthRequest, string, error) {
+	req, err := oidc.NewAuthRequest()
+	if err != nil {
+		return oidc.AuthRequest{}, "", fmt.Errorf("failed to start single sign-on: %w", err)
+	}
+	authURL, err := srv.provider.AuthCodeURL(ctx, req)
+	if err != nil {
+		return oidc.AuthRequest{}, "", fmt.Errorf("failed to start single sign-on: %w", err)
+	}
+
+	return req, authURL, nil
+}
+
+// Complete redeems the code, finds the user by the identity or links the
+// identity to the user with the same email, creating one if needed, and
+// starts a session.
+func (srv OIDCLoginService) Complete(ctx context.Context, req oidc.AuthRequest, code string) (auth.TokenPair, error) {
+	identity, err := srv.provider.Exchange(ctx, req, code)
+	if err != nil {
+		return auth.TokenPair{}, fmt.Errorf("%w: %v", ErrOIDCLoginFailed, err)
+	}
+
+	user, err := srv.findOrCreateUser(ctx, identity)
+	if err != nil {
+		return auth.TokenPair{}, err
+	}
+	if user.DisabledAt != nil {
+		return auth.TokenPair{}, ErrUserDisabled
+	}
+
+	tokens, err := srv.sessionStarter.Start(ctx, user.ID)
+	if err != nil {
+		return auth.TokenPair{}, fmt.Errorf("failed to start session: %w", err)
+	}
+
+	return tokens, nil
+}
+
+func (srv OIDCLoginService) findOrCreateUser(ctx context.Context, identity oidc.Identity) (models.User, error) {
+	var notFoundErr storage.ErrUserNotFound
+	user, err := srv.storage.FindUserByIdentity(ctx, identity.Issuer, identity.Subject)
+	if err == nil {
+		return user, nil
+	}
+	if !errors.As(err, &notFoundErr) {
+		return models.User{}, fmt.Errorf("failed to find user: %w", err)
+	}
+
+	// linking by email is safe only if the provider proved the user owns it
+	if identity.Email == "" || !identity.EmailVerified {
+		return models.User{}, ErrOIDCEmailNotVerified
+	}
+
+	// an account registered with this email but never verified may belong to
+	// someone else, LinkIdentity takes it away from them
+	user, err = srv.storage.FindUserByEmail(ctx, identity.Email)
+	if err == nil {
+		if err := srv.storage.LinkIdentity(ctx, user.ID, identity.Issuer, identity.Subject); err != nil {
+			return models.User{}, err
+		}
+		return user, nil
+	}
+	if !errors.As(err, &notFoundErr) {
+		return models.User{}, fmt.Errorf("failed to find user: %w", err)
+	}
+
+	user, err = srv.storage.CreateUserWithIdentity(ctx, identity.Email, identity.Issuer, identity.Subject)
+	if err != nil {
+		// a concurrent login of the same user created it first
+		var notUniqErr storage.ErrUserNotUniq
+		if errors.As(err, &notUniqErr) {
+			return srv.storage.FindUserByIdentity(ctx, identity.Issuer, identity.Subject)
+		}
+		return models.User{}, err
+	}
+
+	return user, nil
+}
@@ -0,0 +1,130 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ilya-burinskiy/birthday-notify/internal/models"
+	"github.com/ilya-burinskiy/birthday-notify/internal/oidc"
+	"github.com/ilya-burinskiy/birthday-notify/internal/services"
+	"github.com/ilya-burinskiy/birthday-notify/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type oidcProvider struct{ mock.Mock }
+
+func (p *oidcProvider) AuthCodeURL(ctx context.Context, req oidc.AuthRequest) (string, error) {
+	args := p.Called(ctx, req)
+	return args.String(0), args.Error(1)
+}
+
+func (p *oidcProvider) Exchange(ctx context.Context, req oidc.AuthRequest, code string) (oidc.Identity, error) {
+	args := p.Called(ctx, req, code)
+	return args.Get(0).(oidc.Identity), args.Error(1)
+}
+
+type oidcUserStorage struct{ mock.Mock }
+
+func (s *oidcUserStorage) FindUserByIdentity(ctx context.Context, issuer, subject string) (models.User, error) {
+	args := s.Called(ctx, issuer, subject)
+	return args.Get(0).(models.User), args.Error(1)
+}
+
+func (s *oidcUserStorage) FindUserByEmail(ctx context.Context, email string) (models.User, error) {
+	args := s.Called(ctx, email)
+	return args.Get(0).(models.User), args.Error(1)
+}
+
+func (s *oidcUserStorage) LinkIdentity(ctx context.Context, userID int, issuer, subject string) error {
+	args := s.Called(ctx, userID, issuer, subject)
+	return args.Error(0)
+}
+
+func (s *oidcUserStorage) CreateUserWithIdentity(ctx context.Context, email, issuer, subject string) (models.User, error) {
+	args := s.Called(ctx, email, issuer, subject)
+	return args.Get(0).(models.User), args.Error(1)
+}
+
+func TestOIDCLoginComplete(t *testing.T) {
+	identity := oidc.Identity{Issuer: "https://idp.example.com", Subject: "sub", Email: "email@example.com", EmailVerified: true}
+	unverified := identity
+	unverified.EmailVerified = false
+	notFound := storage.ErrUserNotFound{}
+
+	testCases := []struct {
+		name        string
+		identity    oidc.Identity
+		exchangeErr error
+		setup       func(store *oidcUserStorage)
+		wantUserID  int
+		wantErr     error
+	}{
+		{
+			name:     "signs in linked user",
+			identity: identity,
+			setup: func(store *oidcUserStorage) {
+				store.On("FindUserByIdentity", mock.Anything, identity.Issuer, identity.Subject).Return(models.User{ID: 1}, nil)
+			},
+			wantUserID: 1,
+		},
+		{
+			name:     "links identity to user with the same email",
+			identity: identity,
+			setup: func(store *oidcUserStorage) {
+				store.On("FindUserByIdentity", mock.Anything, identity.Issuer, identity.Subject).Return(models.User{}, notFound)
+				store.On("FindUserByEmail", mock.Anything, identity.Email).Return(models.User{ID: 2}, nil)
+				store.On("LinkIdentity", mock.Anything, 2, identity.Issuer, identity.Subject).Return(nil)
+			},
+			wantUserID: 2,
+		},
+		{
+			name:     "creates user",
+			identity: identity,
+			setup: func(store *oidcUserStorage) {
+				store.On("FindUserByIdentity", mock.Anything, identity.Issuer, identity.Subject).Return(models.User{}, notFound)
+				store.On("FindUserByEmail", mock.Anything, identity.Email).Return(models.User{}, notFound)
+				store.On("CreateUserWithIdentity", mock.Anything, identity.Email, identity.Issuer, identity.Subject).
+					Return(models.User{ID: 3}, nil)
+			},
+			wantUserID: 3,
+		},
+		{
+			name:     "does not link unverified email",
+			identity: unverified,
+			setup: func(store *oidcUserStorage) {
+				store.On("FindUserByIdentity", mock.Anything, identity.Issuer, identity.Subject).Return(models.User{}, notFound)
+			},
+			wantErr: services.ErrOIDCEmailNotVerified,
+		},
+		{
+			name:        "fails if code exchange fails",
+			exchangeErr: errors.New("invalid_grant"),
+			setup:       func(store *oidcUserStorage) {},
+			wantErr:     services.ErrOIDCLoginFailed,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			provider := new(oidcProvider)
+			provider.On("Exchange", mock.Anything, mock.Anything, "code").Return(tc.identity, tc.exchangeErr)
+			store := new(oidcUserStorage)
+			tc.setup(store)
+			loginSrv := services.NewOIDCLoginService(provider, store, sessionStarter{})
+
+			tokens, err := loginSrv.Complete(context.TODO(), oidc.AuthRequest{}, "code")
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+				store.AssertNotCalled(t, "LinkIdentity", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+				store.AssertNotCalled(t, "CreateUserWithIdentity", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantUserID, userIDFromJWT(t, tokens.AccessToken))
+		})
+	}
+}
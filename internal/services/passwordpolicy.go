@@ -0,0 +1,37 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ilya-burinskiy/birthday-notify/internal/auth"
+)
+
+type PasswordChecker interface {
+	Check(password string) ([]auth.PasswordViolation, error)
+}
+
+// ErrWeakPassword lists every rule a new password breaks.
+type ErrWeakPassword struct {
+	Violations []auth.PasswordViolation
+}
+
+func (err ErrWeakPassword) Error() string {
+	messages := make([]string, 0, len(err.Violations))
+	for _, violation := range err.Violations {
+		messages = append(messages, violation.Message)
+	}
+	return "weak password: " + strings.Join(messages, "; ")
+}
+
+// checkPassword returns ErrWeakPassword if the password breaks the policy.
+func checkPassword(checker PasswordChecker, password string) error {
+	violations, err := checker.Check(password)
+	if err != nil {
+		return fmt.Errorf("failed to check password: %w", err)
+	}
+	if len(violations) > 0 {
+		return ErrWeakPassword{Violations: violations}
+	}
+	return nil
+}
@@ -0,0 +1,185 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ilya-burinskiy/birthday-notify/internal/auth"
+	"github.com/ilya-burinskiy/birthday-notify/internal/models"
+	"github.com/ilya-burinskiy/birthday-notify/internal/storage"
+	"go.uber.org/zap"
+)
+
+const (
+	// passwordResetSendTimeout bounds the background work of Forgot.
+	passwordResetSendTimeout = time.Minute
+	// requests are counted until there was no request for the window
+	passwordResetWindow = time.Hour
+	// emails sent to one address within the window
+	passwordResetMaxEmails = 3
+	// requests accepted from one client address within the window
+	passwordResetIPMaxRequests = 20
+)
+
+var (
+	ErrInvalidPasswordResetToken = errors.New("invalid or expired password reset token")
+	ErrEmptyPassword             = errors.New("password must not be empty")
+	ErrTooManyPasswordResets     = errors.New("too many password reset requests, try again later")
+)
+
+type PasswordResetStorage interface {
+	FindUserByEmail(ctx context.Context, email string) (models.User, error)
+	ReserveLoginAttempt(
+		ctx context.Context,
+		scope, key string,
+		at, windowStart time.Time,
+		allow func(models.LoginThrottle) error,
+	) (models.LoginThrottle, error)
+	CreatePasswordResetToken(ctx context.Context, userID int, tokenHash []byte, expiresAt time.Time) error
+	ResetPassword(ctx context.Context, tokenHash []byte, encryptedPassword []byte) (int, error)
+}
+
+type PasswordResetService struct {
+	logger     *zap.Logger
+	storage    PasswordResetStorage
+	sender     NotificationSender
+	background Background
+	tokenExp   time.Duration
+	passwords  PasswordChecker
+}
+
+func NewPasswordResetService(
+	logger *zap.Logger,
+	storage PasswordResetStorage,
+	sender NotificationSender,
+	background Background,
+	tokenExp time.Duration,
+	passwords PasswordChecker,
+) PasswordResetService {
+
+	return PasswordResetService{
+		logger:     logger,
+		storage:    storage,
+		sender:     sender,
+		background: background,
+		tokenExp:   tokenExp,
+		passwords:  passwords,
+	}
+}
+
+// Forgot emails a reset token to the user. Unknown emails are silently
+// ignored so that the endpoint can not be used to find registered users. For
+// the same reason the token is stored and sent in the background: known and
+// unknown emails take the same time and get the same response.
+//
+// Every new token invalidates the previous one, so requests are limited per
+// client address (ErrTooManyPasswordResets) and per email. Requests over the
+// email limit are dropped silently: the owner can still use the last email
+// that arrived, and the response does not tell whether the email exists.
+func (srv PasswordResetService) Forgot(ctx context.Context, email, ip string) error {
+	if ip != "" {
+		if err := srv.reserve(ctx, models.PasswordResetScopeIP, ip, passwordResetIPMaxRequests); err != nil {
+			return err
+		}
+	}
+	if err := srv.reserve(ctx, models.PasswordResetScopeAccount, normalizeEmail(email), passwordResetMaxEmails); err != nil {
+		if errors.Is(err, ErrTooManyPasswordResets) {
+			return nil
+		}
+		return err
+	}
+
+	user, err := srv.storage.FindUserByEmail(ctx, email)
+	if err != nil {
+		var notFoundErr storage.ErrUserNotFound
+		if errors.As(err, &notFoundErr) {
+			return nil
+		}
+		return fmt.Errorf("failed to request password reset: %w", err)
+	}
+
+	started := srv.background.Go(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), passwordResetSendTimeout)
+		defer cancel()
+		if err := srv.sendToken(ctx, user); err != nil {
+			srv.logger.Info("failed to send password reset", zap.Int("user_id", user.ID), zap.Error(err))
+		}
+	})
+	if !started {
+		return ErrShuttingDown
+	}
+
+	return nil
+}
+
+// reserve counts the request for the key, it returns ErrTooManyPasswordResets
+// once the key made limit requests without a break of passwordResetWindow.
+func (srv PasswordResetService) reserve(ctx context.Context, scope, key string, limit int) error {
+	now := time.Now()
+	windowStart := now.Add(-passwordResetWindow)
+	allow := func(throttle models.LoginThrottle) error {
+		if throttle.Failures >= limit && !throttle.LastFailedAt.Before(windowStart) {
+			return ErrTooManyPasswordResets
+		}
+		return nil
+	}
+	_, err := srv.storage.ReserveLoginAttempt(ctx, scope, key, now, windowStart, allow)
+	if err != nil {
+		if errors.Is(err, ErrTooManyPasswordResets) {
+			return err
+		}
+		return fmt.Errorf("failed to request password reset: %w", err)
+	}
+	return nil
+}
+
+func (srv PasswordResetService) sendToken(ctx context.Context, user models.User) error {
+	token, tokenHash, err := auth.GeneratePasswordResetToken()
+	if err != nil {
+		return fmt.Errorf("failed to request password reset: %w", err)
+	}
+	err = srv.storage.CreatePasswordResetToken(ctx, user.ID, tokenHash, time.Now().Add(srv.tokenExp))
+	if err != nil {
+		return fmt.Errorf("failed to request password reset: %w", err)
+	}
+
+	body := fmt.Sprintf(
+		"Someone requested a password reset for your account. If it was you, reset the password "+
+			"with the token below, it expires in %s:\n\n%s\n\n"+
+			"If you did not request it, ignore this email.",
+		srv.tokenExp,
+		token,
+	)
+	if err := srv.sender.Send(user.Email, "Password reset", body); err != nil {
+		return fmt.Errorf("failed to send password reset email: %w", err)
+	}
+
+	return nil
+}
+
+// Reset sets a new password and signs the user out of every session.
+func (srv PasswordResetService) Reset(ctx context.Context, token, password string) error {
+	if password == "" {
+		return ErrEmptyPassword
+	}
+	if err := checkPassword(srv.passwords, password); err != nil {
+		return err
+	}
+
+	encryptedPassword, err := auth.HashPassword(password)
+	if err != nil {
+		return fmt.Errorf("failed to reset password: %w", err)
+	}
+	_, err = srv.storage.ResetPassword(ctx, auth.HashPasswordResetToken(token), encryptedPassword)
+	if err != nil {
+		var notFoundErr storage.ErrPasswordResetTokenNotFound
+		if errors.As(err, &notFoundErr) {
+			return ErrInvalidPasswordResetToken
+		}
+		return fmt.Errorf("failed to reset password: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,229 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ilya-burinskiy/birthday-notify/internal/auth"
+	"github.com/ilya-burinskiy/birthday-notify/internal/models"
+	"github.com/ilya-burinskiy/birthday-notify/internal/services"
+	"github.com/ilya-burinskiy/birthday-notify/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type passwordResetStorage struct{ mock.Mock }
+
+func (s *passwordResetStorage) FindUserByEmail(ctx context.Context, email string) (models.User, error) {
+	args := s.Called(ctx, email)
+	return args.Get(0).(models.User), args.Error(1)
+}
+
+func (s *passwordResetStorage) ReserveLoginAttempt(
+	ctx context.Context,
+	scope, key string,
+	at, windowStart time.Time,
+	allow func(models.LoginThrottle) error,
+) (models.LoginThrottle, error) {
+
+	args := s.Called(ctx, scope, key)
+	throttle := args.Get(0).(models.LoginThrottle)
+	throttle.LastFailedAt = at
+	if err := allow(throttle); err != nil {
+		return throttle, err
+	}
+	return throttle, args.Error(1)
+}
+
+// newPasswordResetStorage allows any number of requests.
+func newPasswordResetStorage() *passwordResetStorage {
+	store := new(passwordResetStorage)
+	store.On("ReserveLoginAttempt", mock.Anything, mock.Anything, mock.Anything).Return(models.LoginThrottle{}, nil)
+	return store
+}
+
+func (s *passwordResetStorage) CreatePasswordResetToken(
+	ctx context.Context,
+	userID int,
+	tokenHash []byte,
+	expiresAt time.Time,
+) error {
+
+	args := s.Called(ctx, userID, tokenHash)
+	return args.Error(0)
+}
+
+func (s *passwordResetStorage) ResetPassword(ctx context.Context, tokenHash []byte, encryptedPassword []byte) (int, error) {
+	args := s.Called(ctx, tokenHash, encryptedPassword)
+	return args.Int(0), args.Error(1)
+}
+
+func TestPasswordResetForgot(t *testing.T) {
+	store := newPasswordResetStorage()
+	sender := new(notificationSender)
+	resetSrv := services.NewPasswordResetService(zap.NewNop(), store, sender, services.NewBackground(), time.Hour, passwordPolicy)
+	user := models.User{ID: 1, Email: "email@example.com"}
+
+	t.Run("emails token and stores its hash", func(t *testing.T) {
+		sent := make(chan string, 1)
+		store.On("FindUserByEmail", mock.Anything, user.Email).Return(user, nil)
+		store.On("CreatePasswordResetToken", mock.Anything, user.ID, mock.Anything).Return(nil)
+		sender.On("Send", user.Email, "Password reset", mock.Anything).
+			Run(func(args mock.Arguments) { sent <- args.String(2) }).
+			Return(nil)
+
+		require.NoError(t, resetSrv.Forgot(context.TODO(), user.Email, "127.0.0.1"))
+
+		var body string
+		select {
+		case body = <-sent:
+		case <-time.After(time.Second):
+			t.Fatal("password reset email was not sent")
+		}
+
+		storedHash := store.Calls[len(store.Calls)-1].Arguments.Get(2).([]byte)
+		lines := strings.Split(body, "\n")
+		require.Greater(t, len(lines), 2)
+		assert.Equal(t, auth.HashPasswordResetToken(lines[2]), storedHash)
+	})
+
+	t.Run("does not report send failures", func(t *testing.T) {
+		failingUser := models.User{ID: 2, Email: "failing@example.com"}
+		attempted := make(chan struct{})
+		store.On("FindUserByEmail", mock.Anything, failingUser.Email).Return(failingUser, nil)
+		store.On("CreatePasswordResetToken", mock.Anything, failingUser.ID, mock.Anything).Return(nil)
+		sender.On("Send", failingUser.Email, "Password reset", mock.Anything).
+			Run(func(mock.Arguments) { close(attempted) }).
+			Return(errors.New("smtp is down"))
+
+		assert.NoError(t, resetSrv.Forgot(context.TODO(), failingUser.Email, "127.0.0.1"))
+		select {
+		case <-attempted:
+		case <-time.After(time.Second):
+			t.Fatal("password reset email was not sent")
+		}
+	})
+
+	t.Run("ignores unknown email", func(t *testing.T) {
+		store.On("FindUserByEmail", mock.Anything, "unknown@example.com").
+			Return(models.User{}, storage.ErrUserNotFound{})
+
+		assert.NoError(t, resetSrv.Forgot(context.TODO(), "unknown@example.com", "127.0.0.1"))
+		sender.AssertNotCalled(t, "Send", "unknown@example.com", mock.Anything, mock.Anything)
+	})
+}
+
+func TestPasswordResetForgotThrottled(t *testing.T) {
+	user := models.User{ID: 1, Email: "email@example.com"}
+	exhausted := models.LoginThrottle{Failures: 100}
+
+	t.Run("rejects client over the limit", func(t *testing.T) {
+		store := new(passwordResetStorage)
+		store.On("ReserveLoginAttempt", mock.Anything, models.PasswordResetScopeIP, "127.0.0.1").Return(exhausted, nil)
+		resetSrv := services.NewPasswordResetService(
+			zap.NewNop(),
+			store,
+			new(notificationSender),
+			services.NewBackground(),
+			time.Hour,
+			passwordPolicy,
+		)
+
+		err := resetSrv.Forgot(context.TODO(), user.Email, "127.0.0.1")
+		assert.ErrorIs(t, err, services.ErrTooManyPasswordResets)
+		store.AssertNotCalled(t, "FindUserByEmail", mock.Anything, mock.Anything)
+	})
+
+	t.Run("drops requests over the email limit silently", func(t *testing.T) {
+		store := new(passwordResetStorage)
+		store.On("ReserveLoginAttempt", mock.Anything, models.PasswordResetScopeIP, "127.0.0.1").
+			Return(models.LoginThrottle{}, nil)
+		store.On("ReserveLoginAttempt", mock.Anything, models.PasswordResetScopeAccount, user.Email).Return(exhausted, nil)
+		sender := new(notificationSender)
+		resetSrv := services.NewPasswordResetService(zap.NewNop(), store, sender, services.NewBackground(), time.Hour, passwordPolicy)
+
+		require.NoError(t, resetSrv.Forgot(context.TODO(), " Email@Example.com", "127.0.0.1"))
+		store.AssertNotCalled(t, "FindUserByEmail", mock.Anything, mock.Anything)
+		sender.AssertNotCalled(t, "Send", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("waits for emails on shutdown", func(t *testing.T) {
+		store := newPasswordResetStorage()
+		store.On("FindUserByEmail", mock.Anything, user.Email).Return(user, nil)
+		store.On("CreatePasswordResetToken", mock.Anything, user.ID, mock.Anything).Return(nil)
+		unblock := make(chan struct{})
+		sender := new(notificationSender)
+		sender.On("Send", user.Email, "Password reset", mock.Anything).
+			Run(func(mock.Arguments) { <-unblock }).
+			Return(nil)
+		background := services.NewBackground()
+		resetSrv := services.NewPasswordResetService(zap.NewNop(), store, sender, background, time.Hour, passwordPolicy)
+
+		require.NoError(t, resetSrv.Forgot(context.TODO(), user.Email, "127.0.0.1"))
+		ctx, cancel := context.WithTimeout(context.TODO(), 50*time.Millisecond)
+		defer cancel()
+		assert.ErrorIs(t, background.Stop(ctx), context.DeadlineExceeded)
+
+		close(unblock)
+		require.NoError(t, background.Stop(context.TODO()))
+		sender.AssertCalled(t, "Send", user.Email, "Password reset", mock.Anything)
+		assert.ErrorIs(t, resetSrv.Forgot(context.TODO(), user.Email, "127.0.0.1"), services.ErrShuttingDown)
+	})
+}
+
+func TestPasswordResetReset(t *testing.T) {
+	testCases := []struct {
+		name     string
+		password string
+		resetErr error
+		wantErr  error
+		// weakPassword expects ErrWeakPassword
+		weakPassword bool
+	}{
+		{name: "resets password", password: "new-password"},
+		{name: "rejects empty password", wantErr: services.ErrEmptyPassword},
+		{name: "rejects weak password", password: "password1", weakPassword: true},
+		{
+			name:     "rejects used or expired token",
+			password: "new-password",
+			resetErr: storage.ErrPasswordResetTokenNotFound{},
+			wantErr:  services.ErrInvalidPasswordResetToken,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := new(passwordResetStorage)
+			store.On("ResetPassword", mock.Anything, auth.HashPasswordResetToken("token"), mock.Anything).
+				Return(1, tc.resetErr)
+			resetSrv := services.NewPasswordResetService(
+				zap.NewNop(),
+				store,
+				new(notificationSender),
+				services.NewBackground(),
+				time.Hour,
+				passwordPolicy,
+			)
+
+			err := resetSrv.Reset(context.TODO(), "token", tc.password)
+			if tc.weakPassword {
+				assert.ErrorAs(t, err, &services.ErrWeakPassword{})
+				store.AssertNotCalled(t, "ResetPassword", mock.Anything, mock.Anything, mock.Anything)
+				return
+			}
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			encryptedPassword := store.Calls[0].Arguments.Get(2).([]byte)
+			assert.True(t, auth.ValidatePasswordHash(tc.password, string(encryptedPassword)))
+		})
+	}
+}
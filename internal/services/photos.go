@@ -0,0 +1,188 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/ilya-burinskiy/birthday-notify/internal/models"
+)
+
+var (
+	ErrPhotoTooLarge        = errors.New("photo is too large")
+	ErrUnsupportedPhotoType = errors.New("photo must be a JPEG, PNG, GIF or WebP image")
+	ErrPhotoNotFound        = errors.New("photo not found")
+)
+
+// photoExtensions maps accepted content types to file extensions.
+var photoExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// PhotoStore keeps photo files by name.
+type PhotoStore interface {
+	Save(name string, data []byte) error
+	Open(name string) (io.ReadSeekCloser, error)
+	Delete(name string) error
+}
+
+// DirPhotoStore stores photos as files in a directory.
+type DirPhotoStore struct {
+	dir string
+}
+
+func NewDirPhotoStore(dir string) (DirPhotoStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return DirPhotoStore{}, fmt.Errorf("failed to create photo directory: %w", err)
+	}
+
+	return DirPhotoStore{
+		dir: dir,
+	}, nil
+}
+
+// Save writes the photo to a temporary file first, so that a photo is never
+// served half-written.
+func (store DirPhotoStore) Save(name string, data []byte) error {
+	tmp, err := os.CreateTemp(store.dir, ".upload-*")
+	if err != nil {
+		return fmt.Errorf("failed to save photo: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to save photo: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to save photo: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), store.path(name)); err != nil {
+		return fmt.Errorf("failed to save photo: %w", err)
+	}
+	return nil
+}
+
+func (store DirPhotoStore) Open(name string) (io.ReadSeekCloser, error) {
+	file, err := os.Open(store.path(name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrPhotoNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open photo: %w", err)
+	}
+	return file, nil
+}
+
+// Delete removes the photo, a missing file is not an error.
+func (store DirPhotoStore) Delete(name string) error {
+	err := os.Remove(store.path(name))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to delete photo: %w", err)
+	}
+	return nil
+}
+
+func (store DirPhotoStore) path(name string) string {
+	return filepath.Join(store.dir, filepath.Base(name))
+}
+
+type PhotoStorage interface {
+	FindUserByID(ctx context.Context, userID int) (models.User, error)
+	SetUserPhoto(ctx context.Context, userID int, photo *string) (string, error)
+}
+
+// PhotoService manages profile photos. The file name changes with every
+// upload, so a cached photo is never mistaken for the current one.
+type PhotoService struct {
+	storage PhotoStorage
+	photos  PhotoStore
+	maxSize int
+}
+
+func NewPhotoService(storage PhotoStorage, photos PhotoStore, maxSize int) PhotoService {
+	return PhotoService{
+		storage: storage,
+		photos:  photos,
+		maxSize: maxSize,
+	}
+}
+
+// Upload replaces the photo of the user. The type is detected from the
+// content, not from the name or the Content-Type of the upload.
+func (srv PhotoService) Upload(ctx context.Context, userID int, r io.Reader) (models.User, error) {
+	data, err := io.ReadAll(io.LimitReader(r, int64(srv.maxSize)+1))
+	if err != nil {
+		return models.User{}, fmt.Errorf("failed to upload photo: %w", err)
+	}
+	if len(data) > srv.maxSize {
+		return models.User{}, ErrPhotoTooLarge
+	}
+	ext, ok := photoExtensions[http.DetectContentType(data)]
+	if !ok {
+		return models.User{}, ErrUnsupportedPhotoType
+	}
+
+	suffix := make([]byte, 8)
+	if _, err := rand.Read(suffix); err != nil {
+		return models.User{}, fmt.Errorf("failed to upload photo: %w", err)
+	}
+	name := fmt.Sprintf("%d-%s%s", userID, hex.EncodeToString(suffix), ext)
+	if err := srv.photos.Save(name, data); err != nil {
+		return models.User{}, fmt.Errorf("failed to upload photo: %w", err)
+	}
+
+	previous, err := srv.storage.SetUserPhoto(ctx, userID, &name)
+	if err != nil {
+		srv.photos.Delete(name)
+		return models.User{}, fmt.Errorf("failed to upload photo: %w", err)
+	}
+	srv.deletePrevious(previous)
+
+	return srv.storage.FindUserByID(ctx, userID)
+}
+
+func (srv PhotoService) Remove(ctx context.Context, userID int) error {
+	previous, err := srv.storage.SetUserPhoto(ctx, userID, nil)
+	if err != nil {
+		return fmt.Errorf("failed to remove photo: %w", err)
+	}
+	srv.deletePrevious(previous)
+
+	return nil
+}
+
+// Open returns the photo of the user and its file name, which tells the
+// content type.
+func (srv PhotoService) Open(ctx context.Context, userID int) (io.ReadSeekCloser, string, error) {
+	user, err := srv.storage.FindUserByID(ctx, userID)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to open photo: %w", err)
+	}
+	if user.Photo == "" {
+		return nil, "", ErrPhotoNotFound
+	}
+
+	photo, err := srv.photos.Open(user.Photo)
+	if err != nil {
+		return nil, "", err
+	}
+	return photo, user.Photo, nil
+}
+
+// deletePrevious deletes the replaced photo. The database no longer refers
+// to it, so a file left behind after a failure is only wasted space.
+func (srv PhotoService) deletePrevious(name string) {
+	if name != "" {
+		srv.photos.Delete(name)
+	}
+}
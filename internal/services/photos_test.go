@@ -0,0 +1,122 @@
+package services_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/ilya-burinskiy/birthday-notify/internal/models"
+	"github.com/ilya-burinskiy/birthday-notify/internal/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type photoStore struct{ mock.Mock }
+
+func (s *photoStore) Save(name string, data []byte) error {
+	args := s.Called(name, data)
+	return args.Error(0)
+}
+
+func (s *photoStore) Open(name string) (io.ReadSeekCloser, error) {
+	args := s.Called(name)
+	photo, _ := args.Get(0).(io.ReadSeekCloser)
+	return photo, args.Error(1)
+}
+
+func (s *photoStore) Delete(name string) error {
+	args := s.Called(name)
+	return args.Error(0)
+}
+
+type photoStorage struct{ mock.Mock }
+
+func (s *photoStorage) FindUserByID(ctx context.Context, userID int) (models.User, error) {
+	args := s.Called(ctx, userID)
+	return args.Get(0).(models.User), args.Error(1)
+}
+
+func (s *photoStorage) SetUserPhoto(ctx context.Context, userID int, photo *string) (string, error) {
+	args := s.Called(ctx, userID, photo)
+	return args.String(0), args.Error(1)
+}
+
+var pngPhoto = append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 16)...)
+
+func TestPhotoUpload(t *testing.T) {
+	t.Run("replaces the previous photo", func(t *testing.T) {
+		store := new(photoStorage)
+		store.On("SetUserPhoto", mock.Anything, 1, mock.Anything).Return("1-old.png", nil)
+		store.On("FindUserByID", mock.Anything, 1).Return(models.User{ID: 1, PhotoURL: models.PhotoPath(1)}, nil)
+		photos := new(photoStore)
+		photos.On("Save", mock.Anything, pngPhoto).Return(nil)
+		photos.On("Delete", "1-old.png").Return(nil)
+		photoSrv := services.NewPhotoService(store, photos, 1024)
+
+		user, err := photoSrv.Upload(context.TODO(), 1, bytes.NewReader(pngPhoto))
+		require.NoError(t, err)
+		assert.Equal(t, "/api/users/1/photo", user.PhotoURL)
+		name := photos.Calls[0].Arguments.String(0)
+		assert.True(t, strings.HasPrefix(name, "1-") && strings.HasSuffix(name, ".png"), name)
+		photos.AssertCalled(t, "Delete", "1-old.png")
+	})
+
+	t.Run("rejects too large photo", func(t *testing.T) {
+		photos := new(photoStore)
+		photoSrv := services.NewPhotoService(new(photoStorage), photos, len(pngPhoto)-1)
+
+		_, err := photoSrv.Upload(context.TODO(), 1, bytes.NewReader(pngPhoto))
+		assert.ErrorIs(t, err, services.ErrPhotoTooLarge)
+		photos.AssertNotCalled(t, "Save", mock.Anything, mock.Anything)
+	})
+
+	t.Run("rejects unsupported type", func(t *testing.T) {
+		photos := new(photoStore)
+		photoSrv := services.NewPhotoService(new(photoStorage), photos, 1024)
+
+		_, err := photoSrv.Upload(context.TODO(), 1, strings.NewReader("<svg></svg>"))
+		assert.ErrorIs(t, err, services.ErrUnsupportedPhotoType)
+		photos.AssertNotCalled(t, "Save", mock.Anything, mock.Anything)
+	})
+}
+
+func TestPhotoRemove(t *testing.T) {
+	store := new(photoStorage)
+	store.On("SetUserPhoto", mock.Anything, 1, (*string)(nil)).Return("1-old.png", nil)
+	photos := new(photoStore)
+	photos.On("Delete", "1-old.png").Return(nil)
+	photoSrv := services.NewPhotoService(store, photos, 1024)
+
+	require.NoError(t, photoSrv.Remove(context.TODO(), 1))
+	photos.AssertCalled(t, "Delete", "1-old.png")
+}
+
+func TestPhotoOpenWithoutPhoto(t *testing.T) {
+	store := new(photoStorage)
+	store.On("FindUserByID", mock.Anything, 1).Return(models.User{ID: 1}, nil)
+	photoSrv := services.NewPhotoService(store, new(photoStore), 1024)
+
+	_, _, err := photoSrv.Open(context.TODO(), 1)
+	assert.ErrorIs(t, err, services.ErrPhotoNotFound)
+}
+
+func TestDirPhotoStore(t *testing.T) {
+	photos, err := services.NewDirPhotoStore(t.TempDir())
+	require.NoError(t, err)
+
+	require.NoError(t, photos.Save("1-abc.png", pngPhoto))
+	photo, err := photos.Open("1-abc.png")
+	require.NoError(t, err)
+	data, err := io.ReadAll(photo)
+	require.NoError(t, err)
+	require.NoError(t, photo.Close())
+	assert.Equal(t, pngPhoto, data)
+
+	require.NoError(t, photos.Delete("1-abc.png"))
+	require.NoError(t, photos.Delete("1-abc.png"), "deleting a missing photo is not an error")
+	_, err = photos.Open("1-abc.png")
+	assert.ErrorIs(t, err, services.ErrPhotoNotFound)
+}
@@ -0,0 +1,219 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/ilya-burinskiy/birthday-notify/internal/auth"
+	"github.com/ilya-burinskiy/birthday-notify/internal/models"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrInvalidEmail  = errors.New("invalid email")
+	ErrWrongPassword = errors.New("current password is wrong")
+)
+
+// Limits of the profile fields, they match the columns of "users".
+const (
+	maxNameLength = 100
+	maxBioLength  = 1000
+)
+
+type ErrFieldTooLong struct {
+	Field     string
+	MaxLength int
+}
+
+func (err ErrFieldTooLong) Error() string {
+	return fmt.Sprintf("%s must be at most %d characters long", err.Field, err.MaxLength)
+}
+
+// ErrInvalidCharacters is returned for control characters in a profile field.
+// The names end up in email subjects, so only the bio may span lines.
+type ErrInvalidCharacters struct {
+	Field string
+}
+
+func (err ErrInvalidCharacters) Error() string {
+	return fmt.Sprintf("%s must not contain control characters", err.Field)
+}
+
+type ProfileStorage interface {
+	FindUserByID(ctx context.Context, userID int) (models.User, error)
+	UpdateUser(ctx context.Context, userID int, update models.UserUpdate) (models.User, error)
+	SetPendingEmail(ctx context.Context, userID int, email *string) (models.User, error)
+	ChangeUserPassword(ctx context.Context, userID, keepSessionID int, encryptedPassword []byte) error
+}
+
+// EmailChangeSender confirms an email change with the new address and warns
+// the old one.
+type EmailChangeSender interface {
+	SendEmailChange(ctx context.Context, user models.User, newEmail string) error
+}
+
+// ProfileService lets users view and edit their own account.
+type ProfileService struct {
+	logger    *zap.Logger
+	storage   ProfileStorage
+	verifier  EmailChangeSender
+	passwords PasswordChecker
+	limiter   LoginLimiter
+}
+
+func NewProfileService(
+	logger *zap.Logger,
+	storage ProfileStorage,
+	verifier EmailChangeSender,
+	passwords PasswordChecker,
+	limiter LoginLimiter,
+) ProfileService {
+
+	return ProfileService{
+		logger:    logger,
+		storage:   storage,
+		verifier:  verifier,
+		passwords: passwords,
+		limiter:   limiter,
+	}
+}
+
+func (srv ProfileService) Get(ctx context.Context, userID int) (models.User, error) {
+	return srv.storage.FindUserByID(ctx, userID)
+}
+
+// Update changes the profile fields except the email, see ChangeEmail.
+func (srv ProfileService) Update(ctx context.Context, userID int, update models.UserUpdate) (models.User, error) {
+	fields := []struct {
+		name      string
+		value     **string
+		maxLength int
+		multiline bool
+	}{
+		{"first_name", &update.FirstName, maxNameLength, false},
+		{"last_name", &update.LastName, maxNameLength, false},
+		{"display_name", &update.DisplayName, maxNameLength, false},
+		{"bio", &update.Bio, maxBioLength, true},
+	}
+	for _, field := range fields {
+		if *field.value == nil {
+			continue
+		}
+		value := strings.TrimSpace(**field.value)
+		if utf8.RuneCountInString(value) > field.maxLength {
+			return models.User{}, ErrFieldTooLong{Field: field.name, MaxLength: field.maxLength}
+		}
+		if hasControlCharacters(value, field.multiline) {
+			return models.User{}, ErrInvalidCharacters{Field: field.name}
+		}
+		*field.value = &value
+	}
+
+	user, err := srv.storage.UpdateUser(ctx, userID, update)
+	if err != nil {
+		return models.User{}, fmt.Errorf("failed to update profile: %w", err)
+	}
+
+	return user, nil
+}
+
+func hasControlCharacters(value string, multiline bool) bool {
+	return strings.IndexFunc(value, func(r rune) bool {
+		if multiline && (r == '\n' || r == '\t') {
+			return false
+		}
+		return unicode.IsControl(r)
+	}) >= 0
+}
+
+// ChangeEmail requires the current password, like ChangePassword: with the
+// email a stolen session could take the account over through a password
+// reset. The new email is pending until the user opens the link sent to it,
+// the old address is told about the change. Changing back to the current
+// email cancels a pending change.
+func (srv ProfileService) ChangeEmail(ctx context.Context, userID int, ip, currentPassword, email string) (models.User, error) {
+	email = strings.TrimSpace(email)
+	if !strings.Contains(email, "@") {
+		return models.User{}, ErrInvalidEmail
+	}
+
+	user, err := srv.storage.FindUserByID(ctx, userID)
+	if err != nil {
+		return models.User{}, fmt.Errorf("failed to change email: %w", err)
+	}
+	if err := checkCurrentPassword(ctx, srv.limiter, user, ip, currentPassword); err != nil {
+		return models.User{}, err
+	}
+
+	if email == user.Email {
+		user, err = srv.storage.SetPendingEmail(ctx, userID, nil)
+		if err != nil {
+			return models.User{}, fmt.Errorf("failed to change email: %w", err)
+		}
+		return user, nil
+	}
+
+	user, err = srv.storage.SetPendingEmail(ctx, userID, &email)
+	if err != nil {
+		return models.User{}, fmt.Errorf("failed to change email: %w", err)
+	}
+	// the change is saved, the link can be requested again
+	if err := srv.verifier.SendEmailChange(ctx, user, email); err != nil {
+		srv.logger.Info("failed to send email change", zap.Int("user_id", user.ID), zap.Error(err))
+	}
+
+	return user, nil
+}
+
+// checkCurrentPassword confirms a sensitive change with the password of the
+// user. A wrong password counts as a failed login, so that a stolen session
+// can not be used to guess it.
+func checkCurrentPassword(ctx context.Context, limiter LoginLimiter, user models.User, ip, password string) error {
+	return limitAttempt(ctx, limiter, user.Email, ip, ErrWrongPassword, func() error {
+		if !auth.ValidatePasswordHash(password, string(user.EncryptedPassword)) {
+			return ErrWrongPassword
+		}
+		return nil
+	})
+}
+
+// ChangePassword sets a new password if the current one is right and signs
+// the user out of every other session. Users without a password, who signed
+// up with single sign-on, set one with the password reset.
+func (srv ProfileService) ChangePassword(
+	ctx context.Context,
+	userID,
+	sessionID int,
+	ip,
+	currentPassword,
+	newPassword string,
+) error {
+
+	user, err := srv.storage.FindUserByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to change password: %w", err)
+	}
+	if err := checkCurrentPassword(ctx, srv.limiter, user, ip, currentPassword); err != nil {
+		return err
+	}
+	if newPassword == "" {
+		return ErrEmptyPassword
+	}
+	if err := checkPassword(srv.passwords, newPassword); err != nil {
+		return err
+	}
+
+	encryptedPassword, err := auth.HashPassword(newPassword)
+	if err != nil {
+		return fmt.Errorf("failed to change password: %w", err)
+	}
+	if err := srv.storage.ChangeUserPassword(ctx, userID, sessionID, encryptedPassword); err != nil {
+		return fmt.Errorf("failed to change password: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,227 @@
+package services_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ilya-burinskiy/birthday-notify/internal/auth"
+	"github.com/ilya-burinskiy/birthday-notify/internal/models"
+	"github.com/ilya-burinskiy/birthday-notify/internal/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type profileStorage struct{ mock.Mock }
+
+func (s *profileStorage) FindUserByID(ctx context.Context, userID int) (models.User, error) {
+	args := s.Called(ctx, userID)
+	return args.Get(0).(models.User), args.Error(1)
+}
+
+func (s *profileStorage) UpdateUser(ctx context.Context, userID int, update models.UserUpdate) (models.User, error) {
+	args := s.Called(ctx, userID, update)
+	return args.Get(0).(models.User), args.Error(1)
+}
+
+func (s *profileStorage) SetPendingEmail(ctx context.Context, userID int, email *string) (models.User, error) {
+	args := s.Called(ctx, userID, email)
+	return args.Get(0).(models.User), args.Error(1)
+}
+
+func (s *profileStorage) ChangeUserPassword(
+	ctx context.Context,
+	userID, keepSessionID int,
+	encryptedPassword []byte,
+) error {
+
+	args := s.Called(ctx, userID, keepSessionID, encryptedPassword)
+	return args.Error(0)
+}
+
+type emailChangeSender struct{ mock.Mock }
+
+func (s *emailChangeSender) SendEmailChange(ctx context.Context, user models.User, newEmail string) error {
+	args := s.Called(ctx, user, newEmail)
+	return args.Error(0)
+}
+
+func TestProfileUpdate(t *testing.T) {
+	user := models.User{ID: 1, Email: "old@example.com"}
+
+	t.Run("trims names and rejects too long fields", func(t *testing.T) {
+		firstName := "  Alice "
+		longBio := strings.Repeat("я", 1001)
+		store := new(profileStorage)
+		store.On("UpdateUser", mock.Anything, 1, mock.Anything).Return(user, nil)
+		profileSrv := services.NewProfileService(zap.NewNop(), store, new(emailChangeSender), passwordPolicy, newLoginLimiter())
+
+		_, err := profileSrv.Update(context.TODO(), 1, models.UserUpdate{FirstName: &firstName})
+		require.NoError(t, err)
+		update := store.Calls[len(store.Calls)-1].Arguments.Get(2).(models.UserUpdate)
+		assert.Equal(t, "Alice", *update.FirstName)
+
+		_, err = profileSrv.Update(context.TODO(), 1, models.UserUpdate{Bio: &longBio})
+		assert.ErrorAs(t, err, &services.ErrFieldTooLong{})
+		store.AssertNumberOfCalls(t, "UpdateUser", 1)
+	})
+
+	t.Run("rejects control characters", func(t *testing.T) {
+		name := "Alice\r\nBcc: attacker@example.com"
+		bio := "line one\nline two\x00"
+		store := new(profileStorage)
+		profileSrv := services.NewProfileService(zap.NewNop(), store, new(emailChangeSender), passwordPolicy, newLoginLimiter())
+
+		_, err := profileSrv.Update(context.TODO(), 1, models.UserUpdate{DisplayName: &name})
+		assert.ErrorAs(t, err, &services.ErrInvalidCharacters{})
+		_, err = profileSrv.Update(context.TODO(), 1, models.UserUpdate{Bio: &bio})
+		assert.ErrorAs(t, err, &services.ErrInvalidCharacters{})
+		store.AssertNotCalled(t, "UpdateUser", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("allows line breaks in the bio", func(t *testing.T) {
+		bio := "line one\nline two"
+		store := new(profileStorage)
+		store.On("UpdateUser", mock.Anything, 1, mock.Anything).Return(user, nil)
+		profileSrv := services.NewProfileService(zap.NewNop(), store, new(emailChangeSender), passwordPolicy, newLoginLimiter())
+
+		_, err := profileSrv.Update(context.TODO(), 1, models.UserUpdate{Bio: &bio})
+		require.NoError(t, err)
+	})
+}
+
+func TestProfileChangeEmail(t *testing.T) {
+	verifiedAt := time.Now()
+	user := models.User{
+		ID:                1,
+		Email:             "old@example.com",
+		EncryptedPassword: hashPassword(t, "correct horse battery"),
+		EmailVerifiedAt:   &verifiedAt,
+	}
+	newEmail := "new@example.com"
+
+	t.Run("keeps the new email pending and notifies both addresses", func(t *testing.T) {
+		pending := user
+		pending.PendingEmail = &newEmail
+		store := new(profileStorage)
+		store.On("FindUserByID", mock.Anything, 1).Return(user, nil)
+		store.On("SetPendingEmail", mock.Anything, 1, &newEmail).Return(pending, nil)
+		verifier := new(emailChangeSender)
+		verifier.On("SendEmailChange", mock.Anything, pending, newEmail).Return(nil)
+		profileSrv := services.NewProfileService(zap.NewNop(), store, verifier, passwordPolicy, newLoginLimiter())
+
+		updated, err := profileSrv.ChangeEmail(context.TODO(), 1, "127.0.0.1", "correct horse battery", " new@example.com ")
+		require.NoError(t, err)
+		assert.Equal(t, "old@example.com", updated.Email)
+		assert.NotNil(t, updated.EmailVerifiedAt)
+		verifier.AssertExpectations(t)
+	})
+
+	t.Run("cancels the pending change with the current email", func(t *testing.T) {
+		store := new(profileStorage)
+		store.On("FindUserByID", mock.Anything, 1).Return(user, nil)
+		store.On("SetPendingEmail", mock.Anything, 1, (*string)(nil)).Return(user, nil)
+		verifier := new(emailChangeSender)
+		profileSrv := services.NewProfileService(zap.NewNop(), store, verifier, passwordPolicy, newLoginLimiter())
+
+		_, err := profileSrv.ChangeEmail(context.TODO(), 1, "127.0.0.1", "correct horse battery", user.Email)
+		require.NoError(t, err)
+		verifier.AssertNotCalled(t, "SendEmailChange", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	testCases := []struct {
+		name     string
+		user     models.User
+		password string
+		email    string
+		wantErr  error
+	}{
+		{name: "rejects wrong password", user: user, password: "wrong", email: newEmail, wantErr: services.ErrWrongPassword},
+		{name: "rejects user without password", user: models.User{ID: 1}, email: newEmail, wantErr: services.ErrWrongPassword},
+		{name: "rejects invalid email", user: user, password: "correct horse battery", email: "   ", wantErr: services.ErrInvalidEmail},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := new(profileStorage)
+			store.On("FindUserByID", mock.Anything, 1).Return(tc.user, nil)
+			profileSrv := services.NewProfileService(zap.NewNop(), store, new(emailChangeSender), passwordPolicy, newLoginLimiter())
+
+			_, err := profileSrv.ChangeEmail(context.TODO(), 1, "127.0.0.1", tc.password, tc.email)
+			assert.ErrorIs(t, err, tc.wantErr)
+			store.AssertNotCalled(t, "SetPendingEmail", mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestProfileChangePassword(t *testing.T) {
+	user := models.User{ID: 1, Email: "login", EncryptedPassword: hashPassword(t, "correct horse battery")}
+	testCases := []struct {
+		name            string
+		user            models.User
+		currentPassword string
+		newPassword     string
+		wantErr         error
+		weakPassword    bool
+	}{
+		{name: "changes password", user: user, currentPassword: "correct horse battery", newPassword: "battery staple horse"},
+		{name: "wrong current password", user: user, currentPassword: "wrong", newPassword: "battery staple horse", wantErr: services.ErrWrongPassword},
+		{name: "user without password", user: models.User{ID: 1}, currentPassword: "", newPassword: "battery staple horse", wantErr: services.ErrWrongPassword},
+		{name: "weak new password", user: user, currentPassword: "correct horse battery", newPassword: "password", weakPassword: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := new(profileStorage)
+			store.On("FindUserByID", mock.Anything, 1).Return(tc.user, nil)
+			store.On("ChangeUserPassword", mock.Anything, 1, 7, mock.Anything).Return(nil)
+			profileSrv := services.NewProfileService(zap.NewNop(), store, new(emailChangeSender), passwordPolicy, newLoginLimiter())
+
+			err := profileSrv.ChangePassword(context.TODO(), 1, 7, "127.0.0.1", tc.currentPassword, tc.newPassword)
+			switch {
+			case tc.weakPassword:
+				assert.ErrorAs(t, err, &services.ErrWeakPassword{})
+			case tc.wantErr != nil:
+				assert.ErrorIs(t, err, tc.wantErr)
+			default:
+				require.NoError(t, err)
+				newHash := store.Calls[len(store.Calls)-1].Arguments.Get(3).([]byte)
+				assert.True(t, auth.ValidatePasswordHash(tc.newPassword, string(newHash)))
+				return
+			}
+			store.AssertNotCalled(t, "ChangeUserPassword", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestProfileChangeLimitsPasswordAttempts(t *testing.T) {
+	user := models.User{ID: 1, Email: "email@example.com", EncryptedPassword: hashPassword(t, "correct horse battery")}
+
+	t.Run("records wrong password as failure", func(t *testing.T) {
+		store := new(profileStorage)
+		store.On("FindUserByID", mock.Anything, 1).Return(user, nil)
+		limiter := newLoginLimiter()
+		profileSrv := services.NewProfileService(zap.NewNop(), store, new(emailChangeSender), passwordPolicy, limiter)
+
+		err := profileSrv.ChangePassword(context.TODO(), 1, 7, "127.0.0.1", "wrong", "battery staple horse")
+		assert.ErrorIs(t, err, services.ErrWrongPassword)
+		limiter.AssertCalled(t, "Fail", mock.Anything, "email@example.com", "127.0.0.1")
+		limiter.AssertNotCalled(t, "Release", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("rejects throttled attempt", func(t *testing.T) {
+		store := new(profileStorage)
+		store.On("FindUserByID", mock.Anything, 1).Return(user, nil)
+		limiter := new(loginLimiter)
+		limiter.On("Check", mock.Anything, "email@example.com", "127.0.0.1").
+			Return(services.ErrTooManyLoginAttempts{RetryAfter: time.Minute})
+		profileSrv := services.NewProfileService(zap.NewNop(), store, new(emailChangeSender), passwordPolicy, limiter)
+
+		_, err := profileSrv.ChangeEmail(context.TODO(), 1, "127.0.0.1", "correct horse battery", "new@example.com")
+		var throttledErr services.ErrTooManyLoginAttempts
+		assert.ErrorAs(t, err, &throttledErr)
+		store.AssertNotCalled(t, "SetPendingEmail", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
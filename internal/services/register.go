@@ -7,6 +7,7 @@ import (
 
 	"github.com/ilya-burinskiy/birthday-notify/internal/auth"
 	"github.com/ilya-burinskiy/birthday-notify/internal/models"
+	"go.uber.org/zap"
 )
 
 type UserCreator interface {
@@ -18,13 +19,32 @@ type UserCreator interface {
 	) (models.User, error)
 }
 
+type VerificationSender interface {
+	SendVerification(ctx context.Context, user models.User) error
+}
+
 type RegisterService struct {
-	usrCreator UserCreator
+	logger         *zap.Logger
+	usrCreator     UserCreator
+	sessionStarter SessionStarter
+	verifier       VerificationSender
+	passwords      PasswordChecker
 }
 
-func NewRegisterService(usrCreator UserCreator) RegisterService {
+func NewRegisterService(
+	logger *zap.Logger,
+	usrCreator UserCreator,
+	sessionStarter SessionStarter,
+	verifier VerificationSender,
+	passwords PasswordChecker,
+) RegisterService {
+
 	return RegisterService{
-		usrCreator: usrCreator,
+		logger:         logger,
+		usrCreator:     usrCreator,
+		sessionStarter: sessionStarter,
+		verifier:       verifier,
+		passwords:      passwords,
 	}
 }
 
@@ -33,22 +53,32 @@ func (srv RegisterService) Register(
 	login string,
 	password string,
 	birthdayDate time.Time,
-) (string, error) {
+) (auth.TokenPair, error) {
+
+	if err := checkPassword(srv.passwords, password); err != nil {
+		return auth.TokenPair{}, err
+	}
 
 	encryptedPassword, err := auth.HashPassword(password)
 	if err != nil {
-		return "", fmt.Errorf("failed to register user: %w", err)
+		return auth.TokenPair{}, fmt.Errorf("failed to register user: %w", err)
 	}
 
 	user, err := srv.usrCreator.CreateUser(ctx, login, encryptedPassword, birthdayDate)
 	if err != nil {
-		return "", fmt.Errorf("failed to register user: %w", err)
+		return auth.TokenPair{}, fmt.Errorf("failed to register user: %w", err)
+	}
+
+	// the user is already created, so a mail failure must not fail the
+	// registration: the link can be requested again
+	if err := srv.verifier.SendVerification(ctx, user); err != nil {
+		srv.logger.Info("failed to send verification email", zap.Int("user_id", user.ID), zap.Error(err))
 	}
 
-	jwtStr, err := auth.BuildJWTString(user.ID)
+	tokens, err := srv.sessionStarter.Start(ctx, user.ID)
 	if err != nil {
-		return "", fmt.Errorf("failed to register user: %w", err)
+		return auth.TokenPair{}, fmt.Errorf("failed to register user: %w", err)
 	}
 
-	return jwtStr, nil
+	return tokens, nil
 }
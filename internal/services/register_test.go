@@ -14,6 +14,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
 )
 
 type userCreator struct{ mock.Mock }
@@ -29,6 +30,13 @@ func (s *userCreator) CreateUser(
 	return args.Get(0).(models.User), args.Error(1)
 }
 
+type verificationSender struct{ mock.Mock }
+
+func (s *verificationSender) SendVerification(ctx context.Context, user models.User) error {
+	args := s.Called(ctx, user)
+	return args.Error(0)
+}
+
 func TestRegister(t *testing.T) {
 	type expected struct {
 		jwtStr string
@@ -40,7 +48,9 @@ func TestRegister(t *testing.T) {
 	}
 
 	usrCreator := new(userCreator)
-	registerSrv := services.NewRegisterService(usrCreator)
+	verifier := new(verificationSender)
+	verifier.On("SendVerification", mock.Anything, mock.Anything).Return(nil)
+	registerSrv := services.NewRegisterService(zap.NewNop(), usrCreator, sessionStarter{}, verifier, passwordPolicy)
 	testCases := []struct {
 		name         string
 		login        string
@@ -52,7 +62,7 @@ func TestRegister(t *testing.T) {
 		{
 			name:         "returns JWT string",
 			login:        "login",
-			password:     "password",
+			password:     "correct horse battery",
 			birthdayDate: time.Date(1999, 1, 1, 0, 0, 0, 0, time.UTC),
 			createRes: createUserResult{
 				user: models.User{ID: 1},
@@ -64,7 +74,7 @@ func TestRegister(t *testing.T) {
 		{
 			name:         "returns error if failed to create user",
 			login:        "login",
-			password:     "password",
+			password:     "correct horse battery",
 			birthdayDate: time.Date(1999, 1, 1, 0, 0, 0, 0, time.UTC),
 			createRes: createUserResult{
 				err: errors.New("error"),
@@ -82,12 +92,13 @@ func TestRegister(t *testing.T) {
 				Return(tc.createRes.user, tc.createRes.err)
 			defer createCall.Unset()
 
-			jwtStr, err := registerSrv.Register(ctx, tc.login, tc.password, tc.birthdayDate)
+			tokens, err := registerSrv.Register(ctx, tc.login, tc.password, tc.birthdayDate)
 			if err == nil {
+				verifier.AssertCalled(t, "SendVerification", mock.Anything, tc.createRes.user)
 				assert.Equal(
 					t,
 					userIDFromJWT(t, tc.expected.jwtStr),
-					userIDFromJWT(t, jwtStr),
+					userIDFromJWT(t, tokens.AccessToken),
 				)
 			} else {
 				assert.EqualError(t, err, "failed to register user: error")
@@ -96,8 +107,24 @@ func TestRegister(t *testing.T) {
 	}
 }
 
+func TestRegisterRejectsWeakPassword(t *testing.T) {
+	usrCreator := new(userCreator)
+	registerSrv := services.NewRegisterService(zap.NewNop(), usrCreator, sessionStarter{}, new(verificationSender), passwordPolicy)
+
+	_, err := registerSrv.Register(context.TODO(), "login", "qwerty", time.Date(1999, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	var weakPasswordErr services.ErrWeakPassword
+	require.ErrorAs(t, err, &weakPasswordErr)
+	codes := make([]string, 0, len(weakPasswordErr.Violations))
+	for _, violation := range weakPasswordErr.Violations {
+		codes = append(codes, violation.Code)
+	}
+	assert.Equal(t, []string{auth.PasswordTooShort, auth.PasswordBreached}, codes)
+	usrCreator.AssertNotCalled(t, "CreateUser", mock.Anything, mock.Anything, mock.Anything)
+}
+
 func buildJWTString(t *testing.T, userID int) string {
-	jwtStr, error := auth.BuildJWTString(userID)
+	jwtStr, error := jwtManager.BuildJWTString(userID, 1)
 	require.NoError(t, error)
 
 	return jwtStr
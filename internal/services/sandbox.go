@@ -0,0 +1,50 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SandboxSender guards against emailing real people outside production.
+// Recipients whose domain is in the allowlist receive the message; everyone
+// else is redirected to the catch-all address. The subject is always tagged
+// with the original recipient.
+type SandboxSender struct {
+	sender         NotificationSender
+	catchAll       string
+	allowedDomains map[string]struct{}
+}
+
+func NewSandboxSender(sender NotificationSender, catchAll string, allowedDomains []string) SandboxSender {
+	domains := make(map[string]struct{}, len(allowedDomains))
+	for _, domain := range allowedDomains {
+		domains[strings.ToLower(strings.TrimPrefix(domain, "@"))] = struct{}{}
+	}
+
+	return SandboxSender{
+		sender:         sender,
+		catchAll:       catchAll,
+		allowedDomains: domains,
+	}
+}
+
+func (sender SandboxSender) Send(to string, subject string, body string) error {
+	taggedSubject := fmt.Sprintf("[sandbox: %s] %s", to, subject)
+	if sender.allowed(to) {
+		return sender.sender.Send(to, taggedSubject, body)
+	}
+	if sender.catchAll == "" {
+		return fmt.Errorf("recipient %s is not allowed in sandbox mode", to)
+	}
+
+	return sender.sender.Send(sender.catchAll, taggedSubject, body)
+}
+
+func (sender SandboxSender) allowed(to string) bool {
+	at := strings.LastIndex(to, "@")
+	if at < 0 {
+		return false
+	}
+	_, ok := sender.allowedDomains[strings.ToLower(to[at+1:])]
+	return ok
+}
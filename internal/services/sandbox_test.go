@@ -0,0 +1,65 @@
+package services_test
+
+import (
+	"testing"
+
+	"github.com/ilya-burinskiy/birthday-notify/internal/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type notificationSender struct{ mock.Mock }
+
+func (s *notificationSender) Send(to string, subject string, body string) error {
+	args := s.Called(to, subject, body)
+	return args.Error(0)
+}
+
+func TestSandboxSender(t *testing.T) {
+	testCases := []struct {
+		name           string
+		catchAll       string
+		allowedDomains []string
+		to             string
+		expectedTo     string
+		errMsg         string
+	}{
+		{
+			name:       "redirects recipient to catch-all address",
+			catchAll:   "qa@corp.com",
+			to:         "bob@example.com",
+			expectedTo: "qa@corp.com",
+		},
+		{
+			name:           "keeps recipient from allowed domain",
+			catchAll:       "qa@corp.com",
+			allowedDomains: []string{"Corp.com"},
+			to:             "alice@corp.com",
+			expectedTo:     "alice@corp.com",
+		},
+		{
+			name:           "returns error without catch-all address",
+			allowedDomains: []string{"corp.com"},
+			to:             "bob@example.com",
+			errMsg:         "recipient bob@example.com is not allowed in sandbox mode",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			inner := new(notificationSender)
+			subject := "[sandbox: " + tc.to + "] Birthday notification"
+			inner.On("Send", tc.expectedTo, subject, "body").Return(nil)
+			sender := services.NewSandboxSender(inner, tc.catchAll, tc.allowedDomains)
+
+			err := sender.Send(tc.to, "Birthday notification", "body")
+			if tc.errMsg == "" {
+				assert.NoError(t, err)
+				inner.AssertExpectations(t)
+			} else {
+				assert.EqualError(t, err, tc.errMsg)
+				inner.AssertNotCalled(t, "Send", mock.Anything, mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
@@ -1,21 +1,34 @@
 package services_test
 
 import (
+	"context"
 	"testing"
+	"time"
 
-	"github.com/golang-jwt/jwt/v4"
 	"github.com/ilya-burinskiy/birthday-notify/internal/auth"
-	"github.com/ilya-burinskiy/birthday-notify/internal/configs"
 	"github.com/stretchr/testify/require"
 )
 
+var jwtManager = auth.NewJWTManager(
+	[]auth.SigningKey{{ID: "test", Secret: []byte("secret")}},
+	"birthday-notify",
+	"birthday-notify",
+	time.Hour,
+)
+
+var passwordPolicy = auth.PasswordPolicy{MinLength: 8, Breached: auth.DefaultBreachedPasswords()}
+
+// sessionStarter issues access tokens for session 1 without storing anything.
+type sessionStarter struct{}
+
+func (sessionStarter) Start(ctx context.Context, userID int) (auth.TokenPair, error) {
+	accessToken, err := jwtManager.BuildJWTString(userID, 1)
+	return auth.TokenPair{AccessToken: accessToken}, err
+}
+
 func userIDFromJWT(t *testing.T, jwtStr string) int {
-	claims := &auth.Claims{}
-	token, err := jwt.ParseWithClaims(jwtStr, claims, func(tok *jwt.Token) (interface{}, error) {
-		return []byte(configs.SecretKey), nil
-	})
+	claims, err := jwtManager.ParseJWTString(jwtStr)
 	require.NoError(t, err)
-	require.True(t, token.Valid)
 
 	return claims.UserID
 }
@@ -0,0 +1,147 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ilya-burinskiy/birthday-notify/internal/auth"
+	"github.com/ilya-burinskiy/birthday-notify/internal/models"
+	"github.com/ilya-burinskiy/birthday-notify/internal/storage"
+)
+
+var ErrInvalidRefreshToken = errors.New("invalid refresh token")
+
+type SessionStorage interface {
+	CreateSession(ctx context.Context, userID int, refreshTokenHash []byte, expiresAt time.Time) (models.Session, error)
+	FindRefreshToken(ctx context.Context, tokenHash []byte) (models.RefreshToken, error)
+	RotateRefreshToken(ctx context.Context, token models.RefreshToken, newTokenHash []byte, expiresAt time.Time) error
+	RevokeSession(ctx context.Context, sessionID int) error
+	RevokeUserSessions(ctx context.Context, userID int) error
+	FindSession(ctx context.Context, sessionID int) (models.Session, error)
+}
+
+type AccessTokenBuilder interface {
+	BuildJWTString(userID, sessionID int) (string, error)
+	TokenExp() time.Duration
+}
+
+type SessionService struct {
+	storage         SessionStorage
+	tokenBuilder    AccessTokenBuilder
+	refreshTokenExp time.Duration
+}
+
+func NewSessionService(
+	storage SessionStorage,
+	tokenBuilder AccessTokenBuilder,
+	refreshTokenExp time.Duration,
+) SessionService {
+
+	return SessionService{
+		storage:         storage,
+		tokenBuilder:    tokenBuilder,
+		refreshTokenExp: refreshTokenExp,
+	}
+}
+
+// Start opens a new session for the user and issues its first token pair.
+func (srv SessionService) Start(ctx context.Context, userID int) (auth.TokenPair, error) {
+	refreshToken, refreshTokenHash, err := auth.GenerateRefreshToken()
+	if err != nil {
+		return auth.TokenPair{}, fmt.Errorf("failed to start session: %w", err)
+	}
+
+	session, err := srv.storage.CreateSession(ctx, userID, refreshTokenHash, time.Now().Add(srv.refreshTokenExp))
+	if err != nil {
+		return auth.TokenPair{}, fmt.Errorf("failed to start session: %w", err)
+	}
+
+	return srv.tokenPair(userID, session.ID, refreshToken)
+}
+
+// Refresh exchanges a refresh token for a new token pair. Every refresh token
+// can be used once: presenting an already used token means it was stolen,
+// so the whole session is revoked.
+func (srv SessionService) Refresh(ctx context.Context, refreshToken string) (auth.TokenPair, error) {
+	token, err := srv.storage.FindRefreshToken(ctx, auth.HashRefreshToken(refreshToken))
+	if err != nil {
+		var notFoundErr storage.ErrRefreshTokenNotFound
+		if errors.As(err, &notFoundErr) {
+			return auth.TokenPair{}, ErrInvalidRefreshToken
+		}
+		return auth.TokenPair{}, fmt.Errorf("failed to refresh session: %w", err)
+	}
+
+	if token.SessionRevoked || time.Now().After(token.ExpiresAt) {
+		return auth.TokenPair{}, ErrInvalidRefreshToken
+	}
+	if token.UsedAt != nil {
+		return auth.TokenPair{}, srv.revokeReusedSession(ctx, token)
+	}
+
+	newRefreshToken, newRefreshTokenHash, err := auth.GenerateRefreshToken()
+	if err != nil {
+		return auth.TokenPair{}, fmt.Errorf("failed to refresh session: %w", err)
+	}
+	err = srv.storage.RotateRefreshToken(ctx, token, newRefreshTokenHash, time.Now().Add(srv.refreshTokenExp))
+	if err != nil {
+		var usedErr storage.ErrRefreshTokenUsed
+		if errors.As(err, &usedErr) {
+			return auth.TokenPair{}, srv.revokeReusedSession(ctx, token)
+		}
+		return auth.TokenPair{}, fmt.Errorf("failed to refresh session: %w", err)
+	}
+
+	return srv.tokenPair(token.UserID, token.SessionID, newRefreshToken)
+}
+
+func (srv SessionService) Logout(ctx context.Context, sessionID int) error {
+	if err := srv.storage.RevokeSession(ctx, sessionID); err != nil {
+		return fmt.Errorf("failed to logout: %w", err)
+	}
+	return nil
+}
+
+func (srv SessionService) LogoutAll(ctx context.Context, userID int) error {
+	if err := srv.storage.RevokeUserSessions(ctx, userID); err != nil {
+		return fmt.Errorf("failed to logout: %w", err)
+	}
+	return nil
+}
+
+// IsSessionActive reports whether the session exists and was not revoked.
+func (srv SessionService) IsSessionActive(ctx context.Context, sessionID int) (bool, error) {
+	session, err := srv.storage.FindSession(ctx, sessionID)
+	if err != nil {
+		var notFoundErr storage.ErrSessionNotFound
+		if errors.As(err, &notFoundErr) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return session.RevokedAt == nil, nil
+}
+
+func (srv SessionService) revokeReusedSession(ctx context.Context, token models.RefreshToken) error {
+	if err := srv.storage.RevokeSession(ctx, token.SessionID); err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+	return ErrInvalidRefreshToken
+}
+
+func (srv SessionService) tokenPair(userID, sessionID int, refreshToken string) (auth.TokenPair, error) {
+	accessToken, err := srv.tokenBuilder.BuildJWTString(userID, sessionID)
+	if err != nil {
+		return auth.TokenPair{}, fmt.Errorf("failed to build access token: %w", err)
+	}
+
+	return auth.TokenPair{
+		AccessToken:     accessToken,
+		AccessTokenExp:  srv.tokenBuilder.TokenExp(),
+		RefreshToken:    refreshToken,
+		RefreshTokenExp: srv.refreshTokenExp,
+	}, nil
+}
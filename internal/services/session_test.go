@@ -0,0 +1,140 @@
+package services_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ilya-burinskiy/birthday-notify/internal/auth"
+	"github.com/ilya-burinskiy/birthday-notify/internal/models"
+	"github.com/ilya-burinskiy/birthday-notify/internal/services"
+	"github.com/ilya-burinskiy/birthday-notify/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type sessionStorage struct{ mock.Mock }
+
+func (s *sessionStorage) CreateSession(
+	ctx context.Context,
+	userID int,
+	refreshTokenHash []byte,
+	expiresAt time.Time,
+) (models.Session, error) {
+
+	args := s.Called(ctx, userID, refreshTokenHash)
+	return args.Get(0).(models.Session), args.Error(1)
+}
+
+func (s *sessionStorage) FindRefreshToken(ctx context.Context, tokenHash []byte) (models.RefreshToken, error) {
+	args := s.Called(ctx, tokenHash)
+	return args.Get(0).(models.RefreshToken), args.Error(1)
+}
+
+func (s *sessionStorage) RotateRefreshToken(
+	ctx context.Context,
+	token models.RefreshToken,
+	newTokenHash []byte,
+	expiresAt time.Time,
+) error {
+
+	args := s.Called(ctx, token, newTokenHash)
+	return args.Error(0)
+}
+
+func (s *sessionStorage) RevokeSession(ctx context.Context, sessionID int) error {
+	args := s.Called(ctx, sessionID)
+	return args.Error(0)
+}
+
+func (s *sessionStorage) RevokeUserSessions(ctx context.Context, userID int) error {
+	args := s.Called(ctx, userID)
+	return args.Error(0)
+}
+
+func (s *sessionStorage) FindSession(ctx context.Context, sessionID int) (models.Session, error) {
+	args := s.Called(ctx, sessionID)
+	return args.Get(0).(models.Session), args.Error(1)
+}
+
+func TestSessionServiceRefresh(t *testing.T) {
+	usedAt := time.Now().Add(-time.Minute)
+	testCases := []struct {
+		name        string
+		token       models.RefreshToken
+		findErr     error
+		rotateErr   error
+		wantRevoke  bool
+		wantErr     error
+		wantRotated bool
+	}{
+		{
+			name:        "rotates refresh token",
+			token:       models.RefreshToken{ID: 1, SessionID: 10, UserID: 100, ExpiresAt: time.Now().Add(time.Hour)},
+			wantRotated: true,
+		},
+		{
+			name:    "rejects unknown token",
+			findErr: storage.ErrRefreshTokenNotFound{},
+			wantErr: services.ErrInvalidRefreshToken,
+		},
+		{
+			name:    "rejects expired token",
+			token:   models.RefreshToken{ID: 1, SessionID: 10, UserID: 100, ExpiresAt: time.Now().Add(-time.Hour)},
+			wantErr: services.ErrInvalidRefreshToken,
+		},
+		{
+			name: "rejects token of revoked session",
+			token: models.RefreshToken{
+				ID:             1,
+				SessionID:      10,
+				UserID:         100,
+				ExpiresAt:      time.Now().Add(time.Hour),
+				SessionRevoked: true,
+			},
+			wantErr: services.ErrInvalidRefreshToken,
+		},
+		{
+			name:       "revokes session when used token is presented again",
+			token:      models.RefreshToken{ID: 1, SessionID: 10, UserID: 100, ExpiresAt: time.Now().Add(time.Hour), UsedAt: &usedAt},
+			wantRevoke: true,
+			wantErr:    services.ErrInvalidRefreshToken,
+		},
+		{
+			name:       "revokes session when token is used concurrently",
+			token:      models.RefreshToken{ID: 1, SessionID: 10, UserID: 100, ExpiresAt: time.Now().Add(time.Hour)},
+			rotateErr:  storage.ErrRefreshTokenUsed{},
+			wantRevoke: true,
+			wantErr:    services.ErrInvalidRefreshToken,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := new(sessionStorage)
+			store.On("FindRefreshToken", mock.Anything, auth.HashRefreshToken("refresh-token")).
+				Return(tc.token, tc.findErr)
+			store.On("RotateRefreshToken", mock.Anything, tc.token, mock.Anything).Return(tc.rotateErr)
+			store.On("RevokeSession", mock.Anything, tc.token.SessionID).Return(nil)
+			sessionSrv := services.NewSessionService(store, jwtManager, time.Hour)
+
+			tokens, err := sessionSrv.Refresh(context.TODO(), "refresh-token")
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, tc.token.UserID, userIDFromJWT(t, tokens.AccessToken))
+				assert.NotEqual(t, "refresh-token", tokens.RefreshToken)
+			}
+			if tc.wantRevoke {
+				store.AssertCalled(t, "RevokeSession", mock.Anything, tc.token.SessionID)
+			} else {
+				store.AssertNotCalled(t, "RevokeSession", mock.Anything, mock.Anything)
+			}
+			if tc.wantRotated {
+				store.AssertCalled(t, "RotateRefreshToken", mock.Anything, tc.token, mock.Anything)
+			}
+		})
+	}
+}
@@ -0,0 +1,202 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ilya-burinskiy/birthday-notify/internal/auth"
+	"github.com/ilya-burinskiy/birthday-notify/internal/models"
+	"github.com/ilya-burinskiy/birthday-notify/internal/storage"
+)
+
+const recoveryCodesCount = 10
+
+var (
+	ErrInvalidTOTPCode    = errors.New("invalid two-factor code")
+	ErrTOTPAlreadyEnabled = errors.New("two-factor authentication already enabled")
+	ErrTOTPNotEnabled     = errors.New("two-factor authentication not enabled")
+	ErrTOTPNotEnrolled    = errors.New("two-factor authentication enrollment not started")
+)
+
+type TOTPStorage interface {
+	FindUserByID(ctx context.Context, userID int) (models.User, error)
+	FindTOTP(ctx context.Context, userID int) (models.TOTP, error)
+	SavePendingTOTP(ctx context.Context, userID int, secret string) error
+	EnableTOTP(ctx context.Context, userID int, step int64, recoveryCodeHashes [][]byte) error
+	DisableTOTP(ctx context.Context, userID int) error
+	UseTOTPStep(ctx context.Context, userID int, step int64) error
+	UseRecoveryCode(ctx context.Context, userID int, codeHash []byte) error
+	ReplaceRecoveryCodes(ctx context.Context, userID int, codeHashes [][]byte) error
+}
+
+// TwoFactorService manages the second factor of a signed in user. Every code
+// check counts as a login attempt, so that a stolen session can not be used
+// to brute-force the codes.
+type TwoFactorService struct {
+	storage TOTPStorage
+	issuer  string
+	limiter LoginLimiter
+}
+
+func NewTwoFactorService(storage TOTPStorage, issuer string, limiter LoginLimiter) TwoFactorService {
+	return TwoFactorService{
+		storage: storage,
+		issuer:  issuer,
+		limiter: limiter,
+	}
+}
+
+// Enroll generates a new secret. Two-factor authentication is enabled only
+// after Confirm, so a user who never scans the code is not locked out.
+func (srv TwoFactorService) Enroll(ctx context.Context, userID int) (string, string, error) {
+	totp, err := srv.findTOTP(ctx, userID)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to enroll: %w", err)
+	}
+	if totp.EnabledAt != nil {
+		return "", "", ErrTOTPAlreadyEnabled
+	}
+
+	user, err := srv.storage.FindUserByID(ctx, userID)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to enroll: %w", err)
+	}
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		return "", "", fmt.Errorf("failed to enroll: %w", err)
+	}
+	if err := srv.storage.SavePendingTOTP(ctx, userID, secret); err != nil {
+		return "", "", fmt.Errorf("failed to enroll: %w", err)
+	}
+
+	return secret, auth.TOTPProvisioningURI(srv.issuer, user.Email, secret), nil
+}
+
+// Confirm enables two-factor authentication if the code matches the pending
+// secret and returns recovery codes. The codes are shown only once.
+func (srv TwoFactorService) Confirm(ctx context.Context, userID int, ip, code string) ([]string, error) {
+	totp, err := srv.findTOTP(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to confirm two-factor authentication: %w", err)
+	}
+	if totp.Secret == "" {
+		return nil, ErrTOTPNotEnrolled
+	}
+	if totp.EnabledAt != nil {
+		return nil, ErrTOTPAlreadyEnabled
+	}
+
+	var step int64
+	err = srv.limit(ctx, userID, ip, func() error {
+		var ok bool
+		step, ok = auth.ValidateTOTP(totp.Secret, code, time.Now())
+		if !ok {
+			return ErrInvalidTOTPCode
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	codes, hashes, err := auth.GenerateRecoveryCodes(recoveryCodesCount)
+	if err != nil {
+		return nil, fmt.Errorf("failed to confirm two-factor authentication: %w", err)
+	}
+	if err := srv.storage.EnableTOTP(ctx, userID, step, hashes); err != nil {
+		return nil, fmt.Errorf("failed to confirm two-factor authentication: %w", err)
+	}
+
+	return codes, nil
+}
+
+// Disable turns two-factor authentication off. It requires a valid code or
+// recovery code so that a stolen session can not remove the second factor.
+func (srv TwoFactorService) Disable(ctx context.Context, userID int, ip, code string) error {
+	if err := srv.limitedVerify(ctx, userID, ip, code); err != nil {
+		return err
+	}
+	if err := srv.storage.DisableTOTP(ctx, userID); err != nil {
+		return fmt.Errorf("failed to disable two-factor authentication: %w", err)
+	}
+	return nil
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes of the user.
+func (srv TwoFactorService) RegenerateRecoveryCodes(ctx context.Context, userID int, ip, code string) ([]string, error) {
+	if err := srv.limitedVerify(ctx, userID, ip, code); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := auth.GenerateRecoveryCodes(recoveryCodesCount)
+	if err != nil {
+		return nil, fmt.Errorf("failed to regenerate recovery codes: %w", err)
+	}
+	if err := srv.storage.ReplaceRecoveryCodes(ctx, userID, hashes); err != nil {
+		return nil, fmt.Errorf("failed to regenerate recovery codes: %w", err)
+	}
+
+	return codes, nil
+}
+
+func (srv TwoFactorService) IsEnabled(ctx context.Context, userID int) (bool, error) {
+	totp, err := srv.findTOTP(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return totp.EnabledAt != nil, nil
+}
+
+// Verify accepts a current TOTP code or an unused recovery code. Every code
+// can be used once. Verify does not limit attempts, callers do.
+func (srv TwoFactorService) Verify(ctx context.Context, userID int, code string) error {
+	totp, err := srv.findTOTP(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to verify two-factor code: %w", err)
+	}
+	if totp.EnabledAt == nil {
+		return ErrTOTPNotEnabled
+	}
+
+	if step, ok := auth.ValidateTOTP(totp.Secret, code, time.Now()); ok {
+		err := srv.storage.UseTOTPStep(ctx, userID, step)
+		var usedErr storage.ErrTOTPStepUsed
+		if errors.As(err, &usedErr) {
+			return ErrInvalidTOTPCode
+		}
+		return err
+	}
+
+	err = srv.storage.UseRecoveryCode(ctx, userID, auth.HashRecoveryCode(code))
+	var notFoundErr storage.ErrRecoveryCodeNotFound
+	if errors.As(err, &notFoundErr) {
+		return ErrInvalidTOTPCode
+	}
+	return err
+}
+
+func (srv TwoFactorService) limitedVerify(ctx context.Context, userID int, ip, code string) error {
+	return srv.limit(ctx, userID, ip, func() error {
+		return srv.Verify(ctx, userID, code)
+	})
+}
+
+// limit runs check as a login attempt of the user, see limitAttempt.
+func (srv TwoFactorService) limit(ctx context.Context, userID int, ip string, check func() error) error {
+	user, err := srv.storage.FindUserByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to check two-factor code: %w", err)
+	}
+	return limitAttempt(ctx, srv.limiter, user.Email, ip, ErrInvalidTOTPCode, check)
+}
+
+// findTOTP returns an empty TOTP for users who never enrolled.
+func (srv TwoFactorService) findTOTP(ctx context.Context, userID int) (models.TOTP, error) {
+	totp, err := srv.storage.FindTOTP(ctx, userID)
+	var notFoundErr storage.ErrTOTPNotFound
+	if errors.As(err, &notFoundErr) {
+		return models.TOTP{UserID: userID}, nil
+	}
+	return totp, err
+}
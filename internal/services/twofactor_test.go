@@ -0,0 +1,200 @@
+package services_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ilya-burinskiy/birthday-notify/internal/auth"
+	"github.com/ilya-burinskiy/birthday-notify/internal/models"
+	"github.com/ilya-burinskiy/birthday-notify/internal/services"
+	"github.com/ilya-burinskiy/birthday-notify/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type totpStorage struct{ mock.Mock }
+
+func (s *totpStorage) FindUserByID(ctx context.Context, userID int) (models.User, error) {
+	args := s.Called(ctx, userID)
+	return args.Get(0).(models.User), args.Error(1)
+}
+
+func (s *totpStorage) FindTOTP(ctx context.Context, userID int) (models.TOTP, error) {
+	args := s.Called(ctx, userID)
+	return args.Get(0).(models.TOTP), args.Error(1)
+}
+
+func (s *totpStorage) SavePendingTOTP(ctx context.Context, userID int, secret string) error {
+	args := s.Called(ctx, userID, secret)
+	return args.Error(0)
+}
+
+func (s *totpStorage) EnableTOTP(ctx context.Context, userID int, step int64, recoveryCodeHashes [][]byte) error {
+	args := s.Called(ctx, userID, step, recoveryCodeHashes)
+	return args.Error(0)
+}
+
+func (s *totpStorage) DisableTOTP(ctx context.Context, userID int) error {
+	args := s.Called(ctx, userID)
+	return args.Error(0)
+}
+
+func (s *totpStorage) UseTOTPStep(ctx context.Context, userID int, step int64) error {
+	args := s.Called(ctx, userID, step)
+	return args.Error(0)
+}
+
+func (s *totpStorage) UseRecoveryCode(ctx context.Context, userID int, codeHash []byte) error {
+	args := s.Called(ctx, userID, codeHash)
+	return args.Error(0)
+}
+
+func (s *totpStorage) ReplaceRecoveryCodes(ctx context.Context, userID int, codeHashes [][]byte) error {
+	args := s.Called(ctx, userID, codeHashes)
+	return args.Error(0)
+}
+
+func TestTwoFactorEnrollAndConfirm(t *testing.T) {
+	store := new(totpStorage)
+	store.On("FindTOTP", mock.Anything, 1).Return(models.TOTP{}, storage.ErrTOTPNotFound{}).Once()
+	store.On("FindUserByID", mock.Anything, 1).Return(models.User{ID: 1, Email: "email@example.com"}, nil)
+	store.On("SavePendingTOTP", mock.Anything, 1, mock.Anything).Return(nil)
+	limiter := newLoginLimiter()
+	twoFactorSrv := services.NewTwoFactorService(store, "Birthday Notify", limiter)
+
+	secret, uri, err := twoFactorSrv.Enroll(context.TODO(), 1)
+	require.NoError(t, err)
+	assert.Contains(t, uri, "otpauth://totp/Birthday%20Notify:email@example.com?")
+	store.AssertCalled(t, "SavePendingTOTP", mock.Anything, 1, secret)
+
+	store.On("FindTOTP", mock.Anything, 1).Return(models.TOTP{UserID: 1, Secret: secret}, nil)
+	store.On("EnableTOTP", mock.Anything, 1, mock.Anything, mock.Anything).Return(nil)
+
+	_, err = twoFactorSrv.Confirm(context.TODO(), 1, "127.0.0.1", "000000")
+	assert.ErrorIs(t, err, services.ErrInvalidTOTPCode)
+	store.AssertNotCalled(t, "EnableTOTP", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	limiter.AssertCalled(t, "Fail", mock.Anything, "email@example.com", "127.0.0.1")
+
+	code, err := auth.GenerateTOTPCode(secret, time.Now())
+	require.NoError(t, err)
+	codes, err := twoFactorSrv.Confirm(context.TODO(), 1, "127.0.0.1", code)
+	require.NoError(t, err)
+	assert.Len(t, codes, 10)
+	store.AssertCalled(t, "EnableTOTP", mock.Anything, 1, mock.Anything, mock.Anything)
+	limiter.AssertCalled(t, "Release", mock.Anything, "email@example.com", "127.0.0.1")
+}
+
+func TestTwoFactorSettingsLimitAttempts(t *testing.T) {
+	enabledAt := time.Now()
+	secret, err := auth.GenerateTOTPSecret()
+	require.NoError(t, err)
+
+	t.Run("rejects throttled attempt without checking the code", func(t *testing.T) {
+		store := new(totpStorage)
+		store.On("FindUserByID", mock.Anything, 1).Return(models.User{ID: 1, Email: "email@example.com"}, nil)
+		store.On("FindTOTP", mock.Anything, 1).Return(models.TOTP{UserID: 1, Secret: secret, EnabledAt: &enabledAt}, nil)
+		limiter := new(loginLimiter)
+		limiter.On("Check", mock.Anything, "email@example.com", "127.0.0.1").
+			Return(services.ErrTooManyLoginAttempts{RetryAfter: time.Minute})
+		twoFactorSrv := services.NewTwoFactorService(store, "Birthday Notify", limiter)
+
+		err := twoFactorSrv.Disable(context.TODO(), 1, "127.0.0.1", "abcd-efgh")
+		var throttledErr services.ErrTooManyLoginAttempts
+		assert.ErrorAs(t, err, &throttledErr)
+		store.AssertNotCalled(t, "UseRecoveryCode", mock.Anything, mock.Anything, mock.Anything)
+		store.AssertNotCalled(t, "DisableTOTP", mock.Anything, mock.Anything)
+	})
+
+	t.Run("records wrong recovery code as failure", func(t *testing.T) {
+		store := new(totpStorage)
+		store.On("FindUserByID", mock.Anything, 1).Return(models.User{ID: 1, Email: "email@example.com"}, nil)
+		store.On("FindTOTP", mock.Anything, 1).Return(models.TOTP{UserID: 1, Secret: secret, EnabledAt: &enabledAt}, nil)
+		store.On("UseRecoveryCode", mock.Anything, 1, mock.Anything).Return(storage.ErrRecoveryCodeNotFound{})
+		limiter := newLoginLimiter()
+		twoFactorSrv := services.NewTwoFactorService(store, "Birthday Notify", limiter)
+
+		_, err := twoFactorSrv.RegenerateRecoveryCodes(context.TODO(), 1, "127.0.0.1", "abcd-efgh")
+		assert.ErrorIs(t, err, services.ErrInvalidTOTPCode)
+		limiter.AssertCalled(t, "Fail", mock.Anything, "email@example.com", "127.0.0.1")
+		limiter.AssertNotCalled(t, "Release", mock.Anything, mock.Anything, mock.Anything)
+		store.AssertNotCalled(t, "ReplaceRecoveryCodes", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestTwoFactorVerify(t *testing.T) {
+	secret, err := auth.GenerateTOTPSecret()
+	require.NoError(t, err)
+	code, err := auth.GenerateTOTPCode(secret, time.Now())
+	require.NoError(t, err)
+	enabledAt := time.Now()
+	enabled := models.TOTP{UserID: 1, Secret: secret, EnabledAt: &enabledAt}
+
+	testCases := []struct {
+		name    string
+		totp    models.TOTP
+		findErr error
+		code    string
+		setup   func(store *totpStorage)
+		wantErr error
+	}{
+		{
+			name: "accepts current code",
+			totp: enabled,
+			code: code,
+			setup: func(store *totpStorage) {
+				store.On("UseTOTPStep", mock.Anything, 1, mock.Anything).Return(nil)
+			},
+		},
+		{
+			name: "rejects reused code",
+			totp: enabled,
+			code: code,
+			setup: func(store *totpStorage) {
+				store.On("UseTOTPStep", mock.Anything, 1, mock.Anything).Return(storage.ErrTOTPStepUsed{})
+			},
+			wantErr: services.ErrInvalidTOTPCode,
+		},
+		{
+			name: "accepts recovery code",
+			totp: enabled,
+			code: "ABCD-EFGH",
+			setup: func(store *totpStorage) {
+				store.On("UseRecoveryCode", mock.Anything, 1, auth.HashRecoveryCode("abcdefgh")).Return(nil)
+			},
+		},
+		{
+			name: "rejects unknown recovery code",
+			totp: enabled,
+			code: "abcd-efgh",
+			setup: func(store *totpStorage) {
+				store.On("UseRecoveryCode", mock.Anything, 1, mock.Anything).Return(storage.ErrRecoveryCodeNotFound{})
+			},
+			wantErr: services.ErrInvalidTOTPCode,
+		},
+		{
+			name:    "fails if not enabled",
+			findErr: storage.ErrTOTPNotFound{},
+			code:    code,
+			setup:   func(store *totpStorage) {},
+			wantErr: services.ErrTOTPNotEnabled,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := new(totpStorage)
+			store.On("FindTOTP", mock.Anything, 1).Return(tc.totp, tc.findErr)
+			tc.setup(store)
+			twoFactorSrv := services.NewTwoFactorService(store, "Birthday Notify", newLoginLimiter())
+
+			err := twoFactorSrv.Verify(context.TODO(), 1, tc.code)
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
@@ -2,30 +2,47 @@ package services
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/ilya-burinskiy/birthday-notify/internal/models"
 )
 
 type NotificationSettingUpdater interface {
+	FindNotificationSetting(ctx context.Context, settingID int) (models.NotifySetting, error)
 	UpdateNotificationSetting(ctx context.Context, settingID, daysBeforeNotify int) (models.NotifySetting, error)
 }
 
 type UpdateNotificationSettingService struct {
-	updater NotificationSettingUpdater
+	updater    NotificationSettingUpdater
+	authorizer Authorizer
 }
 
-func NewUpdateNotificationService(updater NotificationSettingUpdater) UpdateNotificationSettingService {
+func NewUpdateNotificationService(updater NotificationSettingUpdater, authorizer Authorizer) UpdateNotificationSettingService {
 	return UpdateNotificationSettingService{
-		updater: updater,
+		updater:    updater,
+		authorizer: authorizer,
 	}
 }
 
+// UpdateNotificationSetting updates the setting on behalf of the user with
+// userID, who must own it or be an administrator.
 func (srv UpdateNotificationSettingService) UpdateNotificationSetting(
 	ctx context.Context,
+	userID,
 	settingID,
 	daysBeforeNotify int,
 ) (models.NotifySetting, error) {
 
-	// TODO: add authorization
+	if err := validateDaysBefore(daysBeforeNotify); err != nil {
+		return models.NotifySetting{}, err
+	}
+	notifySetting, err := srv.updater.FindNotificationSetting(ctx, settingID)
+	if err != nil {
+		return models.NotifySetting{}, fmt.Errorf("failed to update notification setting: %w", err)
+	}
+	if err := srv.authorizer.AuthorizeOwner(ctx, userID, notifySetting.UserID); err != nil {
+		return models.NotifySetting{}, err
+	}
+
 	return srv.updater.UpdateNotificationSetting(ctx, settingID, daysBeforeNotify)
 }
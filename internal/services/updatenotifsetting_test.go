@@ -0,0 +1,84 @@
+package services_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ilya-burinskiy/birthday-notify/internal/models"
+	"github.com/ilya-burinskiy/birthday-notify/internal/services"
+	"github.com/ilya-burinskiy/birthday-notify/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type notificationSettingUpdater struct{ mock.Mock }
+
+func (u *notificationSettingUpdater) FindNotificationSetting(ctx context.Context, settingID int) (models.NotifySetting, error) {
+	args := u.Called(ctx, settingID)
+	return args.Get(0).(models.NotifySetting), args.Error(1)
+}
+
+func (u *notificationSettingUpdater) UpdateNotificationSetting(
+	ctx context.Context,
+	settingID, daysBeforeNotify int,
+) (models.NotifySetting, error) {
+
+	args := u.Called(ctx, settingID, daysBeforeNotify)
+	return args.Get(0).(models.NotifySetting), args.Error(1)
+}
+
+func TestUpdateNotificationSetting(t *testing.T) {
+	testCases := []struct {
+		name       string
+		userID     int
+		settingID  int
+		wantErr    error
+		wantUpdate bool
+	}{
+		{name: "owner updates setting", userID: 1, settingID: 10, wantUpdate: true},
+		{name: "admin updates setting of another user", userID: 3, settingID: 10, wantUpdate: true},
+		{name: "user can not update setting of another user", userID: 2, settingID: 10, wantErr: services.ErrForbidden},
+		{name: "missing setting", userID: 2, settingID: 11, wantErr: storage.ErrNotifySettingNotFound{}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			updater := new(notificationSettingUpdater)
+			updater.On("FindNotificationSetting", mock.Anything, 10).
+				Return(models.NotifySetting{ID: 10, UserID: 1, DaysBeforeNotify: 1}, nil)
+			updater.On("FindNotificationSetting", mock.Anything, 11).
+				Return(models.NotifySetting{}, storage.ErrNotifySettingNotFound{NotifySetting: models.NotifySetting{ID: 11}})
+			updater.On("UpdateNotificationSetting", mock.Anything, 10, 2).
+				Return(models.NotifySetting{ID: 10, UserID: 1, DaysBeforeNotify: 2}, nil)
+			updateSrv := services.NewUpdateNotificationService(updater, services.NewAuthorizer(newRoleFinder()))
+
+			notifySetting, err := updateSrv.UpdateNotificationSetting(context.TODO(), tc.userID, tc.settingID, 2)
+			switch tc.wantErr.(type) {
+			case nil:
+				require.NoError(t, err)
+				assert.Equal(t, 2, notifySetting.DaysBeforeNotify)
+			case storage.ErrNotifySettingNotFound:
+				assert.ErrorAs(t, err, &storage.ErrNotifySettingNotFound{})
+			default:
+				assert.ErrorIs(t, err, tc.wantErr)
+			}
+			if tc.wantUpdate {
+				updater.AssertCalled(t, "UpdateNotificationSetting", mock.Anything, 10, 2)
+			} else {
+				updater.AssertNotCalled(t, "UpdateNotificationSetting", mock.Anything, mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
+func TestUpdateNotificationSettingRejectsNegativeDays(t *testing.T) {
+	updater := new(notificationSettingUpdater)
+	updater.On("FindNotificationSetting", mock.Anything, 10).
+		Return(models.NotifySetting{ID: 10, UserID: 1, DaysBeforeNotify: 1}, nil)
+	updateSrv := services.NewUpdateNotificationService(updater, services.NewAuthorizer(newRoleFinder()))
+
+	_, err := updateSrv.UpdateNotificationSetting(context.TODO(), 1, 10, -1)
+	assert.ErrorIs(t, err, services.ErrInvalidDaysBefore)
+	updater.AssertNotCalled(t, "UpdateNotificationSetting", mock.Anything, mock.Anything, mock.Anything)
+}
@@ -0,0 +1,117 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ilya-burinskiy/birthday-notify/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// deletedAccountKey replaces the email of a deleted user in the lockout
+// audit trail, the client address is erased along with it.
+const deletedAccountKey = "deleted"
+
+// DeleteUser deletes the user in one transaction. Subscriptions in both
+// directions, the notification setting, sessions, API keys, identities and
+// the notifications the user received are deleted by ON DELETE CASCADE;
+// notifications about the user's birthday lose the reference to the user.
+// Login throttling rows are keyed by email, so they are removed or
+// anonymized (the key and the client address) here.
+func (db *DBStorage) DeleteUser(ctx context.Context, userID int) error {
+	err := pgx.BeginFunc(ctx, db.pool, func(tx pgx.Tx) error {
+		var email string
+		row := tx.QueryRow(ctx, `SELECT "email" FROM "users" WHERE "id" = $1 FOR UPDATE`, userID)
+		if err := row.Scan(&email); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ErrUserNotFound{User: models.User{ID: userID}}
+			}
+			return err
+		}
+
+		_, err := tx.Exec(
+			ctx,
+			`DELETE FROM "login_throttles" WHERE "scope" = ANY($1) AND "key" = lower($2)`,
+			[]string{models.LoginScopeAccount, models.PasswordResetScopeAccount},
+			email,
+		)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(
+			ctx,
+			`UPDATE "login_lockouts" SET "key" = $3, "ip" = '' WHERE "scope" = $1 AND "key" = lower($2)`,
+			models.LoginScopeAccount,
+			email,
+			deletedAccountKey,
+		)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(ctx, `DELETE FROM "users" WHERE "id" = $1`, userID)
+		return err
+	})
+	if err != nil {
+		var notFoundErr ErrUserNotFound
+		if errors.As(err, &notFoundErr) {
+			return notFoundErr
+		}
+		return fmt.Errorf("failed to delete user with id=%d: %w", userID, err)
+	}
+
+	return nil
+}
+
+// RecordNotification stores a sent notification.
+func (db *DBStorage) RecordNotification(ctx context.Context, notification models.Notification, date time.Time) error {
+	_, err := db.pool.Exec(
+		ctx,
+		`INSERT INTO "notification_history" ("recipient_user_id", "subscribed_user_id", "days_before_notify", "notify_date")
+		 VALUES ($1, $2, $3, $4)`,
+		notification.SubscribingUserID,
+		notification.SubscribedUserID,
+		notification.DaysBeforeNotify,
+		date,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record notification: %w", err)
+	}
+	return nil
+}
+
+// ListNotificationHistory returns notifications the user received and
+// notifications about the user's birthday, newest first.
+func (db *DBStorage) ListNotificationHistory(ctx context.Context, userID int) ([]models.NotificationRecord, error) {
+	rows, err := db.pool.Query(
+		ctx,
+		`SELECT "id", "recipient_user_id", "subscribed_user_id", "days_before_notify", "notify_date", "sent_at"
+		 FROM "notification_history"
+		 WHERE "recipient_user_id" = $1 OR "subscribed_user_id" = $1
+		 ORDER BY "sent_at" DESC, "id" DESC`,
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list notification history: %w", err)
+	}
+
+	result, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.NotificationRecord, error) {
+		var record models.NotificationRecord
+		err := row.Scan(
+			&record.ID,
+			&record.RecipientUserID,
+			&record.SubscribedUserID,
+			&record.DaysBeforeNotify,
+			&record.NotifyDate,
+			&record.SentAt,
+		)
+		return record, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list notification history: %w", err)
+	}
+
+	return result, nil
+}
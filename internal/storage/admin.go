@@ -0,0 +1,154 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ilya-burinskiy/birthday-notify/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// ListUsers returns users with account details for administrators.
+func (db *DBStorage) ListUsers(ctx context.Context) ([]models.User, error) {
+	rows, err := db.pool.Query(ctx, `SELECT `+userColumns+` FROM "users" ORDER BY "id"`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+
+	result, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.User, error) {
+		return scanUser(row)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+
+	return result, nil
+}
+
+func (db *DBStorage) SetUserRole(ctx context.Context, userID int, role string) error {
+	tag, err := db.pool.Exec(ctx, `UPDATE "users" SET "role" = $1 WHERE "id" = $2`, role, userID)
+	if err != nil {
+		return fmt.Errorf("failed to set role of user with id=%d: %w", userID, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrUserNotFound{User: models.User{ID: userID}}
+	}
+
+	return nil
+}
+
+// DisableUser disables the account and revokes its sessions and API keys,
+// so the user is signed out everywhere at once.
+func (db *DBStorage) DisableUser(ctx context.Context, userID int) error {
+	err := pgx.BeginFunc(ctx, db.pool, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(
+			ctx,
+			`UPDATE "users" SET "disabled_at" = COALESCE("disabled_at", now()) WHERE "id" = $1`,
+			userID,
+		)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			return ErrUserNotFound{User: models.User{ID: userID}}
+		}
+
+		_, err = tx.Exec(
+			ctx,
+			`UPDATE "sessions" SET "revoked_at" = now() WHERE "user_id" = $1 AND "revoked_at" IS NULL`,
+			userID,
+		)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(
+			ctx,
+			`UPDATE "api_keys" SET "revoked_at" = now() WHERE "user_id" = $1 AND "revoked_at" IS NULL`,
+			userID,
+		)
+		return err
+	})
+	if err != nil {
+		var notFoundErr ErrUserNotFound
+		if errors.As(err, &notFoundErr) {
+			return notFoundErr
+		}
+		return fmt.Errorf("failed to disable user with id=%d: %w", userID, err)
+	}
+
+	return nil
+}
+
+func (db *DBStorage) EnableUser(ctx context.Context, userID int) error {
+	tag, err := db.pool.Exec(ctx, `UPDATE "users" SET "disabled_at" = NULL WHERE "id" = $1`, userID)
+	if err != nil {
+		return fmt.Errorf("failed to enable user with id=%d: %w", userID, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrUserNotFound{User: models.User{ID: userID}}
+	}
+
+	return nil
+}
+
+// ListUserSubscriptions returns subscriptions of the user and to the user.
+func (db *DBStorage) ListUserSubscriptions(ctx context.Context, userID int) ([]models.Subscription, error) {
+	rows, err := db.pool.Query(
+		ctx,
+		`SELECT "id", "subscribed_user_id", "subscribing_user_id" FROM "subscriptions"
+		 WHERE "subscribed_user_id" = $1 OR "subscribing_user_id" = $1
+		 ORDER BY "id"`,
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list subscriptions: %w", err)
+	}
+
+	result, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.Subscription, error) {
+		var subscription models.Subscription
+		err := row.Scan(&subscription.ID, &subscription.SubscribedUserID, &subscription.SubscribingUserID)
+		return subscription, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list subscriptions: %w", err)
+	}
+
+	return result, nil
+}
+
+func (db *DBStorage) FindUserNotificationSetting(ctx context.Context, userID int) (models.NotifySetting, error) {
+	row := db.pool.QueryRow(
+		ctx,
+		`SELECT "id", "days_before_notify" FROM "notify_settings" WHERE "user_id" = $1`,
+		userID,
+	)
+	notifySetting := models.NotifySetting{UserID: userID}
+	err := row.Scan(&notifySetting.ID, &notifySetting.DaysBeforeNotify)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return notifySetting, ErrNotifySettingNotFound{NotifySetting: notifySetting}
+		}
+		return notifySetting, fmt.Errorf("failed to find notification setting: %w", err)
+	}
+
+	return notifySetting, nil
+}
+
+// SaveUserNotificationSetting creates or updates the setting of the user.
+func (db *DBStorage) SaveUserNotificationSetting(ctx context.Context, userID, daysBeforeNotify int) (models.NotifySetting, error) {
+	row := db.pool.QueryRow(
+		ctx,
+		`INSERT INTO "notify_settings" ("user_id", "days_before_notify") VALUES ($1, $2)
+		 ON CONFLICT ("user_id") DO UPDATE SET "days_before_notify" = EXCLUDED."days_before_notify"
+		 RETURNING "id"`,
+		userID,
+		daysBeforeNotify,
+	)
+	notifySetting := models.NotifySetting{UserID: userID, DaysBeforeNotify: daysBeforeNotify}
+	if err := row.Scan(&notifySetting.ID); err != nil {
+		return notifySetting, fmt.Errorf("failed to save notification setting: %w", err)
+	}
+
+	return notifySetting, nil
+}
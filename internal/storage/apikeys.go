@@ -0,0 +1,137 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ilya-burinskiy/birthday-notify/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+const apiKeyColumns = `"id", "user_id", "name", "scopes", "created_at", "expires_at", "last_used_at", "revoked_at"`
+
+func (db *DBStorage) CreateAPIKey(
+	ctx context.Context,
+	userID int,
+	name string,
+	scopes []string,
+	keyHash []byte,
+	expiresAt *time.Time,
+) (models.APIKey, error) {
+
+	row := db.pool.QueryRow(
+		ctx,
+		`INSERT INTO "api_keys" ("user_id", "name", "scopes", "token_hash", "expires_at")
+		 VALUES ($1, $2, $3, $4, $5)
+		 RETURNING `+apiKeyColumns,
+		userID,
+		name,
+		scopes,
+		keyHash,
+		expiresAt,
+	)
+	apiKey, err := scanAPIKey(row)
+	if err != nil {
+		return apiKey, fmt.Errorf("failed to create api key: %w", err)
+	}
+
+	return apiKey, nil
+}
+
+func (db *DBStorage) FindAPIKeyByHash(ctx context.Context, keyHash []byte) (models.APIKey, error) {
+	row := db.pool.QueryRow(
+		ctx,
+		`SELECT `+apiKeyColumns+` FROM "api_keys" WHERE "token_hash" = $1`,
+		keyHash,
+	)
+	apiKey, err := scanAPIKey(row)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return apiKey, ErrAPIKeyNotFound{}
+		}
+		return apiKey, fmt.Errorf("failed to find api key: %w", err)
+	}
+
+	return apiKey, nil
+}
+
+// ListUserAPIKeys returns keys of the user that were not revoked.
+func (db *DBStorage) ListUserAPIKeys(ctx context.Context, userID int) ([]models.APIKey, error) {
+	rows, err := db.pool.Query(
+		ctx,
+		`SELECT `+apiKeyColumns+`
+		 FROM "api_keys"
+		 WHERE "user_id" = $1 AND "revoked_at" IS NULL
+		 ORDER BY "id"`,
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list api keys: %w", err)
+	}
+	defer rows.Close()
+
+	apiKeys := make([]models.APIKey, 0)
+	for rows.Next() {
+		apiKey, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list api keys: %w", err)
+		}
+		apiKeys = append(apiKeys, apiKey)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list api keys: %w", err)
+	}
+
+	return apiKeys, nil
+}
+
+// RevokeAPIKey revokes the key only if it belongs to the user.
+func (db *DBStorage) RevokeAPIKey(ctx context.Context, userID, apiKeyID int) error {
+	tag, err := db.pool.Exec(
+		ctx,
+		`UPDATE "api_keys" SET "revoked_at" = now()
+		 WHERE "id" = $1 AND "user_id" = $2 AND "revoked_at" IS NULL`,
+		apiKeyID,
+		userID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to revoke api key with id=%d: %w", apiKeyID, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrAPIKeyNotFound{APIKey: models.APIKey{ID: apiKeyID, UserID: userID}}
+	}
+
+	return nil
+}
+
+// TouchAPIKey records that the key was used. To avoid a write on every
+// request last_used_at is updated at most once a minute.
+func (db *DBStorage) TouchAPIKey(ctx context.Context, apiKeyID int) error {
+	_, err := db.pool.Exec(
+		ctx,
+		`UPDATE "api_keys" SET "last_used_at" = now()
+		 WHERE "id" = $1 AND ("last_used_at" IS NULL OR "last_used_at" < now() - interval '1 minute')`,
+		apiKeyID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update api key with id=%d: %w", apiKeyID, err)
+	}
+	return nil
+}
+
+func scanAPIKey(row pgx.Row) (models.APIKey, error) {
+	var apiKey models.APIKey
+	err := row.Scan(
+		&apiKey.ID,
+		&apiKey.UserID,
+		&apiKey.Name,
+		&apiKey.Scopes,
+		&apiKey.CreatedAt,
+		&apiKey.ExpiresAt,
+		&apiKey.LastUsedAt,
+		&apiKey.RevokedAt,
+	)
+	return apiKey, err
+}
@@ -0,0 +1,17 @@
+CREATE TABLE "sessions" (
+    "id" bigserial PRIMARY KEY,
+    "user_id" bigint references "users"("id") ON DELETE CASCADE NOT NULL,
+    "created_at" timestamptz NOT NULL DEFAULT now(),
+    "revoked_at" timestamptz
+);
+
+CREATE INDEX "sessions_user_id_idx" ON "sessions"("user_id");
+
+CREATE TABLE "refresh_tokens" (
+    "id" bigserial PRIMARY KEY,
+    "session_id" bigint references "sessions"("id") ON DELETE CASCADE NOT NULL,
+    "token_hash" bytea UNIQUE NOT NULL,
+    "created_at" timestamptz NOT NULL DEFAULT now(),
+    "expires_at" timestamptz NOT NULL,
+    "used_at" timestamptz
+);
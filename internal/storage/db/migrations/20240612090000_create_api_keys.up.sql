@@ -0,0 +1,13 @@
+CREATE TABLE "api_keys" (
+    "id" bigserial PRIMARY KEY,
+    "user_id" bigint references "users"("id") ON DELETE CASCADE NOT NULL,
+    "name" varchar(255) NOT NULL,
+    "token_hash" bytea UNIQUE NOT NULL,
+    "scopes" text[] NOT NULL,
+    "created_at" timestamptz NOT NULL DEFAULT now(),
+    "expires_at" timestamptz,
+    "last_used_at" timestamptz,
+    "revoked_at" timestamptz
+);
+
+CREATE INDEX "api_keys_user_id_idx" ON "api_keys"("user_id");
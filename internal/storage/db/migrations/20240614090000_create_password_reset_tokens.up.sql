@@ -0,0 +1,10 @@
+CREATE TABLE "password_reset_tokens" (
+    "id" bigserial PRIMARY KEY,
+    "user_id" bigint references "users"("id") ON DELETE CASCADE NOT NULL,
+    "token_hash" bytea UNIQUE NOT NULL,
+    "created_at" timestamptz NOT NULL DEFAULT now(),
+    "expires_at" timestamptz NOT NULL,
+    "used_at" timestamptz
+);
+
+CREATE INDEX "password_reset_tokens_user_id_idx" ON "password_reset_tokens"("user_id");
@@ -0,0 +1,4 @@
+-- fails while users without a birthdate exist, they have to be given one or
+-- deleted by hand first
+ALTER TABLE "users" ALTER COLUMN "birthdate" SET NOT NULL;
+DROP TABLE "user_identities";
@@ -0,0 +1,13 @@
+-- users signing in with single sign-on may not have a birthdate yet
+ALTER TABLE "users" ALTER COLUMN "birthdate" DROP NOT NULL;
+
+CREATE TABLE "user_identities" (
+    "id" bigserial PRIMARY KEY,
+    "user_id" bigint references "users"("id") ON DELETE CASCADE NOT NULL,
+    "issuer" varchar(512) NOT NULL,
+    "subject" varchar(255) NOT NULL,
+    "created_at" timestamptz NOT NULL DEFAULT now(),
+    UNIQUE ("issuer", "subject")
+);
+
+CREATE INDEX "user_identities_user_id_idx" ON "user_identities"("user_id");
@@ -0,0 +1,16 @@
+CREATE TABLE "user_totp" (
+    "user_id" bigint PRIMARY KEY references "users"("id") ON DELETE CASCADE,
+    "secret" varchar(64) NOT NULL,
+    "created_at" timestamptz NOT NULL DEFAULT now(),
+    "enabled_at" timestamptz,
+    -- the last accepted time step, codes can not be reused
+    "last_step" bigint
+);
+
+CREATE TABLE "totp_recovery_codes" (
+    "id" bigserial PRIMARY KEY,
+    "user_id" bigint references "users"("id") ON DELETE CASCADE NOT NULL,
+    "code_hash" bytea NOT NULL,
+    "used_at" timestamptz,
+    UNIQUE ("user_id", "code_hash")
+);
@@ -0,0 +1,22 @@
+-- failed login counters per account (lower-cased email) and per client address
+CREATE TABLE "login_throttles" (
+    "scope" varchar(16) NOT NULL,
+    "key" varchar(320) NOT NULL,
+    "failures" integer NOT NULL,
+    "last_failed_at" timestamptz NOT NULL,
+    "locked_until" timestamptz,
+    PRIMARY KEY ("scope", "key")
+);
+
+-- audit trail of lockouts, rows are never deleted
+CREATE TABLE "login_lockouts" (
+    "id" bigserial PRIMARY KEY,
+    "scope" varchar(16) NOT NULL,
+    "key" varchar(320) NOT NULL,
+    "ip" varchar(64) NOT NULL,
+    "failures" integer NOT NULL,
+    "locked_at" timestamptz NOT NULL,
+    "locked_until" timestamptz NOT NULL,
+    "unlocked_at" timestamptz
+);
+CREATE INDEX "login_lockouts_locked_at_idx" ON "login_lockouts" ("locked_at");
@@ -0,0 +1,3 @@
+ALTER TABLE "users"
+    ADD COLUMN "role" varchar(16) NOT NULL DEFAULT 'user' CHECK ("role" IN ('user', 'admin')),
+    ADD COLUMN "disabled_at" timestamptz;
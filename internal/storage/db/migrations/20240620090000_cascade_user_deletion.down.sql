@@ -0,0 +1,14 @@
+DROP TABLE IF EXISTS "notification_history";
+
+ALTER TABLE "notify_settings"
+    DROP CONSTRAINT "notify_settings_user_id_fkey",
+    ADD CONSTRAINT "notify_settings_user_id_fkey"
+        FOREIGN KEY ("user_id") REFERENCES "users"("id");
+
+ALTER TABLE "subscriptions"
+    DROP CONSTRAINT "subscriptions_subscribed_user_id_fkey",
+    DROP CONSTRAINT "subscriptions_subscribing_user_id_fkey",
+    ADD CONSTRAINT "subscriptions_subscribed_user_id_fkey"
+        FOREIGN KEY ("subscribed_user_id") REFERENCES "users"("id"),
+    ADD CONSTRAINT "subscriptions_subscribing_user_id_fkey"
+        FOREIGN KEY ("subscribing_user_id") REFERENCES "users"("id");
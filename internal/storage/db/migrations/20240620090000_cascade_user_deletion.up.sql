@@ -0,0 +1,27 @@
+-- deleting a user deletes the subscriptions in both directions and the setting
+ALTER TABLE "subscriptions"
+    DROP CONSTRAINT "subscriptions_subscribed_user_id_fkey",
+    DROP CONSTRAINT "subscriptions_subscribing_user_id_fkey",
+    ADD CONSTRAINT "subscriptions_subscribed_user_id_fkey"
+        FOREIGN KEY ("subscribed_user_id") REFERENCES "users"("id") ON DELETE CASCADE,
+    ADD CONSTRAINT "subscriptions_subscribing_user_id_fkey"
+        FOREIGN KEY ("subscribing_user_id") REFERENCES "users"("id") ON DELETE CASCADE;
+
+ALTER TABLE "notify_settings"
+    DROP CONSTRAINT "notify_settings_user_id_fkey",
+    ADD CONSTRAINT "notify_settings_user_id_fkey"
+        FOREIGN KEY ("user_id") REFERENCES "users"("id") ON DELETE CASCADE;
+
+-- sent notifications; when the user whose birthday it was is deleted, the
+-- recipient keeps the row without the reference to that user
+CREATE TABLE "notification_history" (
+    "id" bigserial PRIMARY KEY,
+    "recipient_user_id" bigint references "users"("id") ON DELETE CASCADE NOT NULL,
+    "subscribed_user_id" bigint references "users"("id") ON DELETE SET NULL,
+    "days_before_notify" int NOT NULL,
+    "notify_date" date NOT NULL,
+    "sent_at" timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX "notification_history_recipient_user_id_idx" ON "notification_history"("recipient_user_id");
+CREATE INDEX "notification_history_subscribed_user_id_idx" ON "notification_history"("subscribed_user_id");
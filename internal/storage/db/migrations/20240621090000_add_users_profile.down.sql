@@ -0,0 +1,6 @@
+ALTER TABLE "users"
+    DROP COLUMN "photo",
+    DROP COLUMN "bio",
+    DROP COLUMN "display_name",
+    DROP COLUMN "last_name",
+    DROP COLUMN "first_name";
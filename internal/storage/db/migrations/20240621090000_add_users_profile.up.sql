@@ -0,0 +1,7 @@
+ALTER TABLE "users"
+    ADD COLUMN "first_name" varchar(100) NOT NULL DEFAULT '',
+    ADD COLUMN "last_name" varchar(100) NOT NULL DEFAULT '',
+    ADD COLUMN "display_name" varchar(100) NOT NULL DEFAULT '',
+    ADD COLUMN "bio" varchar(1000) NOT NULL DEFAULT '',
+    -- name of the file in the photo directory
+    ADD COLUMN "photo" varchar(255);
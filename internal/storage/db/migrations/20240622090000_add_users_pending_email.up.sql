@@ -0,0 +1,2 @@
+-- a new email is kept here until the user opens the link sent to it
+ALTER TABLE "users" ADD COLUMN "pending_email" varchar(256);
@@ -0,0 +1,2 @@
+-- links for a pending email change issued before this time are refused
+ALTER TABLE "users" ADD COLUMN "pending_email_requested_at" timestamptz;
@@ -0,0 +1 @@
+ALTER TABLE "login_throttles" DROP COLUMN "previous_failed_at";
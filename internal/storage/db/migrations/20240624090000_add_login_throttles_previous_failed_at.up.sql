@@ -0,0 +1,3 @@
+-- "last_failed_at" before the latest reserved attempt, restored when the
+-- attempt is released
+ALTER TABLE "login_throttles" ADD COLUMN "previous_failed_at" timestamptz;
@@ -19,6 +19,9 @@ type ErrUserNotFound struct {
 }
 
 func (err ErrUserNotFound) Error() string {
+	if err.User.Email == "" {
+		return fmt.Sprintf("user with id=%d not found", err.User.ID)
+	}
 	return fmt.Sprintf("user with email \"%s\" not found", err.User.Email)
 }
 
@@ -33,3 +36,83 @@ func (err ErrSubscriptionNotFound) Error() string {
 		err.Subscription.SubscribingUserID,
 	)
 }
+
+type ErrRefreshTokenNotFound struct{}
+
+func (err ErrRefreshTokenNotFound) Error() string {
+	return "refresh token not found"
+}
+
+type ErrRefreshTokenUsed struct {
+	RefreshToken models.RefreshToken
+}
+
+func (err ErrRefreshTokenUsed) Error() string {
+	return fmt.Sprintf("refresh token with id=%d already used", err.RefreshToken.ID)
+}
+
+type ErrSessionNotFound struct {
+	Session models.Session
+}
+
+func (err ErrSessionNotFound) Error() string {
+	return fmt.Sprintf("session with id=%d not found", err.Session.ID)
+}
+
+type ErrAPIKeyNotFound struct {
+	APIKey models.APIKey
+}
+
+func (err ErrAPIKeyNotFound) Error() string {
+	if err.APIKey.ID == 0 {
+		return "api key not found"
+	}
+	return fmt.Sprintf("api key with id=%d not found", err.APIKey.ID)
+}
+
+type ErrPasswordResetTokenNotFound struct{}
+
+func (err ErrPasswordResetTokenNotFound) Error() string {
+	return "password reset token not found, used or expired"
+}
+
+type ErrTOTPNotFound struct {
+	TOTP models.TOTP
+}
+
+func (err ErrTOTPNotFound) Error() string {
+	return fmt.Sprintf("two-factor authentication of user with id=%d not found", err.TOTP.UserID)
+}
+
+type ErrTOTPStepUsed struct {
+	TOTP models.TOTP
+}
+
+func (err ErrTOTPStepUsed) Error() string {
+	return fmt.Sprintf("totp code of user with id=%d already used", err.TOTP.UserID)
+}
+
+type ErrRecoveryCodeNotFound struct{}
+
+func (err ErrRecoveryCodeNotFound) Error() string {
+	return "recovery code not found or already used"
+}
+
+type ErrNotifySettingNotFound struct {
+	NotifySetting models.NotifySetting
+}
+
+func (err ErrNotifySettingNotFound) Error() string {
+	if err.NotifySetting.ID == 0 {
+		return fmt.Sprintf("notify setting of user with id=%d not found", err.NotifySetting.UserID)
+	}
+	return fmt.Sprintf("notify setting with id=%d not found", err.NotifySetting.ID)
+}
+
+type ErrNotifySettingNotUniq struct {
+	NotifySetting models.NotifySetting
+}
+
+func (err ErrNotifySettingNotUniq) Error() string {
+	return fmt.Sprintf("user with id=%d already has notify setting", err.NotifySetting.UserID)
+}
@@ -0,0 +1,142 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ilya-burinskiy/birthday-notify/internal/models"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// FindUserByIdentity finds the user linked to the single sign-on identity.
+func (db *DBStorage) FindUserByIdentity(ctx context.Context, issuer, subject string) (models.User, error) {
+	row := db.pool.QueryRow(
+		ctx,
+		`SELECT `+userColumns+`
+		 FROM "users"
+		 INNER JOIN "user_identities" ON "user_identities"."user_id" = "users"."id"
+		 WHERE "issuer" = $1 AND "subject" = $2`,
+		issuer,
+		subject,
+	)
+	user, err := scanUser(row)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.User{}, ErrUserNotFound{}
+		}
+		return user, fmt.Errorf("failed to find user by identity: %w", err)
+	}
+
+	return user, nil
+}
+
+// LinkIdentity links the identity to an existing user. The provider has
+// verified the email, so it is marked verified here too. If the account had
+// not verified the email, whoever registered it did not prove they own it:
+// the password, a pending email change, two-factor enrollment, sessions and
+// API keys are dropped, so only the owner of the email keeps access.
+func (db *DBStorage) LinkIdentity(ctx context.Context, userID int, issuer, subject string) error {
+	err := pgx.BeginFunc(ctx, db.pool, func(tx pgx.Tx) error {
+		_, err := tx.Exec(
+			ctx,
+			`INSERT INTO "user_identities" ("user_id", "issuer", "subject") VALUES ($1, $2, $3)`,
+			userID,
+			issuer,
+			subject,
+		)
+		if err != nil {
+			return err
+		}
+
+		tag, err := tx.Exec(
+			ctx,
+			`UPDATE "users" SET "email_verified_at" = now(), "encrypted_password" = '',
+			   "pending_email" = NULL, "pending_email_requested_at" = NULL
+			 WHERE "id" = $1 AND "email_verified_at" IS NULL`,
+			userID,
+		)
+		if err != nil || tag.RowsAffected() == 0 {
+			return err
+		}
+
+		for _, query := range []string{
+			`UPDATE "sessions" SET "revoked_at" = now() WHERE "user_id" = $1 AND "revoked_at" IS NULL`,
+			`UPDATE "api_keys" SET "revoked_at" = now() WHERE "user_id" = $1 AND "revoked_at" IS NULL`,
+			`DELETE FROM "user_totp" WHERE "user_id" = $1`,
+			`DELETE FROM "totp_recovery_codes" WHERE "user_id" = $1`,
+			`DELETE FROM "password_reset_tokens" WHERE "user_id" = $1`,
+		} {
+			if _, err := tx.Exec(ctx, query, userID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to link identity to user with id=%d: %w", userID, err)
+	}
+
+	return nil
+}
+
+// CreateUserWithIdentity creates a user without a password who can only
+// sign in with the identity (or after a password reset).
+func (db *DBStorage) CreateUserWithIdentity(ctx context.Context, email, issuer, subject string) (models.User, error) {
+	user := models.User{Email: email, EncryptedPassword: []byte{}, Role: models.RoleUser}
+	err := pgx.BeginFunc(ctx, db.pool, func(tx pgx.Tx) error {
+		row := tx.QueryRow(
+			ctx,
+			`INSERT INTO "users" ("email", "encrypted_password", "email_verified_at")
+			 VALUES ($1, $2, now())
+			 RETURNING "id", "email_verified_at"`,
+			email,
+			user.EncryptedPassword,
+		)
+		if err := row.Scan(&user.ID, &user.EmailVerifiedAt); err != nil {
+			return err
+		}
+
+		_, err := tx.Exec(
+			ctx,
+			`INSERT INTO "user_identities" ("user_id", "issuer", "subject") VALUES ($1, $2, $3)`,
+			user.ID,
+			issuer,
+			subject,
+		)
+		return err
+	})
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation {
+			return user, ErrUserNotUniq{User: user}
+		}
+		return user, fmt.Errorf("failed to create user: %w", err)
+	}
+
+	return user, nil
+}
+
+func (db *DBStorage) ListUserIdentities(ctx context.Context, userID int) ([]models.Identity, error) {
+	rows, err := db.pool.Query(
+		ctx,
+		`SELECT "issuer", "subject", "created_at" FROM "user_identities" WHERE "user_id" = $1 ORDER BY "id"`,
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list identities: %w", err)
+	}
+
+	result, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.Identity, error) {
+		var identity models.Identity
+		err := row.Scan(&identity.Issuer, &identity.Subject, &identity.CreatedAt)
+		return identity, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list identities: %w", err)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,39 @@
+package storage_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ilya-burinskiy/birthday-notify/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// A squatter registers the victim's email and starts an email change to an
+// address they own. Once the victim signs in with single sign-on, the
+// squatter's link must not move the account to the squatter's address,
+// otherwise a password reset sent there would hand the account over.
+func TestLinkIdentityDropsPendingEmailChange(t *testing.T) {
+	db := newTestStorage(t)
+	ctx := context.Background()
+	victimEmail := uniqueEmail("victim")
+	squatterEmail := uniqueEmail("squatter")
+
+	squatter, err := db.CreateUser(ctx, victimEmail, []byte("hash"), time.Date(1999, 1, 1, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	_, err = db.SetPendingEmail(ctx, squatter.ID, &squatterEmail)
+	require.NoError(t, err)
+
+	require.NoError(t, db.LinkIdentity(ctx, squatter.ID, "https://accounts.example.com", victimEmail))
+
+	err = db.MarkEmailVerified(ctx, squatter.ID, squatterEmail, time.Now())
+	assert.ErrorAs(t, err, &storage.ErrUserNotFound{})
+	user, err := db.FindUserByID(ctx, squatter.ID)
+	require.NoError(t, err)
+	assert.Equal(t, victimEmail, user.Email)
+	assert.Nil(t, user.PendingEmail)
+	assert.Empty(t, user.EncryptedPassword)
+	_, err = db.FindUserByEmail(ctx, squatterEmail)
+	assert.ErrorAs(t, err, &storage.ErrUserNotFound{})
+}
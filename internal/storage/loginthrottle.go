@@ -0,0 +1,246 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ilya-burinskiy/birthday-notify/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// FindLoginThrottle returns a throttle without failures if nothing was
+// recorded for the key.
+func (db *DBStorage) FindLoginThrottle(ctx context.Context, scope, key string) (models.LoginThrottle, error) {
+	row := db.pool.QueryRow(
+		ctx,
+		`SELECT "failures", "last_failed_at", "locked_until" FROM "login_throttles"
+		 WHERE "scope" = $1 AND "key" = $2`,
+		scope,
+		key,
+	)
+	throttle := models.LoginThrottle{Scope: scope, Key: key}
+	err := row.Scan(&throttle.Failures, &throttle.LastFailedAt, &throttle.LockedUntil)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return throttle, fmt.Errorf("failed to find login throttle: %w", err)
+	}
+
+	return throttle, nil
+}
+
+// ReserveLoginAttempt counts an attempt before its outcome is known, so
+// that concurrent attempts can not all pass the check before any of them is
+// counted. The row is locked while allow decides on the current state; if
+// allow returns an error, nothing is counted and the error is returned.
+// Failures before windowStart are forgotten.
+func (db *DBStorage) ReserveLoginAttempt(
+	ctx context.Context,
+	scope, key string,
+	at, windowStart time.Time,
+	allow func(models.LoginThrottle) error,
+) (models.LoginThrottle, error) {
+
+	throttle := models.LoginThrottle{Scope: scope, Key: key}
+	var denied error
+	err := pgx.BeginFunc(ctx, db.pool, func(tx pgx.Tx) error {
+		_, err := tx.Exec(
+			ctx,
+			`INSERT INTO "login_throttles" ("scope", "key", "failures", "last_failed_at") VALUES ($1, $2, 0, $3)
+			 ON CONFLICT ("scope", "key") DO NOTHING`,
+			scope,
+			key,
+			at,
+		)
+		if err != nil {
+			return err
+		}
+
+		row := tx.QueryRow(
+			ctx,
+			`SELECT "failures", "last_failed_at", "locked_until" FROM "login_throttles"
+			 WHERE "scope" = $1 AND "key" = $2
+			 FOR UPDATE`,
+			scope,
+			key,
+		)
+		if err := row.Scan(&throttle.Failures, &throttle.LastFailedAt, &throttle.LockedUntil); err != nil {
+			return err
+		}
+		if denied = allow(throttle); denied != nil {
+			return denied
+		}
+
+		row = tx.QueryRow(
+			ctx,
+			`UPDATE "login_throttles"
+			 SET "failures" = CASE WHEN "last_failed_at" < $4 THEN 1 ELSE "failures" + 1 END,
+			     "previous_failed_at" = "last_failed_at",
+			     "last_failed_at" = $3
+			 WHERE "scope" = $1 AND "key" = $2
+			 RETURNING "failures", "last_failed_at", "locked_until"`,
+			scope,
+			key,
+			at,
+			windowStart,
+		)
+		return row.Scan(&throttle.Failures, &throttle.LastFailedAt, &throttle.LockedUntil)
+	})
+	if denied != nil {
+		return throttle, denied
+	}
+	if err != nil {
+		return throttle, fmt.Errorf("failed to reserve login attempt: %w", err)
+	}
+
+	return throttle, nil
+}
+
+// ReleaseLoginAttempt takes back an attempt reserved by ReserveLoginAttempt
+// that turned out not to be a failure. The time of the last failure is
+// restored too, otherwise the released attempt would still delay the next
+// one.
+func (db *DBStorage) ReleaseLoginAttempt(ctx context.Context, scope, key string) error {
+	_, err := db.pool.Exec(
+		ctx,
+		`UPDATE "login_throttles"
+		 SET "failures" = GREATEST("failures" - 1, 0),
+		     "last_failed_at" = COALESCE("previous_failed_at", "last_failed_at"),
+		     "previous_failed_at" = NULL
+		 WHERE "scope" = $1 AND "key" = $2`,
+		scope,
+		key,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to release login attempt: %w", err)
+	}
+
+	return nil
+}
+
+// LockLogin locks the key until lockout.LockedUntil and records the lockout
+// in the audit trail.
+func (db *DBStorage) LockLogin(ctx context.Context, lockout models.LoginLockout) (models.LoginLockout, error) {
+	err := pgx.BeginFunc(ctx, db.pool, func(tx pgx.Tx) error {
+		_, err := tx.Exec(
+			ctx,
+			`UPDATE "login_throttles" SET "locked_until" = $3 WHERE "scope" = $1 AND "key" = $2`,
+			lockout.Scope,
+			lockout.Key,
+			lockout.LockedUntil,
+		)
+		if err != nil {
+			return err
+		}
+		row := tx.QueryRow(
+			ctx,
+			`INSERT INTO "login_lockouts" ("scope", "key", "ip", "failures", "locked_at", "locked_until")
+			 VALUES ($1, $2, $3, $4, $5, $6) RETURNING "id"`,
+			lockout.Scope,
+			lockout.Key,
+			lockout.IP,
+			lockout.Failures,
+			lockout.LockedAt,
+			lockout.LockedUntil,
+		)
+		return row.Scan(&lockout.ID)
+	})
+	if err != nil {
+		return lockout, fmt.Errorf("failed to lock login: %w", err)
+	}
+
+	return lockout, nil
+}
+
+func (db *DBStorage) ResetLoginThrottle(ctx context.Context, scope, key string) error {
+	_, err := db.pool.Exec(ctx, `DELETE FROM "login_throttles" WHERE "scope" = $1 AND "key" = $2`, scope, key)
+	if err != nil {
+		return fmt.Errorf("failed to reset login throttle: %w", err)
+	}
+
+	return nil
+}
+
+// UnlockLogin forgets failures of the key and marks its active lockouts as
+// unlocked.
+func (db *DBStorage) UnlockLogin(ctx context.Context, scope, key string) error {
+	err := pgx.BeginFunc(ctx, db.pool, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, `DELETE FROM "login_throttles" WHERE "scope" = $1 AND "key" = $2`, scope, key)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(
+			ctx,
+			`UPDATE "login_lockouts" SET "unlocked_at" = now()
+			 WHERE "scope" = $1 AND "key" = $2 AND "unlocked_at" IS NULL AND "locked_until" > now()`,
+			scope,
+			key,
+		)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to unlock login: %w", err)
+	}
+
+	return nil
+}
+
+// ListLoginLockouts returns the latest lockouts first.
+func (db *DBStorage) ListLoginLockouts(ctx context.Context, limit int) ([]models.LoginLockout, error) {
+	rows, err := db.pool.Query(
+		ctx,
+		`SELECT "id", "scope", "key", "ip", "failures", "locked_at", "locked_until", "unlocked_at"
+		 FROM "login_lockouts" ORDER BY "locked_at" DESC LIMIT $1`,
+		limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list login lockouts: %w", err)
+	}
+
+	lockouts, err := collectLoginLockouts(rows)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list login lockouts: %w", err)
+	}
+
+	return lockouts, nil
+}
+
+// ListAccountLoginLockouts returns the lockouts of the account with the
+// email, the latest first.
+func (db *DBStorage) ListAccountLoginLockouts(ctx context.Context, email string) ([]models.LoginLockout, error) {
+	rows, err := db.pool.Query(
+		ctx,
+		`SELECT "id", "scope", "key", "ip", "failures", "locked_at", "locked_until", "unlocked_at"
+		 FROM "login_lockouts" WHERE "scope" = $1 AND "key" = lower($2)
+		 ORDER BY "locked_at" DESC`,
+		models.LoginScopeAccount,
+		email,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list account login lockouts: %w", err)
+	}
+
+	lockouts, err := collectLoginLockouts(rows)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list account login lockouts: %w", err)
+	}
+
+	return lockouts, nil
+}
+
+func collectLoginLockouts(rows pgx.Rows) ([]models.LoginLockout, error) {
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.LoginLockout, error) {
+		var lockout models.LoginLockout
+		err := row.Scan(
+			&lockout.ID,
+			&lockout.Scope,
+			&lockout.Key,
+			&lockout.IP,
+			&lockout.Failures,
+			&lockout.LockedAt,
+			&lockout.LockedUntil,
+			&lockout.UnlockedAt,
+		)
+		return lockout, err
+	})
+}
@@ -0,0 +1,32 @@
+package storage_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ilya-burinskiy/birthday-notify/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReleaseLoginAttemptRestoresLastFailure(t *testing.T) {
+	db := newTestStorage(t)
+	ctx := context.Background()
+	key := uniqueEmail("throttled")
+	allow := func(models.LoginThrottle) error { return nil }
+	failedAt := time.Now().Add(-time.Minute)
+	windowStart := failedAt.Add(-time.Hour)
+
+	// a failed attempt, then one with the right password
+	_, err := db.ReserveLoginAttempt(ctx, models.LoginScopeAccount, key, failedAt, windowStart, allow)
+	require.NoError(t, err)
+	_, err = db.ReserveLoginAttempt(ctx, models.LoginScopeAccount, key, time.Now(), windowStart, allow)
+	require.NoError(t, err)
+	require.NoError(t, db.ReleaseLoginAttempt(ctx, models.LoginScopeAccount, key))
+
+	throttle, err := db.FindLoginThrottle(ctx, models.LoginScopeAccount, key)
+	require.NoError(t, err)
+	assert.Equal(t, 1, throttle.Failures)
+	assert.WithinDuration(t, failedAt, throttle.LastFailedAt, time.Millisecond)
+}
@@ -0,0 +1,72 @@
+package storage_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ilya-burinskiy/birthday-notify/internal/models"
+	"github.com/ilya-burinskiy/birthday-notify/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// createSubscription creates a verified user subscribed to a user with a
+// birthday on birthdate.
+func createSubscription(t *testing.T, db *storage.DBStorage, birthdate time.Time) (models.User, models.User) {
+	t.Helper()
+	ctx := context.Background()
+	recipient, err := db.CreateUser(ctx, uniqueEmail("recipient"), []byte("hash"), time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	require.NoError(t, db.MarkEmailVerified(ctx, recipient.ID, recipient.Email, time.Now()))
+	subscribed, err := db.CreateUser(ctx, uniqueEmail("subscribed"), []byte("hash"), birthdate)
+	require.NoError(t, err)
+	_, err = db.CreateSubscription(ctx, subscribed.ID, recipient.ID)
+	require.NoError(t, err)
+	return recipient, subscribed
+}
+
+// notificationsFor keeps the notifications between the users, other tests
+// may have left subscriptions for the same date.
+func notificationsFor(notifications []models.Notification, recipient, subscribed models.User) []models.Notification {
+	var result []models.Notification
+	for _, notification := range notifications {
+		if notification.SubscribingUserID == recipient.ID && notification.SubscribedUserID == subscribed.ID {
+			result = append(result, notification)
+		}
+	}
+	return result
+}
+
+func TestFetchNotificationsForDateSkipsSent(t *testing.T) {
+	db := newTestStorage(t)
+	ctx := context.Background()
+	date := time.Date(2024, 6, 10, 0, 0, 0, 0, time.UTC)
+	recipient, subscribed := createSubscription(t, db, time.Date(1995, 6, 11, 0, 0, 0, 0, time.UTC))
+
+	notifications, err := db.FetchNotificationsForDate(ctx, date)
+	require.NoError(t, err)
+	due := notificationsFor(notifications, recipient, subscribed)
+	require.Len(t, due, 1)
+
+	require.NoError(t, db.RecordNotification(ctx, due[0], date))
+	notifications, err = db.FetchNotificationsForDate(ctx, date)
+	require.NoError(t, err)
+	assert.Empty(t, notificationsFor(notifications, recipient, subscribed))
+}
+
+func TestFetchNotificationsForDateSkipsDisabledUsers(t *testing.T) {
+	db := newTestStorage(t)
+	ctx := context.Background()
+	date := time.Date(2024, 6, 10, 0, 0, 0, 0, time.UTC)
+	birthdate := time.Date(1995, 6, 11, 0, 0, 0, 0, time.UTC)
+	disabledRecipient, subscribed := createSubscription(t, db, birthdate)
+	require.NoError(t, db.DisableUser(ctx, disabledRecipient.ID))
+	recipient, disabledSubscribed := createSubscription(t, db, birthdate)
+	require.NoError(t, db.DisableUser(ctx, disabledSubscribed.ID))
+
+	notifications, err := db.FetchNotificationsForDate(ctx, date)
+	require.NoError(t, err)
+	assert.Empty(t, notificationsFor(notifications, disabledRecipient, subscribed))
+	assert.Empty(t, notificationsFor(notifications, recipient, disabledSubscribed))
+}
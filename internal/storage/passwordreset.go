@@ -0,0 +1,94 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// CreatePasswordResetToken stores a new reset token for the user. Tokens
+// issued earlier stop working, so only the latest email can be used.
+func (db *DBStorage) CreatePasswordResetToken(
+	ctx context.Context,
+	userID int,
+	tokenHash []byte,
+	expiresAt time.Time,
+) error {
+
+	err := pgx.BeginFunc(ctx, db.pool, func(tx pgx.Tx) error {
+		_, err := tx.Exec(
+			ctx,
+			`UPDATE "password_reset_tokens" SET "used_at" = now() WHERE "user_id" = $1 AND "used_at" IS NULL`,
+			userID,
+		)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(
+			ctx,
+			`INSERT INTO "password_reset_tokens" ("user_id", "token_hash", "expires_at") VALUES ($1, $2, $3)`,
+			userID,
+			tokenHash,
+			expiresAt,
+		)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create password reset token: %w", err)
+	}
+
+	return nil
+}
+
+// ResetPassword uses the reset token, sets the new password, drops a pending
+// email change and revokes all sessions of the user in one transaction. It
+// returns the id of the user.
+func (db *DBStorage) ResetPassword(ctx context.Context, tokenHash []byte, encryptedPassword []byte) (int, error) {
+	var userID int
+	err := pgx.BeginFunc(ctx, db.pool, func(tx pgx.Tx) error {
+		row := tx.QueryRow(
+			ctx,
+			`UPDATE "password_reset_tokens" SET "used_at" = now()
+			 WHERE "token_hash" = $1 AND "used_at" IS NULL AND "expires_at" > now()
+			 RETURNING "user_id"`,
+			tokenHash,
+		)
+		if err := row.Scan(&userID); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ErrPasswordResetTokenNotFound{}
+			}
+			return err
+		}
+
+		_, err := tx.Exec(
+			ctx,
+			`UPDATE "users" SET "encrypted_password" = $1, "pending_email" = NULL, "pending_email_requested_at" = NULL
+			 WHERE "id" = $2`,
+			encryptedPassword,
+			userID,
+		)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(
+			ctx,
+			`UPDATE "sessions" SET "revoked_at" = now() WHERE "user_id" = $1 AND "revoked_at" IS NULL`,
+			userID,
+		)
+		return err
+	})
+	if err != nil {
+		var notFoundErr ErrPasswordResetTokenNotFound
+		if errors.As(err, &notFoundErr) {
+			return 0, notFoundErr
+		}
+		return 0, fmt.Errorf("failed to reset password: %w", err)
+	}
+
+	return userID, nil
+}
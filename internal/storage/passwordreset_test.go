@@ -0,0 +1,47 @@
+package storage_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ilya-burinskiy/birthday-notify/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResetPasswordDropsPendingEmailChange(t *testing.T) {
+	db := newTestStorage(t)
+	ctx := context.Background()
+	email := uniqueEmail("owner")
+	attackerEmail := uniqueEmail("attacker")
+
+	user, err := db.CreateUser(ctx, email, []byte("hash"), time.Date(1999, 1, 1, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	_, err = db.SetPendingEmail(ctx, user.ID, &attackerEmail)
+	require.NoError(t, err)
+	linkIssuedAt := time.Now()
+
+	tokenHash := []byte(uniqueEmail("token"))
+	require.NoError(t, db.CreatePasswordResetToken(ctx, user.ID, tokenHash, time.Now().Add(time.Hour)))
+	_, err = db.ResetPassword(ctx, tokenHash, []byte("new hash"))
+	require.NoError(t, err)
+
+	err = db.MarkEmailVerified(ctx, user.ID, attackerEmail, linkIssuedAt)
+	assert.ErrorAs(t, err, &storage.ErrUserNotFound{})
+
+	t.Run("refuses a link issued before the change was requested again", func(t *testing.T) {
+		time.Sleep(time.Second)
+		_, err := db.SetPendingEmail(ctx, user.ID, &attackerEmail)
+		require.NoError(t, err)
+
+		err = db.MarkEmailVerified(ctx, user.ID, attackerEmail, linkIssuedAt)
+		assert.ErrorAs(t, err, &storage.ErrUserNotFound{})
+		require.NoError(t, db.MarkEmailVerified(ctx, user.ID, attackerEmail, time.Now()))
+
+		user, err := db.FindUserByID(ctx, user.ID)
+		require.NoError(t, err)
+		assert.Equal(t, attackerEmail, user.Email)
+		assert.Nil(t, user.PendingEmail)
+	})
+}
@@ -0,0 +1,159 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ilya-burinskiy/birthday-notify/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// CreateSession starts a session for the user together with its first
+// refresh token.
+func (db *DBStorage) CreateSession(
+	ctx context.Context,
+	userID int,
+	refreshTokenHash []byte,
+	expiresAt time.Time,
+) (models.Session, error) {
+
+	session := models.Session{UserID: userID}
+	err := pgx.BeginFunc(ctx, db.pool, func(tx pgx.Tx) error {
+		row := tx.QueryRow(
+			ctx,
+			`INSERT INTO "sessions" ("user_id") VALUES ($1) RETURNING "id", "created_at"`,
+			userID,
+		)
+		if err := row.Scan(&session.ID, &session.CreatedAt); err != nil {
+			return err
+		}
+
+		_, err := tx.Exec(
+			ctx,
+			`INSERT INTO "refresh_tokens" ("session_id", "token_hash", "expires_at") VALUES ($1, $2, $3)`,
+			session.ID,
+			refreshTokenHash,
+			expiresAt,
+		)
+		return err
+	})
+	if err != nil {
+		return session, fmt.Errorf("failed to create session: %w", err)
+	}
+
+	return session, nil
+}
+
+func (db *DBStorage) FindRefreshToken(ctx context.Context, tokenHash []byte) (models.RefreshToken, error) {
+	row := db.pool.QueryRow(
+		ctx,
+		`SELECT "refresh_tokens"."id", "session_id", "user_id", "expires_at", "used_at", "revoked_at" IS NOT NULL
+		 FROM "refresh_tokens"
+		 INNER JOIN "sessions" ON "refresh_tokens"."session_id" = "sessions"."id"
+		 WHERE "token_hash" = $1`,
+		tokenHash,
+	)
+	var token models.RefreshToken
+	err := row.Scan(
+		&token.ID,
+		&token.SessionID,
+		&token.UserID,
+		&token.ExpiresAt,
+		&token.UsedAt,
+		&token.SessionRevoked,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return token, ErrRefreshTokenNotFound{}
+		}
+		return token, fmt.Errorf("failed to find refresh token: %w", err)
+	}
+
+	return token, nil
+}
+
+// RotateRefreshToken marks the token as used and stores its successor. It
+// fails with ErrRefreshTokenUsed if the token was used concurrently.
+func (db *DBStorage) RotateRefreshToken(
+	ctx context.Context,
+	token models.RefreshToken,
+	newTokenHash []byte,
+	expiresAt time.Time,
+) error {
+
+	err := pgx.BeginFunc(ctx, db.pool, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(
+			ctx,
+			`UPDATE "refresh_tokens" SET "used_at" = now() WHERE "id" = $1 AND "used_at" IS NULL`,
+			token.ID,
+		)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			return ErrRefreshTokenUsed{RefreshToken: token}
+		}
+
+		_, err = tx.Exec(
+			ctx,
+			`INSERT INTO "refresh_tokens" ("session_id", "token_hash", "expires_at") VALUES ($1, $2, $3)`,
+			token.SessionID,
+			newTokenHash,
+			expiresAt,
+		)
+		return err
+	})
+	if err != nil {
+		var usedErr ErrRefreshTokenUsed
+		if errors.As(err, &usedErr) {
+			return usedErr
+		}
+		return fmt.Errorf("failed to rotate refresh token: %w", err)
+	}
+
+	return nil
+}
+
+func (db *DBStorage) RevokeSession(ctx context.Context, sessionID int) error {
+	_, err := db.pool.Exec(
+		ctx,
+		`UPDATE "sessions" SET "revoked_at" = now() WHERE "id" = $1 AND "revoked_at" IS NULL`,
+		sessionID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to revoke session with id=%d: %w", sessionID, err)
+	}
+	return nil
+}
+
+func (db *DBStorage) RevokeUserSessions(ctx context.Context, userID int) error {
+	_, err := db.pool.Exec(
+		ctx,
+		`UPDATE "sessions" SET "revoked_at" = now() WHERE "user_id" = $1 AND "revoked_at" IS NULL`,
+		userID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to revoke sessions of user with id=%d: %w", userID, err)
+	}
+	return nil
+}
+
+func (db *DBStorage) FindSession(ctx context.Context, sessionID int) (models.Session, error) {
+	row := db.pool.QueryRow(
+		ctx,
+		`SELECT "user_id", "created_at", "revoked_at" FROM "sessions" WHERE "id" = $1`,
+		sessionID,
+	)
+	session := models.Session{ID: sessionID}
+	err := row.Scan(&session.UserID, &session.CreatedAt, &session.RevokedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return session, ErrSessionNotFound{Session: session}
+		}
+		return session, fmt.Errorf("failed to find session: %w", err)
+	}
+
+	return session, nil
+}
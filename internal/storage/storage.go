@@ -36,6 +36,10 @@ func NewDBStorage(dsn string) (*DBStorage, error) {
 	}, nil
 }
 
+func (db *DBStorage) Close() {
+	db.pool.Close()
+}
+
 func (db *DBStorage) CreateUser(ctx context.Context, email string, encryptedPassword []byte, birthDate time.Time) (models.User, error) {
 	row := db.pool.QueryRow(
 		ctx,
@@ -44,7 +48,7 @@ func (db *DBStorage) CreateUser(ctx context.Context, email string, encryptedPass
 		encryptedPassword,
 		birthDate,
 	)
-	user := models.User{Email: email, EncryptedPassword: encryptedPassword, BirthDate: birthDate}
+	user := models.User{Email: email, EncryptedPassword: encryptedPassword, BirthDate: &birthDate, Role: models.RoleUser}
 	err := row.Scan(&user.ID)
 	if err != nil {
 		var pgErr *pgconn.PgError
@@ -60,16 +64,15 @@ func (db *DBStorage) CreateUser(ctx context.Context, email string, encryptedPass
 func (db *DBStorage) FindUserByEmail(ctx context.Context, email string) (models.User, error) {
 	row := db.pool.QueryRow(
 		ctx,
-		`SELECT "id", "encrypted_password", "birthdate"
+		`SELECT `+userColumns+`
 		 FROM "users"
 		 WHERE "email" = $1`,
 		email,
 	)
-	user := models.User{Email: email}
-	err := row.Scan(&user.ID, &user.EncryptedPassword, &user.BirthDate)
+	user, err := scanUser(row)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			return user, ErrUserNotFound{User: user}
+			return user, ErrUserNotFound{User: models.User{Email: email}}
 		}
 
 		return user, fmt.Errorf("failed to find user: %w", err)
@@ -78,6 +81,223 @@ func (db *DBStorage) FindUserByEmail(ctx context.Context, email string) (models.
 	return user, nil
 }
 
+func (db *DBStorage) FindUserByID(ctx context.Context, userID int) (models.User, error) {
+	row := db.pool.QueryRow(
+		ctx,
+		`SELECT `+userColumns+`
+		 FROM "users"
+		 WHERE "id" = $1`,
+		userID,
+	)
+	user, err := scanUser(row)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return user, ErrUserNotFound{User: models.User{ID: userID}}
+		}
+
+		return user, fmt.Errorf("failed to find user: %w", err)
+	}
+
+	return user, nil
+}
+
+func (db *DBStorage) UpdateUserPassword(ctx context.Context, userID int, encryptedPassword []byte) error {
+	tag, err := db.pool.Exec(
+		ctx,
+		`UPDATE "users" SET "encrypted_password" = $1 WHERE "id" = $2`,
+		encryptedPassword,
+		userID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update password: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrUserNotFound{User: models.User{ID: userID}}
+	}
+
+	return nil
+}
+
+// UpdateUser changes the profile of the user. The email is changed with
+// SetPendingEmail and MarkEmailVerified.
+func (db *DBStorage) UpdateUser(ctx context.Context, userID int, update models.UserUpdate) (models.User, error) {
+	row := db.pool.QueryRow(
+		ctx,
+		`UPDATE "users" SET
+		   "birthdate" = COALESCE($2, "birthdate"),
+		   "first_name" = COALESCE($3, "first_name"),
+		   "last_name" = COALESCE($4, "last_name"),
+		   "display_name" = COALESCE($5, "display_name"),
+		   "bio" = COALESCE($6, "bio")
+		 WHERE "id" = $1
+		 RETURNING `+userColumns,
+		userID,
+		update.BirthDate,
+		update.FirstName,
+		update.LastName,
+		update.DisplayName,
+		update.Bio,
+	)
+	user, err := scanUser(row)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return user, ErrUserNotFound{User: models.User{ID: userID}}
+		}
+		return user, fmt.Errorf("failed to update user: %w", err)
+	}
+
+	return user, nil
+}
+
+// SetPendingEmail stores the email the user wants to change to, nil cancels
+// the change. The time of the request is kept with the precision of the
+// verification tokens, see MarkEmailVerified.
+func (db *DBStorage) SetPendingEmail(ctx context.Context, userID int, email *string) (models.User, error) {
+	var requestedAt *time.Time
+	if email != nil {
+		now := time.Now().Truncate(time.Second)
+		requestedAt = &now
+	}
+	row := db.pool.QueryRow(
+		ctx,
+		`UPDATE "users" SET "pending_email" = $2, "pending_email_requested_at" = $3
+		 WHERE "id" = $1 RETURNING `+userColumns,
+		userID,
+		email,
+		requestedAt,
+	)
+	user, err := scanUser(row)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return user, ErrUserNotFound{User: models.User{ID: userID}}
+		}
+		return user, fmt.Errorf("failed to set pending email of user with id=%d: %w", userID, err)
+	}
+
+	return user, nil
+}
+
+// SetUserPhoto replaces the photo file name of the user, nil removes the
+// photo. It returns the previous file name so that the file can be deleted.
+func (db *DBStorage) SetUserPhoto(ctx context.Context, userID int, photo *string) (string, error) {
+	row := db.pool.QueryRow(
+		ctx,
+		`UPDATE "users" SET "photo" = $2
+		 FROM (SELECT "photo" FROM "users" WHERE "id" = $1 FOR UPDATE) AS "previous"
+		 WHERE "users"."id" = $1
+		 RETURNING "previous"."photo"`,
+		userID,
+		photo,
+	)
+	var previous *string
+	if err := row.Scan(&previous); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", ErrUserNotFound{User: models.User{ID: userID}}
+		}
+		return "", fmt.Errorf("failed to set photo of user with id=%d: %w", userID, err)
+	}
+	if previous == nil {
+		return "", nil
+	}
+
+	return *previous, nil
+}
+
+// ChangeUserPassword sets the new password and revokes every session of the
+// user except the one making the change in one transaction.
+func (db *DBStorage) ChangeUserPassword(ctx context.Context, userID, keepSessionID int, encryptedPassword []byte) error {
+	err := pgx.BeginFunc(ctx, db.pool, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(
+			ctx,
+			`UPDATE "users" SET "encrypted_password" = $1 WHERE "id" = $2`,
+			encryptedPassword,
+			userID,
+		)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			return ErrUserNotFound{User: models.User{ID: userID}}
+		}
+
+		_, err = tx.Exec(
+			ctx,
+			`UPDATE "sessions" SET "revoked_at" = now()
+			 WHERE "user_id" = $1 AND "id" <> $2 AND "revoked_at" IS NULL`,
+			userID,
+			keepSessionID,
+		)
+		return err
+	})
+	if err != nil {
+		var notFoundErr ErrUserNotFound
+		if errors.As(err, &notFoundErr) {
+			return notFoundErr
+		}
+		return fmt.Errorf("failed to change password: %w", err)
+	}
+
+	return nil
+}
+
+// MarkEmailVerified marks the email verified only if it is still the
+// user's email, so that a link sent to an old address does not verify a
+// new one. Otherwise it completes the pending change to the email, if the
+// link was issued at or after the change was requested: a password reset
+// or a single sign-on takeover drops the pending change, and a link from
+// before that must not confirm the same address requested again.
+func (db *DBStorage) MarkEmailVerified(ctx context.Context, userID int, email string, issuedAt time.Time) error {
+	tag, err := db.pool.Exec(
+		ctx,
+		`UPDATE "users" SET "email_verified_at" = COALESCE("email_verified_at", now())
+		 WHERE "id" = $1 AND "email" = $2`,
+		userID,
+		email,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to verify email of user with id=%d: %w", userID, err)
+	}
+	if tag.RowsAffected() > 0 {
+		return nil
+	}
+
+	// the email confirms a change, reset tokens sent to the old address stop
+	// working
+	err = pgx.BeginFunc(ctx, db.pool, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(
+			ctx,
+			`UPDATE "users" SET "email" = $2, "pending_email" = NULL, "pending_email_requested_at" = NULL,
+			   "email_verified_at" = now()
+			 WHERE "id" = $1 AND "pending_email" = $2 AND "pending_email_requested_at" <= $3`,
+			userID,
+			email,
+			issuedAt,
+		)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			return ErrUserNotFound{User: models.User{ID: userID, Email: email}}
+		}
+
+		_, err = tx.Exec(ctx, `DELETE FROM "password_reset_tokens" WHERE "user_id" = $1`, userID)
+		return err
+	})
+	if err != nil {
+		var notFoundErr ErrUserNotFound
+		if errors.As(err, &notFoundErr) {
+			return err
+		}
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation {
+			return ErrUserNotUniq{User: models.User{Email: email}}
+		}
+		return fmt.Errorf("failed to verify email of user with id=%d: %w", userID, err)
+	}
+
+	return nil
+}
+
 func (db *DBStorage) CreateSubscription(ctx context.Context, subscribedUserID, subscribingUserID int) (models.Subscription, error) {
 	row := db.pool.QueryRow(
 		ctx,
@@ -133,18 +353,36 @@ func (db *DBStorage) DeleteSubscription(ctx context.Context, subscriptionID int)
 	return nil
 }
 
-func (db *DBStorage) FetchNotificationsForCurrentDate(ctx context.Context) ([]models.Notification, error) {
+// FetchNotificationsForDate returns the notifications due on date that are
+// not in the history for the date yet, so that running the date again does
+// not send them twice. Disabled accounts neither receive notifications nor
+// are announced.
+func (db *DBStorage) FetchNotificationsForDate(ctx context.Context, date time.Time) ([]models.Notification, error) {
 	rows, err := db.pool.Query(
 		ctx,
-		`SELECT "subscribing_users"."email" AS "subscribing_user_email",
+		`SELECT "subscribing_users"."id" AS "subscribing_user_id",
+		        "subscribing_users"."email" AS "subscribing_user_email",
+		        "subscribing_users"."first_name", "subscribing_users"."last_name", "subscribing_users"."display_name",
 		        COALESCE("days_before_notify", 1) AS "days_before_notify",
-				"subscribed_users"."email" AS "subscribed_user_email"
+		        "subscribed_users"."id" AS "subscribed_user_id",
+				"subscribed_users"."email" AS "subscribed_user_email",
+		        "subscribed_users"."first_name", "subscribed_users"."last_name", "subscribed_users"."display_name"
 		 FROM "subscriptions"
 		 INNER JOIN "users" AS "subscribed_users" ON "subscriptions"."subscribed_user_id" = "subscribed_users"."id"
 		 INNER JOIN "users" AS "subscribing_users" ON "subscriptions"."subscribing_user_id" = "subscribing_users"."id"
 		 LEFT JOIN "notify_settings" ON "subscribing_users"."id" = "notify_settings"."user_id"
-		 WHERE EXTRACT(DAY FROM CURRENT_DATE + COALESCE("days_before_notify", 1)) = EXTRACT(DAY FROM "subscribed_users"."birthdate")
-		   AND EXTRACT(MONTH FROM CURRENT_DATE + COALESCE("days_before_notify", 1)) = EXTRACT(MONTH FROM "subscribed_users"."birthdate")`,
+		 WHERE "subscribing_users"."email_verified_at" IS NOT NULL
+		   AND "subscribing_users"."disabled_at" IS NULL
+		   AND "subscribed_users"."disabled_at" IS NULL
+		   AND EXTRACT(DAY FROM $1::date + COALESCE("days_before_notify", 1)) = EXTRACT(DAY FROM "subscribed_users"."birthdate")
+		   AND EXTRACT(MONTH FROM $1::date + COALESCE("days_before_notify", 1)) = EXTRACT(MONTH FROM "subscribed_users"."birthdate")
+		   AND NOT EXISTS (
+		     SELECT 1 FROM "notification_history"
+		     WHERE "recipient_user_id" = "subscribing_users"."id"
+		       AND "subscribed_user_id" = "subscribed_users"."id"
+		       AND "notify_date" = $1::date
+		   )`,
+		date,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch notifications: %w", err)
@@ -152,11 +390,24 @@ func (db *DBStorage) FetchNotificationsForCurrentDate(ctx context.Context) ([]mo
 
 	result, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.Notification, error) {
 		var notification models.Notification
+		var subscribing, subscribed models.User
 		err := row.Scan(
+			&notification.SubscribingUserID,
 			&notification.SubscribingUserEmail,
+			&subscribing.FirstName,
+			&subscribing.LastName,
+			&subscribing.DisplayName,
 			&notification.DaysBeforeNotify,
+			&notification.SubscribedUserID,
 			&notification.SubscribedUserEmail,
+			&subscribed.FirstName,
+			&subscribed.LastName,
+			&subscribed.DisplayName,
 		)
+		subscribing.Email = notification.SubscribingUserEmail
+		subscribed.Email = notification.SubscribedUserEmail
+		notification.SubscribingUserName = subscribing.Name()
+		notification.SubscribedUserName = subscribed.Name()
 		return notification, err
 	})
 
@@ -182,15 +433,32 @@ func (db *DBStorage) CreateNotificationSetting(ctx context.Context, userID, days
 			return notifySetting, fmt.Errorf("failed to create notify setting: %w", err)
 		}
 
-		// TODO: use proper error types instead of fmt.Errorf
 		switch pgErr.Code {
 		case pgerrcode.ForeignKeyViolation:
-			return notifySetting, fmt.Errorf("user with id=%d does not exists", userID)
+			return notifySetting, ErrUserNotFound{User: models.User{ID: userID}}
 		case pgerrcode.UniqueViolation:
-			return notifySetting, fmt.Errorf("user with id=%d already has notify setting", userID)
+			return notifySetting, ErrNotifySettingNotUniq{NotifySetting: notifySetting}
 		default:
-			return notifySetting, nil
+			return notifySetting, fmt.Errorf("failed to create notify setting: %w", pgErr)
+		}
+	}
+
+	return notifySetting, nil
+}
+
+func (db *DBStorage) FindNotificationSetting(ctx context.Context, settingID int) (models.NotifySetting, error) {
+	row := db.pool.QueryRow(
+		ctx,
+		`SELECT "user_id", "days_before_notify" FROM "notify_settings" WHERE "id" = $1`,
+		settingID,
+	)
+	notifySetting := models.NotifySetting{ID: settingID}
+	err := row.Scan(&notifySetting.UserID, &notifySetting.DaysBeforeNotify)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return notifySetting, ErrNotifySettingNotFound{NotifySetting: notifySetting}
 		}
+		return notifySetting, fmt.Errorf("failed to find notification setting: %w", err)
 	}
 
 	return notifySetting, nil
@@ -206,6 +474,9 @@ func (db *DBStorage) UpdateNotificationSetting(ctx context.Context, settingID, d
 	)
 	err := row.Scan(&notifySetting.UserID)
 	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return notifySetting, ErrNotifySettingNotFound{NotifySetting: notifySetting}
+		}
 		return notifySetting, fmt.Errorf("failed to update notification setting: %w", err)
 	}
 
@@ -215,7 +486,7 @@ func (db *DBStorage) UpdateNotificationSetting(ctx context.Context, settingID, d
 func (db *DBStorage) FetchUsers(ctx context.Context) ([]models.User, error) {
 	rows, err := db.pool.Query(
 		ctx,
-		`SELECT "id", "email", "birthdate" FROM "users"`,
+		`SELECT "id", "email", "birthdate", "first_name", "last_name", "display_name", "bio", "photo" FROM "users"`,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch users: %w", err)
@@ -223,11 +494,18 @@ func (db *DBStorage) FetchUsers(ctx context.Context) ([]models.User, error) {
 
 	result, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.User, error) {
 		var user models.User
+		var photo *string
 		err := row.Scan(
 			&user.ID,
 			&user.Email,
 			&user.BirthDate,
+			&user.FirstName,
+			&user.LastName,
+			&user.DisplayName,
+			&user.Bio,
+			&photo,
 		)
+		setPhoto(&user, photo)
 		return user, err
 	})
 
@@ -238,6 +516,41 @@ func (db *DBStorage) FetchUsers(ctx context.Context) ([]models.User, error) {
 	return result, nil
 }
 
+// userColumns are the columns of "users" read by scanUser.
+const userColumns = `"users"."id", "users"."email", "users"."encrypted_password", "users"."birthdate",
+	"users"."email_verified_at", "users"."pending_email", "users"."role", "users"."disabled_at",
+	"users"."first_name", "users"."last_name", "users"."display_name", "users"."bio", "users"."photo"`
+
+func scanUser(row pgx.Row) (models.User, error) {
+	var user models.User
+	var photo *string
+	err := row.Scan(
+		&user.ID,
+		&user.Email,
+		&user.EncryptedPassword,
+		&user.BirthDate,
+		&user.EmailVerifiedAt,
+		&user.PendingEmail,
+		&user.Role,
+		&user.DisabledAt,
+		&user.FirstName,
+		&user.LastName,
+		&user.DisplayName,
+		&user.Bio,
+		&photo,
+	)
+	setPhoto(&user, photo)
+	return user, err
+}
+
+func setPhoto(user *models.User, photo *string) {
+	if photo == nil {
+		return
+	}
+	user.Photo = *photo
+	user.PhotoURL = models.PhotoPath(user.ID)
+}
+
 //go:embed db/migrations/*.sql
 var migrationsDir embed.FS
 
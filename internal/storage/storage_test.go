@@ -0,0 +1,31 @@
+package storage_test
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/ilya-burinskiy/birthday-notify/internal/storage"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestStorage connects to the database in TEST_DATABASE_URI and runs the
+// migrations. Tests are skipped without it. Every test creates its own users
+// with uniqueEmail, so they do not depend on each other or on existing data.
+func newTestStorage(t *testing.T) *storage.DBStorage {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URI")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URI is not set")
+	}
+
+	db, err := storage.NewDBStorage(dsn)
+	require.NoError(t, err)
+	t.Cleanup(db.Close)
+	return db
+}
+
+func uniqueEmail(name string) string {
+	return fmt.Sprintf("%s-%d@example.com", name, time.Now().UnixNano())
+}
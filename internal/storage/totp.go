@@ -0,0 +1,155 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ilya-burinskiy/birthday-notify/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+func (db *DBStorage) FindTOTP(ctx context.Context, userID int) (models.TOTP, error) {
+	row := db.pool.QueryRow(
+		ctx,
+		`SELECT "secret", "enabled_at", "last_step" FROM "user_totp" WHERE "user_id" = $1`,
+		userID,
+	)
+	totp := models.TOTP{UserID: userID}
+	err := row.Scan(&totp.Secret, &totp.EnabledAt, &totp.LastStep)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return totp, ErrTOTPNotFound{TOTP: totp}
+		}
+		return totp, fmt.Errorf("failed to find totp: %w", err)
+	}
+
+	return totp, nil
+}
+
+// SavePendingTOTP stores a new secret awaiting confirmation. It replaces a
+// previous pending secret but never an enabled one.
+func (db *DBStorage) SavePendingTOTP(ctx context.Context, userID int, secret string) error {
+	tag, err := db.pool.Exec(
+		ctx,
+		`INSERT INTO "user_totp" ("user_id", "secret") VALUES ($1, $2)
+		 ON CONFLICT ("user_id") DO UPDATE
+		 SET "secret" = EXCLUDED."secret", "created_at" = now(), "last_step" = NULL
+		 WHERE "user_totp"."enabled_at" IS NULL`,
+		userID,
+		secret,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save totp: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("failed to save totp: already enabled for user with id=%d", userID)
+	}
+
+	return nil
+}
+
+// EnableTOTP enables the pending secret and replaces the recovery codes.
+func (db *DBStorage) EnableTOTP(ctx context.Context, userID int, step int64, recoveryCodeHashes [][]byte) error {
+	err := pgx.BeginFunc(ctx, db.pool, func(tx pgx.Tx) error {
+		_, err := tx.Exec(
+			ctx,
+			`UPDATE "user_totp" SET "enabled_at" = now(), "last_step" = $2 WHERE "user_id" = $1`,
+			userID,
+			step,
+		)
+		if err != nil {
+			return err
+		}
+		return replaceRecoveryCodes(ctx, tx, userID, recoveryCodeHashes)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to enable totp: %w", err)
+	}
+
+	return nil
+}
+
+func (db *DBStorage) DisableTOTP(ctx context.Context, userID int) error {
+	err := pgx.BeginFunc(ctx, db.pool, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, `DELETE FROM "totp_recovery_codes" WHERE "user_id" = $1`, userID)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx, `DELETE FROM "user_totp" WHERE "user_id" = $1`, userID)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to disable totp: %w", err)
+	}
+
+	return nil
+}
+
+// UseTOTPStep records an accepted code. It fails with ErrTOTPStepUsed if the
+// same or a later code was accepted before.
+func (db *DBStorage) UseTOTPStep(ctx context.Context, userID int, step int64) error {
+	tag, err := db.pool.Exec(
+		ctx,
+		`UPDATE "user_totp" SET "last_step" = $2
+		 WHERE "user_id" = $1 AND ("last_step" IS NULL OR "last_step" < $2)`,
+		userID,
+		step,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to use totp code: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrTOTPStepUsed{TOTP: models.TOTP{UserID: userID}}
+	}
+
+	return nil
+}
+
+func (db *DBStorage) UseRecoveryCode(ctx context.Context, userID int, codeHash []byte) error {
+	tag, err := db.pool.Exec(
+		ctx,
+		`UPDATE "totp_recovery_codes" SET "used_at" = now()
+		 WHERE "user_id" = $1 AND "code_hash" = $2 AND "used_at" IS NULL`,
+		userID,
+		codeHash,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to use recovery code: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrRecoveryCodeNotFound{}
+	}
+
+	return nil
+}
+
+func (db *DBStorage) ReplaceRecoveryCodes(ctx context.Context, userID int, codeHashes [][]byte) error {
+	err := pgx.BeginFunc(ctx, db.pool, func(tx pgx.Tx) error {
+		return replaceRecoveryCodes(ctx, tx, userID, codeHashes)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to replace recovery codes: %w", err)
+	}
+
+	return nil
+}
+
+func replaceRecoveryCodes(ctx context.Context, tx pgx.Tx, userID int, codeHashes [][]byte) error {
+	_, err := tx.Exec(ctx, `DELETE FROM "totp_recovery_codes" WHERE "user_id" = $1`, userID)
+	if err != nil {
+		return err
+	}
+	for _, codeHash := range codeHashes {
+		_, err := tx.Exec(
+			ctx,
+			`INSERT INTO "totp_recovery_codes" ("user_id", "code_hash") VALUES ($1, $2)`,
+			userID,
+			codeHash,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}